@@ -17,13 +17,23 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
-	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/text"
-	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/revision"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/watch"
+	"github.com/Benek2048/ZigzagDockerComposeMake/pkg/dcm"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // buildCmd represents the build command
@@ -33,58 +43,220 @@ var buildCmd = &cobra.Command{
 	Long: `The build command combines separate service definitions and a template 
 docker-compose file into a complete docker-compose.yml file. It looks for service 
 definitions in the 'services' directory and merges them with the template file 
-(docker-compose-dcm.yml) containing shared configurations.`,
+(docker-compose-dcm.yml) containing shared configurations.
+
+With --rev, the template and the service files are read from the given revision of the
+git repository instead of the working tree, which is left untouched. The compose file is
+written to the standard output or to the path given by --output.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Read the flags
 		buildDirectory, _ := cmd.Flags().GetString("directory")
 		templateFileName, _ := cmd.Flags().GetString("template")
 		composeFileName, _ := cmd.Flags().GetString("compose")
 		forceOverwrite, _ := cmd.Flags().GetBool("force")
-		yamlMode, _ := cmd.Flags().GetBool("yaml-mode")
+		formatName, _ := cmd.Flags().GetString("format")
+		noHeader, _ := cmd.Flags().GetBool("no-header")
+		watchMode, _ := cmd.Flags().GetBool("watch")
+		rev, _ := cmd.Flags().GetString("rev")
+
+		outputFormat, err := format.Parse(formatName)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		if rev != "" {
+			if watchMode {
+				cobra.CheckErr(fmt.Errorf("--rev cannot be combined with --watch"))
+			}
+			buildRevision(cmd, buildDirectory, templateFileName, rev, outputFormat, noHeader)
+			return
+		}
 
 		// Show the parameters
 		fmt.Printf("Build directory: %v\n", buildDirectory)
 		fmt.Printf("Template file: %v\n", templateFileName)
-		fmt.Printf("Services directory: %v\n", logic.ServicesDirectoryConst)
+		fmt.Printf("Services directory: %v\n", servicesDirectory(cmd))
 		fmt.Printf("Compose file: %v\n", composeFileName)
 		fmt.Printf("Force overwrite: %v\n", cmd.Flags().Lookup("force").Value.String())
+		fmt.Printf("Output format: %v\n", outputFormat)
 
 		// Create paths
 		templateFilePath := filepath.Join(buildDirectory, templateFileName)
-		serviceDirectoryPath := filepath.Join(buildDirectory, logic.ServicesDirectoryConst)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
 		composeFilePath := filepath.Join(buildDirectory, composeFileName)
 
-		//// Create builder with configuration
-		if !yamlMode {
-			fmt.Println("Text mode")
-			builder := text.NewBuilder(
+		selected, err := selectEngine(cmd, func() (engine.Detection, error) {
+			return engine.DetectBuild(afero.NewOsFs(), templateFilePath, serviceDirectoryPath)
+		})
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		prompter, err := newPrompter(cmd, os.Stdout)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		// newBuilder creates the builder of the selected engine with configuration
+		newBuilder := func(forceOverwrite bool) engine.Builder {
+			builder := selected.NewBuilder(
 				buildDirectory,       // build directory
 				templateFilePath,     // template file path
 				serviceDirectoryPath, // services directory path
 				composeFilePath,      // output file path
 				forceOverwrite,       // force overwrite flag
 			)
+			builder.SetFormat(outputFormat)
+			builder.SetProvenance(!noHeader)
+			builder.SetPrompter(prompter)
+			return builder
+		}
+
+		if watchMode {
+			watchBuild(cmd, buildDirectory, templateFilePath, serviceDirectoryPath, composeFileName, newBuilder)
+			return
+		}
+
+		// Serialize concurrent builds and decompositions of the same project
+		lock, err := lockProject(cmd, buildDirectory)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		// Execute the build
+		err = newBuilder(forceOverwrite).Build()
+		_ = lock.Release()
+		cobra.CheckErr(err)
+		fmt.Printf("Compose file '%v' created\n", composeFilePath)
+	},
+}
+
+// buildRevision builds the compose file from the template and the service files at a revision of the
+// git repository and writes it to the standard output or to the path given by --output.
+// The compose file may go to the standard output, so all other messages go to the standard error.
+func buildRevision(cmd *cobra.Command, buildDirectory, templateFileName, rev string, outputFormat format.Format, noHeader bool) {
+	output, _ := cmd.Flags().GetString("output")
+	forceOverwrite, _ := cmd.Flags().GetBool("force")
+	prompter, err := newPrompter(cmd, os.Stderr)
+	if err != nil {
+		cobra.CheckErr(err)
+	}
+
+	// Show the parameters
+	fmt.Fprintf(os.Stderr, "Build directory: %v\n", buildDirectory)
+	fmt.Fprintf(os.Stderr, "Revision: %v\n", rev)
+	fmt.Fprintf(os.Stderr, "Template file: %v\n", templateFileName)
+	fmt.Fprintf(os.Stderr, "Services directory: %v\n", servicesDirectory(cmd))
+	fmt.Fprintf(os.Stderr, "Output: %v\n", output)
+	fmt.Fprintf(os.Stderr, "Output format: %v\n", outputFormat)
+
+	snapshot, err := revision.Load(cmd.Context(), buildDirectory, rev, templateFileName, servicesDirectory(cmd))
+	if err != nil {
+		cobra.CheckErr(err)
+	}
+	fmt.Fprintf(os.Stderr, "Commit: %v\n", snapshot.Commit)
+
+	result, err := dcm.Build(cmd.Context(), dcm.Options{
+		FS:           afero.NewIOFS(snapshot.Fs),
+		Template:     filepath.ToSlash(templateFileName),
+		ServicesDir:  filepath.ToSlash(servicesDirectory(cmd)),
+		Engine:       engineName(cmd),
+		Format:       string(outputFormat),
+		NoProvenance: noHeader,
+	})
+	if err != nil {
+		cobra.CheckErr(err)
+	}
+	selected, err := engine.Get(result.Engine)
+	if err != nil {
+		cobra.CheckErr(err)
+	}
+	showEngine(os.Stderr, selected, result.EngineReasons)
+	content := result.Files[0].Content
+
+	if output == "-" {
+		_, err := os.Stdout.Write(content)
+		cobra.CheckErr(err)
+		return
+	}
 
-			// Execute the build
-			if err := builder.Build(); err != nil {
-				cobra.CheckErr(err)
+	err = path.WriteFileConfirmed(afero.NewOsFs(), output, content, 0644, prompter, forceOverwrite)
+	cobra.CheckErr(err)
+	fmt.Fprintf(os.Stderr, "Compose file '%v' created from revision %v (%v)\n", output, rev, snapshot.ShortCommit())
+}
+
+// watchBuild builds the project and rebuilds it after every change of the template or the service files
+// until the program is interrupted. The compose file is overwritten without asking, and only the
+// first build backs up the existing one.
+func watchBuild(cmd *cobra.Command, buildDirectory, templateFilePath, serviceDirectoryPath, composeFileName string, newBuilder func(bool) engine.Builder) {
+	debounce, _ := cmd.Flags().GetDuration("debounce")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Only the changed files are read and parsed again, the builder reads the others from the cache
+	cache := watch.NewCache(afero.NewOsFs(), templateFilePath, serviceDirectoryPath)
+	var lastFingerprint string
+	rebuild := func(changed []string, backup bool) {
+		timestamp := time.Now().Format("15:04:05")
+
+		if errs := cache.Update(changed); len(errs) > 0 {
+			fmt.Printf("[%v] Validation failed, compose file not rebuilt:\n", timestamp)
+			for _, err := range errs {
+				fmt.Printf("  %v\n", err)
 			}
-		} else {
-			fmt.Println("Yaml mode")
-			builder := yaml.NewBuilder(
-				buildDirectory,       // build directory
-				templateFilePath,     // template file path
-				serviceDirectoryPath, // services directory path
-				composeFilePath,      // output file path
-				forceOverwrite,       // force overwrite flag
-			)
+			return
+		}
+
+		// Skip rebuilds when the content of the sources did not change, e.g. a file was only touched
+		sources, err := provenance.CollectSources(cache.Fs(), buildDirectory, templateFilePath, serviceDirectoryPath)
+		if err != nil {
+			fmt.Printf("[%v] Build failed: %v\n", timestamp, err)
+			return
+		}
+		var fingerprint strings.Builder
+		for _, source := range sources {
+			fingerprint.WriteString(source.Path + " " + source.Hash + "\n")
+		}
+		if fingerprint.String() == lastFingerprint {
+			return
+		}
 
-			// Execute the build
-			if err := builder.Build(); err != nil {
-				cobra.CheckErr(err)
+		lock, err := acquireLock(cmd, buildDirectory)
+		if err != nil {
+			fmt.Printf("[%v] Build failed: %v\n", timestamp, err)
+			return
+		}
+		builder := newBuilder(true)
+		builder.SetFs(cache.Fs())
+		builder.SetBackup(backup)
+		err = builder.Build()
+		_ = lock.Release()
+		if err != nil {
+			fmt.Printf("[%v] Build failed: %v\n", timestamp, err)
+			return
+		}
+		lastFingerprint = fingerprint.String()
+
+		summary := fmt.Sprintf("%d service file(s)", len(sources)-1)
+		if len(changed) > 0 {
+			relative := make([]string, 0, len(changed))
+			for _, name := range changed {
+				relative = append(relative, provenance.NewSource(buildDirectory, name, nil).Path)
 			}
+			summary += ", changed: " + strings.Join(relative, ", ")
 		}
-	},
+		fmt.Printf("[%v] Rebuilt '%v' from %v\n", timestamp, composeFileName, summary)
+	}
+
+	rebuild(nil, true)
+
+	fmt.Println("Watching for changes, press Ctrl+C to stop")
+	watcher := watch.NewWatcher(templateFilePath, serviceDirectoryPath, debounce)
+	err := watcher.Run(ctx, func(changed []string) {
+		rebuild(changed, false)
+	})
+	cobra.CheckErr(err)
 }
 
 func init() {
@@ -95,5 +267,12 @@ func init() {
 	buildCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file to build")
 	buildCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file to build")
 	buildCmd.Flags().BoolP("force", "f", false, "Force overwrite of existing compose file or services folder")
-	buildCmd.Flags().BoolP("yaml-mode", "", false, "Use YAML mode for processing")
+	addEngineFlags(buildCmd)
+	buildCmd.Flags().StringP("format", "", string(format.YAML), "Output format: yaml, yaml-normalized or json")
+	_ = buildCmd.RegisterFlagCompletionFunc("format", completeValues(format.Names()...))
+	buildCmd.Flags().BoolP("no-header", "", false, "Do not write the provenance header into the compose file")
+	buildCmd.Flags().BoolP("watch", "w", false, "Rebuild the compose file whenever the template or a service file changes")
+	buildCmd.Flags().StringP("rev", "", "", "Build from the given git revision, e.g. a tag or a commit, instead of the working tree")
+	buildCmd.Flags().StringP("output", "o", "-", "Path of the compose file built with --rev, - for the standard output")
+	buildCmd.Flags().Duration("debounce", 300*time.Millisecond, "Time to wait for further changes before rebuilding in watch mode")
 }
@@ -0,0 +1,114 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/inventory"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generates the shell completion script",
+	Long: `The completion command writes the completion script for the given shell to the standard
+output. Besides the commands and flags, service names are completed from the service files
+of the project (from the compose file for extract), and flags like --engine, --format and
+--from complete the values they support.
+
+Bash (requires the bash-completion package):
+  source <(dcm completion bash)
+  dcm completion bash > /etc/bash_completion.d/dcm
+
+Zsh:
+  dcm completion zsh > "${fpath[1]}/_dcm"
+
+Fish:
+  dcm completion fish > ~/.config/fish/completions/dcm.fish
+
+PowerShell:
+  dcm completion powershell | Out-String | Invoke-Expression`,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch args[0] {
+		case "bash":
+			err = rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			err = rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			err = rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			err = rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+		cobra.CheckErr(err)
+	},
+}
+
+// completionFunc completes the arguments or the value of a flag
+type completionFunc = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completeServices completes the first argument with the names of the services of the project,
+// read from the service files as the template includes them, or from the compose file when fromCompose is true
+func completeServices(fromCompose bool) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		// The completion runs without the pre-run hooks, so the project config is applied here
+		if _, err := loadProjectConfig(cmd); err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+
+		var services []inventory.Service
+		var err error
+		if fromCompose {
+			composeFileName, _ := cmd.Flags().GetString("compose")
+			services, err = inventory.FromCompose(afero.NewOsFs(), buildDirectory, filepath.Join(buildDirectory, composeFileName))
+		} else {
+			services, err = inventory.FromProject(afero.NewOsFs(), buildDirectory, filepath.Join(buildDirectory, templateFile(cmd)), filepath.Join(buildDirectory, servicesDirectory(cmd)))
+		}
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		var names []string
+		for _, service := range services {
+			if strings.HasPrefix(service.Name, toComplete) {
+				names = append(names, fmt.Sprintf("%v\t%v:%d", service.Name, service.Source, service.Line))
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeValues completes a flag with the given values, each optionally followed by a tab and a description
+func completeValues(values ...string) completionFunc {
+	return cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp)
+}
+
+func init() {
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(completionCmd)
+}
@@ -0,0 +1,92 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/config"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"path/filepath"
+)
+
+// configFlags maps the flags to the keys of the project config providing their values
+var configFlags = map[string]string{
+	"services-dir": config.ServicesDirKey,
+	"template":     config.TemplateKey,
+	"compose":      config.ComposeKey,
+	"engine":       config.EngineKey,
+	"indent":       config.IndentKey,
+}
+
+// buildConfigFlags maps the flags of the build command to the keys of its section in the project config
+var buildConfigFlags = map[string]string{
+	"format":    config.BuildFormatKey,
+	"no-header": config.BuildNoHeaderKey,
+}
+
+// applyProjectConfig sets the flags of the command that were not given to the values of the project
+// config and the DCM_* environment variables. The flags keep their defaults for everything else.
+// The config file is searched from the start directory up. When it is found in a parent, the project
+// directory only moves to the directory of the config file if the start directory has no template of
+// its own; otherwise the start directory is the project and just inherits the settings.
+func applyProjectConfig(cmd *cobra.Command, path, start string) error {
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed || flag.Name == "directory" {
+			return
+		}
+		key, ok := configFlags[flag.Name]
+		if !ok && cmd == buildCmd {
+			key, ok = buildConfigFlags[flag.Name]
+		}
+		if ok && viper.IsSet(key) {
+			err = flag.Value.Set(viper.GetString(key))
+		}
+	})
+	if err != nil || path == "" || cmd == initCmd {
+		return err
+	}
+
+	directory := cmd.Flags().Lookup("directory")
+	if directory == nil || directory.Changed {
+		return nil
+	}
+	if exists, _ := afero.Exists(afero.NewOsFs(), filepath.Join(start, templateFile(cmd))); exists {
+		return nil
+	}
+	return directory.Value.Set(filepath.Dir(path))
+}
+
+// templateFile returns the name of the template file given by the template flag, or by the project
+// config for commands without the flag
+func templateFile(cmd *cobra.Command) string {
+	if flag := cmd.Flags().Lookup("template"); flag != nil {
+		return flag.Value.String()
+	}
+	if viper.IsSet(config.TemplateKey) {
+		return viper.GetString(config.TemplateKey)
+	}
+	return config.Default().Template
+}
+
+// servicesDirectory returns the name of the directory containing the service files
+func servicesDirectory(cmd *cobra.Command) string {
+	name, _ := cmd.Flags().GetString("services-dir")
+	return name
+}
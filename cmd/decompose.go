@@ -18,11 +18,11 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
-	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/text"
-	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/decompose"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"os"
 	"path/filepath"
@@ -38,7 +38,13 @@ import (
 var decomposeCmd = &cobra.Command{
 	Use:   "decompose",
 	Short: "Breaking down the 'docker-compose.yml' file into individual service files",
-	Long:  `Based on the 'docker-compose.yml' file, service files will be created in the 'services' folder.`,
+	Long: `Based on the 'docker-compose.yml' file, service files will be created in the 'services' folder.
+
+Every file is compared with the existing one. New files are created and unchanged files are
+skipped. For each changed file, the diff is shown and you are asked: y overwrites the file, N keeps it, a
+accepts it and all remaining files. Service files of services not in the compose file are left
+untouched. The replaced files, the template included, are backed up next to themselves with a
+date-based name (e.g. services/app-20240101.yml). With --force, nothing is asked or backed up.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		//fmt.Println("decompose called")
 
@@ -47,19 +53,22 @@ var decomposeCmd = &cobra.Command{
 		templateFileName, _ := cmd.Flags().GetString("template")
 		composeFileName, _ := cmd.Flags().GetString("compose")
 		forceOverwrite, _ := cmd.Flags().GetBool("force")
-		yamlMode, _ := cmd.Flags().GetBool("yaml-mode")
+		prompter, err := newPrompter(cmd, os.Stdout)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
 
 		//Show the parameters
 		fmt.Printf("Build directory: %v\n", buildDirectory)
 		fmt.Printf("Template file: %v\n", templateFileName)
-		fmt.Printf("Services directory: %v\n", logic.ServicesDirectoryConst)
+		fmt.Printf("Services directory: %v\n", servicesDirectory(cmd))
 		fmt.Printf("Compose file: %v\n", composeFileName)
 		fmt.Printf("Force overwrite: %v\n", cmd.Flags().Lookup("force").Value.String())
 		templateFilePath := filepath.Join(buildDirectory, templateFileName)
-		serviceDirectoryPath := filepath.Join(buildDirectory, logic.ServicesDirectoryConst)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
 		composeFilePath := filepath.Join(buildDirectory, composeFileName)
 
-		exists, err := path.IsExist(buildDirectory)
+		exists, err := path.IsExist(afero.NewOsFs(), buildDirectory)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -68,7 +77,7 @@ var decomposeCmd = &cobra.Command{
 			return
 		}
 
-		exists, err = path.IsExist(composeFilePath)
+		exists, err = path.IsExist(afero.NewOsFs(), composeFilePath)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -77,62 +86,52 @@ var decomposeCmd = &cobra.Command{
 			return
 		}
 
-		exists, err = path.IsExist(templateFilePath)
+		selected, err := selectEngine(cmd, func() (engine.Detection, error) {
+			return engine.DetectDecompose(afero.NewOsFs(), composeFilePath)
+		})
 		if err != nil {
 			cobra.CheckErr(err)
 		}
-		if exists && !forceOverwrite {
-			fmt.Printf("Template file '%v' already exists. Overwrite[y/N]?", templateFilePath)
-			answer := input.AskForYesOrNot("y", "N")
-			if !answer {
-				fmt.Println("Operation canceled")
-				return
-			}
-			// Create backup of existing file before overwriting
-			if err := path.BackupExistingFile(templateFilePath); err != nil {
-				fmt.Printf("Error creating backup: %v\n", err)
-				return
-			}
+
+		content, err := os.ReadFile(composeFilePath)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+		decomposition, err := selected.Split(content)
+		if err != nil {
+			cobra.CheckErr(err)
 		}
 
-		exists, err = path.IsExist(serviceDirectoryPath)
+		// Compare every file with the existing one, only the confirmed changes are written. The
+		// questions are asked before the project is locked, so a slow review blocks no other dcm.
+		planner := decompose.NewPlanner(templateFilePath, serviceDirectoryPath)
+		changes, err := planner.Plan(decomposition)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
-		if exists && !forceOverwrite {
-			fmt.Printf("Service directory '%v' already exists. Overwrite[y/N]?", serviceDirectoryPath)
-			answer := input.AskForYesOrNot("y", "N")
-			if !answer {
-				fmt.Println("Operation canceled")
-				return
-			}
-			// Create backup of existing directory before overwriting
-			if err := path.BackupExistingDirectory(serviceDirectoryPath); err != nil {
-				fmt.Printf("Error creating backup: %v\n", err)
-				return
-			}
+		changes, err = decompose.Confirm(changes, prompter, os.Stdout, buildDirectory, forceOverwrite)
+		if err != nil {
+			cobra.CheckErr(err)
 		}
 
-		if !yamlMode {
-			fmt.Println("Text mode")
-			decomposer := text.NewServiceDecomposer(
-				composeFilePath,      // fileSrc
-				templateFilePath,     // fileTemplate
-				serviceDirectoryPath, // servicesDir
-			)
-			if err := decomposer.Decompose(); err != nil {
-				cobra.CheckErr(err)
-			}
-		} else {
-			fmt.Println("Yaml mode")
-			decomposer := yaml.NewServiceDecomposer(
-				composeFilePath,      // fileSrc
-				templateFilePath,     // fileTemplate
-				serviceDirectoryPath, // servicesDir
-			)
-			if err := decomposer.Decompose(); err != nil {
-				cobra.CheckErr(err)
+		// All backups and writes are committed together while holding the project lock,
+		// any failure leaves the project untouched
+		tx := withProjectTx(cmd, buildDirectory, func(tx *path.Transaction) error {
+			// Without --force, the replaced files are backed up
+			tx.SetBackup(!forceOverwrite)
+			if err := planner.Verify(changes); err != nil {
+				return err
 			}
+			return planner.Stage(tx, changes)
+		})
+
+		for _, change := range changes {
+			name, _ := filepath.Rel(buildDirectory, change.Path)
+			fmt.Printf("  %-9v %v\n", change.Status, name)
+		}
+		for _, backup := range tx.Backups() {
+			name, _ := filepath.Rel(buildDirectory, backup)
+			fmt.Printf("  %-9v %v\n", "backup", name)
 		}
 	},
 }
@@ -145,5 +144,5 @@ func init() {
 	decomposeCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file to build")
 	decomposeCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file to build")
 	decomposeCmd.Flags().BoolP("force", "f", false, "Force overwrite")
-	decomposeCmd.Flags().BoolP("yaml-mode", "", false, "Use YAML mode for processing")
+	addEngineFlags(decomposeCmd)
 }
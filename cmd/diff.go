@@ -0,0 +1,181 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/diff"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/revision"
+	"github.com/Benek2048/ZigzagDockerComposeMake/pkg/dcm"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// diffMarkers are the prefixes of the printed differences
+var diffMarkers = map[diff.Kind]string{
+	diff.Added:   "+",
+	diff.Removed: "-",
+	diff.Changed: "~",
+}
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff A B",
+	Short: "Shows the structural differences between two compose files or dcm projects",
+	Long: `The diff command compares two compose files service by service and shows the services
+that were added or removed, changed images and the port, environment, volume and other
+entries that were added, removed or changed. Formatting, comments and key order are ignored.
+
+A and B can each be a compose file, a dcm project directory, which is built in memory,
+or a git revision of the project in the directory given by --directory. An argument that is
+neither an existing file or directory nor a revision of that repository is reported as a
+missing file.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		exitCode, _ := cmd.Flags().GetBool("exit-code")
+
+		oldLabel, oldContent, err := loadCompose(cmd, args[0])
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+		newLabel, newContent, err := loadCompose(cmd, args[1])
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		report, err := diff.Compare(oldContent, newContent)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		fmt.Printf("--- %v\n", oldLabel)
+		fmt.Printf("+++ %v\n", newLabel)
+		if report.Empty() {
+			fmt.Println("No structural differences")
+			return
+		}
+
+		for _, service := range report.Services {
+			fmt.Printf("%v %v\n", diffMarkers[service.Kind], service.Name)
+			for _, entry := range service.Entries {
+				switch entry.Kind {
+				case diff.Added:
+					fmt.Printf("    + %v: %v\n", entry.Key, entry.New)
+				case diff.Removed:
+					fmt.Printf("    - %v: %v\n", entry.Key, entry.Old)
+				default:
+					fmt.Printf("    ~ %v: %v -> %v\n", entry.Key, entry.Old, entry.New)
+				}
+			}
+		}
+		for _, section := range report.Sections {
+			fmt.Printf("%v %v (section)\n", diffMarkers[section.Kind], section.Name)
+		}
+
+		added, removed, changed := report.Count()
+		fmt.Printf("Services: %d added, %d removed, %d changed\n", added, removed, changed)
+		if exitCode {
+			os.Exit(1)
+		}
+	},
+}
+
+// loadCompose returns a label and the content of the compose file described by the argument:
+// a compose file, a dcm project directory or a git revision of the project in --directory
+func loadCompose(cmd *cobra.Command, arg string) (string, []byte, error) {
+	directory, _ := cmd.Flags().GetString("directory")
+	templateFileName, _ := cmd.Flags().GetString("template")
+	composeFileName, _ := cmd.Flags().GetString("compose")
+
+	info, statErr := os.Stat(arg)
+	switch {
+	case statErr == nil && !info.IsDir():
+		content, err := os.ReadFile(arg)
+		return arg, content, err
+	case statErr == nil:
+		content, err := composeFromProject(cmd, os.DirFS(arg))
+		if err != nil {
+			return "", nil, fmt.Errorf("project '%v': %w", arg, err)
+		}
+		return arg, content, nil
+	case !os.IsNotExist(statErr):
+		return "", nil, statErr
+	}
+
+	snapshot, err := revision.Load(cmd.Context(), directory, arg,
+		templateFileName, servicesDirectory(cmd), composeFileName)
+	if errors.Is(err, revision.ErrUnknownRevision) || errors.Is(err, exec.ErrNotFound) {
+		// Only an argument resolving to a commit is read as a revision, anything else is a mistyped path
+		return "", nil, statErr
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("revision '%v': %w", arg, err)
+	}
+	content, err := composeFromProject(cmd, afero.NewIOFS(snapshot.Fs))
+	if err != nil {
+		return "", nil, fmt.Errorf("revision '%v': %w", arg, err)
+	}
+	return fmt.Sprintf("%v (%v)", arg, snapshot.ShortCommit()), content, nil
+}
+
+// composeFromProject builds the compose file of a project in memory.
+// A project without a template is compared by its compose file.
+func composeFromProject(cmd *cobra.Command, project fs.FS) ([]byte, error) {
+	templateFileName, _ := cmd.Flags().GetString("template")
+	composeFileName, _ := cmd.Flags().GetString("compose")
+
+	if _, err := fs.Stat(project, filepath.ToSlash(templateFileName)); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		content, err := fs.ReadFile(project, filepath.ToSlash(composeFileName))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("neither '%v' nor '%v' found", templateFileName, composeFileName)
+		}
+		return content, err
+	}
+
+	result, err := dcm.Build(cmd.Context(), dcm.Options{
+		FS:           project,
+		Template:     filepath.ToSlash(templateFileName),
+		ServicesDir:  filepath.ToSlash(servicesDirectory(cmd)),
+		Engine:       engineName(cmd),
+		NoProvenance: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Files[0].Content, nil
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	wd, _ := os.Getwd()
+	diffCmd.Flags().StringP("directory", "d", wd, "Specify the project directory git revisions are read from")
+	diffCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file of the projects")
+	diffCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file of projects without a template")
+	addEngineFlags(diffCmd)
+	diffCmd.Flags().BoolP("exit-code", "", false, "Exit with code 1 when the compose files differ")
+}
@@ -0,0 +1,72 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+)
+
+// addEngineFlags adds the --engine flag and the deprecated --yaml-mode flag to the command
+func addEngineFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("engine", "e", engine.Text, fmt.Sprintf("Processing engine: %v", strings.Join(engine.Names(), ", ")))
+	_ = cmd.RegisterFlagCompletionFunc("engine", completeValues(engine.Names()...))
+	cmd.Flags().BoolP("yaml-mode", "", false, "Use YAML mode for processing")
+	_ = cmd.Flags().MarkDeprecated("yaml-mode", "use --engine yaml instead")
+}
+
+// resolveEngine returns the engine selected by the flags of the command.
+// The auto engine is resolved by the detect function, the reasons of the choice are returned with it.
+func resolveEngine(cmd *cobra.Command, detect func() (engine.Detection, error)) (engine.Engine, []string, error) {
+	return engine.Resolve(engineName(cmd), detect)
+}
+
+// engineName returns the name of the engine selected by the flags of the command, which may be auto
+func engineName(cmd *cobra.Command) string {
+	name, _ := cmd.Flags().GetString("engine")
+	yamlMode, _ := cmd.Flags().GetBool("yaml-mode")
+	if yamlMode && !cmd.Flags().Changed("engine") {
+		name = engine.YAML
+	}
+	return name
+}
+
+// selectEngine resolves the engine selected by the flags of the command and shows it with the reasons of the choice
+func selectEngine(cmd *cobra.Command, detect func() (engine.Detection, error)) (engine.Engine, error) {
+	e, reasons, err := resolveEngine(cmd, detect)
+	if err != nil {
+		return engine.Engine{}, err
+	}
+	showEngine(os.Stdout, e, reasons)
+	return e, nil
+}
+
+// showEngine shows the engine with the reasons of the choice made by auto
+func showEngine(w io.Writer, e engine.Engine, reasons []string) {
+	if len(reasons) == 0 {
+		fmt.Fprintf(w, "Engine: %v (%v)\n", e.Name, e.Description)
+		return
+	}
+	fmt.Fprintf(w, "Engine: %v (%v), picked by auto because:\n", e.Name, e.Description)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "  - %v\n", reason)
+	}
+}
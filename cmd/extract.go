@@ -0,0 +1,90 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/scaffold"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extractCmd represents the extract command
+var extractCmd = &cobra.Command{
+	Use:   "extract SERVICE",
+	Short: "Moves a single service from docker-compose.yml into its own service file",
+	Long: `The extract command moves one service from the docker-compose.yml file into
+services/SERVICE.yml, indented like the existing service files. The rest of the compose
+file is left as it is; a backup of it is kept next to it. Unlike decompose, the other
+service files and the template are not touched, except that networks and named volumes
+of the service missing in an existing template are declared there.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeServices(true),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+		templateFileName, _ := cmd.Flags().GetString("template")
+		indent, _ := cmd.Flags().GetInt("indent")
+		composeFileName, _ := cmd.Flags().GetString("compose")
+
+		// Create paths
+		templateFilePath := filepath.Join(buildDirectory, templateFileName)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
+		composeFilePath := filepath.Join(buildDirectory, composeFileName)
+
+		stageScaffold(cmd, buildDirectory, templateFileName, func(tx *path.Transaction) (*scaffold.Result, error) {
+			scaffolder := scaffold.NewScaffolder(buildDirectory, templateFilePath, serviceDirectoryPath)
+			scaffolder.SetIndent(indent)
+			return scaffolder.Extract(tx, composeFilePath, args[0])
+		})
+	},
+}
+
+// stageScaffold runs the stage function in a transaction while holding the project lock,
+// commits it and prints the created service files and template declarations
+func stageScaffold(cmd *cobra.Command, buildDirectory, templateFileName string, stage func(tx *path.Transaction) (*scaffold.Result, error)) {
+	var result *scaffold.Result
+	withProjectTx(cmd, buildDirectory, func(tx *path.Transaction) error {
+		var err error
+		result, err = stage(tx)
+		return err
+	})
+
+	for _, filePath := range result.Paths {
+		fmt.Printf("Service file '%v' created\n", filePath)
+	}
+	if len(result.Networks) > 0 {
+		fmt.Printf("Networks added to '%v': %v\n", templateFileName, strings.Join(result.Networks, ", "))
+	}
+	if len(result.Volumes) > 0 {
+		fmt.Printf("Volumes added to '%v': %v\n", templateFileName, strings.Join(result.Volumes, ", "))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(extractCmd)
+
+	wd, _ := os.Getwd()
+	extractCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+	extractCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	extractCmd.Flags().IntP("indent", "", 0, "Indentation of the service names, 0 takes it from the existing service files")
+	extractCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file to extract the service from")
+}
@@ -0,0 +1,165 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/textdiff"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+// formattedFile is a project file whose content differs from its canonical formatting
+type formattedFile struct {
+	path    string
+	content []byte
+	updated []byte
+}
+
+// fmtCmd represents the fmt command
+var fmtCmd = &cobra.Command{
+	Use:   "fmt",
+	Short: "Formats the service files and the template in the canonical style",
+	Long: `The fmt command rewrites the service files and the template in the canonical style:
+two spaces per indentation level, the keys of every service in canonical order (image, build,
+container_name, ...), double-quoted port strings and a blank line between services and between
+the sections of the template. Other blank lines are removed, except in block scalars. Comments
+are kept, the ones at the top and the bottom of a file stay at column 0. The service names are
+indented like in most of the existing service files.
+
+With --check nothing is written: the files that are not formatted are listed and the command
+exits with code 1, which makes it usable in CI. With --diff the changes are printed instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+		templateFileName, _ := cmd.Flags().GetString("template")
+		check, _ := cmd.Flags().GetBool("check")
+		showDiff, _ := cmd.Flags().GetBool("diff")
+		keyOrder, _ := cmd.Flags().GetStringSlice("key-order")
+		indent, _ := cmd.Flags().GetInt("indent")
+
+		// Create paths
+		templateFilePath := filepath.Join(buildDirectory, templateFileName)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
+
+		fs := afero.NewOsFs()
+		files, err := formatProject(fs, templateFilePath, serviceDirectoryPath, keyOrder, indent)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		if check || showDiff {
+			for _, file := range files {
+				name, _ := filepath.Rel(buildDirectory, file.path)
+				if showDiff {
+					fmt.Print(textdiff.Unified(name, name, file.content, file.updated))
+				} else {
+					fmt.Println(name)
+				}
+			}
+			if check && len(files) > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if len(files) == 0 {
+			fmt.Println("All files are formatted")
+			return
+		}
+
+		withProjectTx(cmd, buildDirectory, func(tx *path.Transaction) error {
+			for _, file := range files {
+				if err := tx.WriteFile(file.path, file.updated, 0644); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		for _, file := range files {
+			name, _ := filepath.Rel(buildDirectory, file.path)
+			fmt.Printf("Formatted '%v'\n", name)
+		}
+	},
+}
+
+// formatProject returns the service files and the template that are not in the canonical style,
+// the service files first in the order of their names. An indent of 0 is detected from the service files.
+func formatProject(fs afero.Fs, templateFilePath, serviceDirectoryPath string, keyOrder []string, indent int) ([]formattedFile, error) {
+	names, err := logic.ReadServiceFiles(fs, serviceDirectoryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+
+	var services []formattedFile
+	var contents [][]byte
+	for _, name := range names {
+		filePath := filepath.Join(serviceDirectoryPath, name)
+		content, err := afero.ReadFile(fs, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service file %s: %w", name, err)
+		}
+		services = append(services, formattedFile{path: filePath, content: content})
+		contents = append(contents, content)
+	}
+
+	var files []formattedFile
+	if indent <= 0 {
+		indent = format.DetectIndent(contents)
+	}
+	style := format.SourceStyle{KeyOrder: keyOrder, Indent: indent}
+	for _, file := range services {
+		file.updated, err = format.FormatServiceFile(file.content, style)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format service file %s: %w", filepath.Base(file.path), err)
+		}
+		if string(file.updated) != string(file.content) {
+			files = append(files, file)
+		}
+	}
+
+	content, err := afero.ReadFile(fs, templateFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+	updated, err := format.FormatTemplate(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to format template file: %w", err)
+	}
+	if string(updated) != string(content) {
+		files = append(files, formattedFile{path: templateFilePath, content: content, updated: updated})
+	}
+	return files, nil
+}
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+
+	wd, _ := os.Getwd()
+	fmtCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+	fmtCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	fmtCmd.Flags().IntP("indent", "", 0, "Indentation of the service names, 0 takes it from the existing service files")
+	fmtCmd.Flags().BoolP("check", "", false, "List the files that are not formatted and exit with code 1 instead of writing")
+	fmtCmd.Flags().BoolP("diff", "", false, "Print the changes as a diff instead of writing")
+	fmtCmd.Flags().StringSliceP("key-order", "", format.ServiceKeyOrder, "Canonical order of the keys inside a service")
+}
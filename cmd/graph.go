@@ -0,0 +1,90 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/graph"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+// graphCmd represents the graph command
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Draws the dependencies between the services as DOT or Mermaid",
+	Long: `The graph command draws the services of the project as nodes and their depends_on
+entries as edges, labelled with the condition when one is given. With --networks and
+--volumes the networks and named volumes shared by several services are added as nodes.
+
+The graph is written to the standard output, so it can be rendered with Graphviz or
+embedded in a Markdown file inside a mermaid code block. Cycles, orphaned services and
+dependencies on undefined services are reported on the standard error.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		graphFormat, _ := cmd.Flags().GetString("format")
+		networks, _ := cmd.Flags().GetBool("networks")
+		volumes, _ := cmd.Flags().GetBool("volumes")
+		strict, _ := cmd.Flags().GetBool("strict")
+
+		services, err := loadInventory(cmd)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		g := graph.New(services)
+		output, err := g.Render(graph.Format(graphFormat), graph.Options{Networks: networks, Volumes: volumes})
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+		fmt.Print(output)
+
+		// The report goes to the standard error to keep the graph clean
+		cycles, missing := g.Cycles(), g.Missing()
+		for _, cycle := range cycles {
+			fmt.Fprintf(os.Stderr, "Cycle: %v\n", strings.Join(cycle, ", "))
+		}
+		for _, dependency := range missing {
+			fmt.Fprintf(os.Stderr, "Undefined dependency: %v\n", dependency)
+		}
+		if orphans := g.Orphans(); len(orphans) > 0 {
+			fmt.Fprintf(os.Stderr, "Orphaned services: %v\n", strings.Join(orphans, ", "))
+		}
+
+		if strict && (len(cycles) > 0 || len(missing) > 0) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	wd, _ := os.Getwd()
+	graphCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+	graphCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	graphCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file read with --from compose")
+	graphCmd.Flags().StringP("from", "", "auto", "Read the services from: auto, services or compose")
+	graphCmd.Flags().StringP("format", "", "mermaid", "Output format: dot or mermaid")
+	_ = graphCmd.RegisterFlagCompletionFunc("from", completeValues("auto", "services", "compose"))
+	_ = graphCmd.RegisterFlagCompletionFunc("format", completeValues(string(graph.DOT), string(graph.Mermaid)))
+	graphCmd.Flags().BoolP("networks", "", false, "Add the networks shared by several services as nodes")
+	graphCmd.Flags().BoolP("volumes", "", false, "Add the named volumes shared by several services as nodes")
+	graphCmd.Flags().BoolP("strict", "", false, "Exit with code 1 when the graph has cycles or undefined dependencies")
+}
@@ -0,0 +1,90 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/config"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// initCmd represents the init command
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Creates the project config file dcm.yaml",
+	Long: `The init command creates the project config file dcm.yaml in the directory given by
+--directory, or in the current one. It holds the name of the services directory, the template and the
+compose file, the engine, the indentation of the service names and the options of the
+build command. The values are taken from the flags, so they can be set right away:
+
+  dcm init --services-dir stack --engine auto
+
+Every dcm command run in the directory or below it reads the file. Flags take precedence
+over it, and DCM_* environment variables, e.g. DCM_ENGINE or DCM_BUILD_NO_HEADER, take
+precedence over the file but not over the flags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		project := config.Default()
+		project.ServicesDir = servicesDirectory(cmd)
+		project.Template, _ = cmd.Flags().GetString("template")
+		project.Compose, _ = cmd.Flags().GetString("compose")
+		project.Engine, _ = cmd.Flags().GetString("engine")
+		project.Indent, _ = cmd.Flags().GetInt("indent")
+		forceOverwrite, _ := cmd.Flags().GetBool("force")
+		directory, _ := cmd.Flags().GetString("directory")
+		configFilePath := filepath.Join(directory, logic.ProjectConfigFileNameConst)
+
+		err := project.Validate()
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+		content, err := project.Marshal()
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		prompter, err := newPrompter(cmd, os.Stdout)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+		err = path.WriteFileConfirmed(afero.NewOsFs(), configFilePath, content, 0644, prompter, forceOverwrite)
+		cobra.CheckErr(err)
+
+		fmt.Printf("Project config file '%v' created\n", configFilePath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+
+	wd, _ := os.Getwd()
+	initCmd.Flags().StringP("directory", "d", wd, "Specify the directory to create the project config file in")
+	initCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	initCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file")
+	initCmd.Flags().StringP("engine", "e", engine.Text, fmt.Sprintf("Processing engine: %v", strings.Join(engine.Names(), ", ")))
+	_ = initCmd.RegisterFlagCompletionFunc("engine", completeValues(engine.Names()...))
+	initCmd.Flags().IntP("indent", "", 0, "Indentation of the service names, 0 takes it from the existing service files")
+	initCmd.Flags().BoolP("force", "f", false, "Overwrite an existing project config file without asking")
+}
@@ -0,0 +1,75 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/scaffold"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// injectCmd represents the inject command
+var injectCmd = &cobra.Command{
+	Use:   "inject FILE",
+	Short: "Adds the services of a standalone fragment to the project",
+	Long: `The inject command adds every service of FILE to the project as services/NAME.yml,
+indented like the existing service files and keeping the comments of the fragment. FILE is
+either a compose file with a services section or a service file; use - to read it from
+the standard input. Networks and named volumes of the services missing in the template are
+declared there. Services clashing with existing ones are refused.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+		templateFileName, _ := cmd.Flags().GetString("template")
+		indent, _ := cmd.Flags().GetInt("indent")
+
+		// Create paths
+		templateFilePath := filepath.Join(buildDirectory, templateFileName)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
+
+		var fragment []byte
+		var err error
+		if args[0] == "-" {
+			fragment, err = io.ReadAll(os.Stdin)
+		} else {
+			fragment, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		stageScaffold(cmd, buildDirectory, templateFileName, func(tx *path.Transaction) (*scaffold.Result, error) {
+			scaffolder := scaffold.NewScaffolder(buildDirectory, templateFilePath, serviceDirectoryPath)
+			scaffolder.SetIndent(indent)
+			return scaffolder.Inject(tx, args[0], fragment)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(injectCmd)
+
+	wd, _ := os.Getwd()
+	injectCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+	injectCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	injectCmd.Flags().IntP("indent", "", 0, "Indentation of the service names, 0 takes it from the existing service files")
+}
@@ -0,0 +1,164 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/lint"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Checks the services against rules for common compose mistakes",
+	Long: `The lint command checks every service of the project against the built-in rules:
+images without a tag or with the latest tag, missing restart policies, missing healthchecks,
+privileged containers, the host network, bind mounts of the Docker socket and missing logging
+limits. The services are checked as the template includes them, so anchors of the template
+and merge keys are resolved, and every finding points at the file and line it comes from.
+
+The severity of a rule is changed with --severity RULE=LEVEL, where the level is error, warning,
+note or off. A "# dcm:ignore RULE" comment drops the findings of the rule on its line, on the next
+line when it stands on a line of its own, or for the whole service on the line of the service name.
+The command exits with code 1 when a finding is at least as severe as --fail-on.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		outputFormat, _ := cmd.Flags().GetString("format")
+		severities, _ := cmd.Flags().GetStringToString("severity")
+		failOn, _ := cmd.Flags().GetString("fail-on")
+		listRules, _ := cmd.Flags().GetBool("rules")
+
+		linter := lint.NewLinter()
+		ids := make([]string, 0, len(severities))
+		for id := range severities {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			severity, err := lint.ParseSeverity(severities[id])
+			if err == nil {
+				err = linter.SetSeverity(id, severity)
+			}
+			cobra.CheckErr(err)
+		}
+
+		if listRules {
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, rule := range lint.Rules() {
+				fmt.Fprintf(writer, "%v\t%v\t%v\n", rule.ID, linter.Severity(rule.ID), rule.Description)
+			}
+			cobra.CheckErr(writer.Flush())
+			return
+		}
+
+		threshold := lint.Off
+		if failOn != "none" {
+			severity, err := lint.ParseSeverity(failOn)
+			if err != nil || severity == lint.Off {
+				cobra.CheckErr(fmt.Errorf("unknown level '%v' for --fail-on, expected one of: error, warning, note, none", failOn))
+			}
+			threshold = severity
+		}
+
+		buildDirectory, templateFilePath, serviceDirectoryPath, composeFilePath, from, err := servicesSource(cmd)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+		var findings []lint.Finding
+		if from == "services" {
+			findings, err = linter.Project(buildDirectory, templateFilePath, serviceDirectoryPath)
+		} else {
+			findings, err = linter.Compose(buildDirectory, composeFilePath)
+		}
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		switch outputFormat {
+		case "text":
+			printFindings(findings)
+		case "json":
+			if findings == nil {
+				findings = []lint.Finding{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(findings)
+		case "sarif":
+			var output []byte
+			output, err = linter.SARIF(findings, logic.VersionConst, logic.RepositoryURLConst)
+			if err == nil {
+				fmt.Println(string(output))
+			}
+		default:
+			err = fmt.Errorf("unknown format '%v', expected one of: text, json, sarif", outputFormat)
+		}
+		cobra.CheckErr(err)
+
+		if threshold != lint.Off {
+			for _, finding := range findings {
+				if finding.Severity.AtLeast(threshold) {
+					os.Exit(1)
+				}
+			}
+		}
+	},
+}
+
+// printFindings prints one finding per line followed by the number of findings per severity
+func printFindings(findings []lint.Finding) {
+	if len(findings) == 0 {
+		fmt.Println("No findings")
+		return
+	}
+	counts := make(map[lint.Severity]int)
+	for _, finding := range findings {
+		fmt.Println(finding)
+		counts[finding.Severity]++
+	}
+	fmt.Printf("%d error(s), %d warning(s), %d note(s)\n", counts[lint.Error], counts[lint.Warning], counts[lint.Note])
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	wd, _ := os.Getwd()
+	lintCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+	lintCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	lintCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file read with --from compose")
+	lintCmd.Flags().StringP("from", "", "auto", "Read the services from: auto, services or compose")
+	lintCmd.Flags().StringP("format", "", "text", "Output format: text, json or sarif")
+	lintCmd.Flags().StringToStringP("severity", "", nil, "Severity of a rule as RULE=LEVEL, the level is error, warning, note or off (repeatable)")
+	lintCmd.Flags().StringP("fail-on", "", "error", "Exit with code 1 for findings of this level or above: error, warning, note or none")
+	lintCmd.Flags().BoolP("rules", "", false, "List the rules with their severities")
+	_ = lintCmd.RegisterFlagCompletionFunc("from", completeValues("auto", "services", "compose"))
+	_ = lintCmd.RegisterFlagCompletionFunc("format", completeValues("text", "json", "sarif"))
+	_ = lintCmd.RegisterFlagCompletionFunc("fail-on", completeValues("error", "warning", "note", "none"))
+	var severities []string
+	for _, rule := range lint.Rules() {
+		for _, level := range []lint.Severity{lint.Error, lint.Warning, lint.Note, lint.Off} {
+			severities = append(severities, fmt.Sprintf("%v=%v\t%v", rule.ID, level, rule.Description))
+		}
+	}
+	_ = lintCmd.RegisterFlagCompletionFunc("severity", completeValues(severities...))
+}
@@ -0,0 +1,190 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/inventory"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// listColumns are the headers of the table and CSV output
+var listColumns = []string{"SERVICE", "SOURCE", "IMAGE/BUILD", "PORTS", "NETWORKS", "VOLUMES", "DEPENDS_ON", "PROFILES", "RESTART"}
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the services of the project",
+	Long: `The list command prints every service of the project with its source file, image or
+build context, published host ports, networks, volumes, dependencies, profiles and restart
+policy. The services are read from the service files as the template includes them, or from
+the compose file if the project has no services directory or --from compose is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		outputFormat, _ := cmd.Flags().GetString("format")
+
+		services, err := loadInventory(cmd)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		switch outputFormat {
+		case "table":
+			err = printServiceTable(services)
+		case "json":
+			if services == nil {
+				services = []inventory.Service{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(services)
+		case "csv":
+			err = printServiceCSV(services)
+		default:
+			err = fmt.Errorf("unknown format '%v', expected one of: table, json, csv", outputFormat)
+		}
+		cobra.CheckErr(err)
+	},
+}
+
+// loadInventory reads the services of the project given by the directory, compose and from flags
+func loadInventory(cmd *cobra.Command) ([]inventory.Service, error) {
+	buildDirectory, templateFilePath, serviceDirectoryPath, composeFilePath, from, err := servicesSource(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if from == "services" {
+		return inventory.FromProject(afero.NewOsFs(), buildDirectory, templateFilePath, serviceDirectoryPath)
+	}
+	return inventory.FromCompose(afero.NewOsFs(), buildDirectory, composeFilePath)
+}
+
+// servicesSource returns the paths given by the directory, template and compose flags and where the services
+// are read from: services, or compose when the project has no services directory or --from compose is given
+func servicesSource(cmd *cobra.Command) (buildDirectory, templateFilePath, serviceDirectoryPath, composeFilePath, from string, err error) {
+	buildDirectory, _ = cmd.Flags().GetString("directory")
+	composeFileName, _ := cmd.Flags().GetString("compose")
+	from, _ = cmd.Flags().GetString("from")
+
+	// Create paths
+	templateFilePath = filepath.Join(buildDirectory, templateFile(cmd))
+	serviceDirectoryPath = filepath.Join(buildDirectory, servicesDirectory(cmd))
+	composeFilePath = filepath.Join(buildDirectory, composeFileName)
+
+	switch from {
+	case "auto":
+		exists, err := path.IsExist(afero.NewOsFs(), serviceDirectoryPath)
+		if err != nil {
+			return "", "", "", "", "", err
+		}
+		from = "services"
+		if !exists {
+			from = "compose"
+		}
+	case "services", "compose":
+	default:
+		return "", "", "", "", "", fmt.Errorf("unknown source '%v', expected one of: auto, services, compose", from)
+	}
+	return buildDirectory, templateFilePath, serviceDirectoryPath, composeFilePath, from, nil
+}
+
+// serviceRow returns the columns of a service in the table and CSV output
+func serviceRow(service inventory.Service, separator string) []string {
+	imageOrBuild := service.Image
+	if imageOrBuild == "" && service.Build != "" {
+		imageOrBuild = "build: " + service.Build
+	}
+
+	var dependencies []string
+	for _, dependency := range service.DependsOn {
+		if dependency.Condition != "" {
+			dependencies = append(dependencies, dependency.Service+" ("+dependency.Condition+")")
+		} else {
+			dependencies = append(dependencies, dependency.Service)
+		}
+	}
+
+	return []string{
+		service.Name,
+		service.Source + ":" + strconv.Itoa(service.Line),
+		imageOrBuild,
+		strings.Join(service.Ports, separator),
+		strings.Join(service.Networks, separator),
+		strings.Join(service.Volumes, separator),
+		strings.Join(dependencies, separator),
+		strings.Join(service.Profiles, separator),
+		service.Restart,
+	}
+}
+
+// printServiceTable prints the services as an aligned table, empty columns are shown as '-'
+func printServiceTable(services []inventory.Service) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(listColumns, "\t"))
+	for _, service := range services {
+		row := serviceRow(service, ", ")
+		for i := range row {
+			if row[i] == "" {
+				row[i] = "-"
+			}
+		}
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
+
+// printServiceCSV prints the services as CSV with a header row, list values are separated by ';'
+func printServiceCSV(services []inventory.Service) error {
+	writer := csv.NewWriter(os.Stdout)
+	header := make([]string, 0, len(listColumns))
+	for _, column := range listColumns {
+		header = append(header, strings.ToLower(column))
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, service := range services {
+		if err := writer.Write(serviceRow(service, ";")); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	wd, _ := os.Getwd()
+	listCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+	listCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	listCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file read with --from compose")
+	listCmd.Flags().StringP("from", "", "auto", "Read the services from: auto, services or compose")
+	listCmd.Flags().StringP("format", "", "table", "Output format: table, json or csv")
+	_ = listCmd.RegisterFlagCompletionFunc("from", completeValues("auto", "services", "compose"))
+	_ = listCmd.RegisterFlagCompletionFunc("format", completeValues("table", "json", "csv"))
+}
@@ -0,0 +1,77 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+)
+
+// lockProject acquires the lock of the project directory using the lock flags of the root command.
+// The lock is also released when the program is interrupted, e.g. while waiting for an answer.
+func lockProject(cmd *cobra.Command, directory string) (*path.Lock, error) {
+	lock, err := acquireLock(cmd, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupted; ok {
+			_ = lock.Release()
+			fmt.Println()
+			os.Exit(130)
+		}
+	}()
+
+	return lock, nil
+}
+
+// acquireLock acquires the lock of the project directory using the lock flags of the root command
+func acquireLock(cmd *cobra.Command, directory string) (*path.Lock, error) {
+	wait, _ := cmd.Flags().GetDuration("lock-timeout")
+	stale, _ := cmd.Flags().GetDuration("lock-stale")
+	return path.AcquireLock(afero.NewOsFs(), directory, wait, stale)
+}
+
+// withProjectTx runs the stage function in a transaction on the project directory while holding the
+// project lock and commits it. If the stage function or the commit fails, the transaction is rolled
+// back, the lock released and the command ends with the error. It returns the committed transaction.
+func withProjectTx(cmd *cobra.Command, directory string, stage func(tx *path.Transaction) error) *path.Transaction {
+	lock, err := lockProject(cmd, directory)
+	if err != nil {
+		cobra.CheckErr(err)
+	}
+	defer lock.Release()
+
+	tx, err := path.NewTransaction(afero.NewOsFs(), directory)
+	if err == nil {
+		err = stage(tx)
+		if err == nil {
+			err = tx.Commit()
+		}
+		tx.Rollback()
+	}
+	_ = lock.Release()
+	cobra.CheckErr(err)
+	return tx
+}
@@ -0,0 +1,110 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/scaffold"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+// newCmd represents the new command
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Creates new files in a dcm project",
+}
+
+// newServiceCmd represents the new service command
+var newServiceCmd = &cobra.Command{
+	Use:   "service NAME",
+	Short: "Creates a new service file",
+	Long: `The new service command creates services/NAME.yml with the given image, ports, networks
+and volumes, indented like the existing service files. With --from, the service starts from
+an entry of the built-in library, e.g. postgres or redis; use --list to show the entries.
+
+Networks and named volumes used by the service that are not declared in the template are
+added to it. A name already used by another service is refused.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+		templateFileName, _ := cmd.Flags().GetString("template")
+		indent, _ := cmd.Flags().GetInt("indent")
+		list, _ := cmd.Flags().GetBool("list")
+		from, _ := cmd.Flags().GetString("from")
+		image, _ := cmd.Flags().GetString("image")
+		ports, _ := cmd.Flags().GetStringArray("port")
+		networks, _ := cmd.Flags().GetStringArray("network")
+		volumes, _ := cmd.Flags().GetStringArray("volume")
+
+		if list {
+			for _, entry := range scaffold.Library() {
+				fmt.Printf("  %-10s %v\n", entry.Name, entry.Description)
+			}
+			return
+		}
+
+		// Create paths
+		templateFilePath := filepath.Join(buildDirectory, templateFileName)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
+
+		stageScaffold(cmd, buildDirectory, templateFileName, func(tx *path.Transaction) (*scaffold.Result, error) {
+			scaffolder := scaffold.NewScaffolder(buildDirectory, templateFilePath, serviceDirectoryPath)
+			scaffolder.SetIndent(indent)
+			return scaffolder.Stage(tx, scaffold.Options{
+				Name:     args[0],
+				From:     from,
+				Image:    image,
+				Ports:    ports,
+				Networks: networks,
+				Volumes:  volumes,
+			})
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	newCmd.AddCommand(newServiceCmd)
+
+	wd, _ := os.Getwd()
+	newServiceCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+	newServiceCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	newServiceCmd.Flags().IntP("indent", "", 0, "Indentation of the service names, 0 takes it from the existing service files")
+	newServiceCmd.Flags().StringP("from", "", "", "Start from an entry of the built-in library")
+	_ = newServiceCmd.RegisterFlagCompletionFunc("from", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var entries []string
+		for _, entry := range scaffold.Library() {
+			entries = append(entries, entry.Name+"\t"+entry.Description)
+		}
+		return entries, cobra.ShellCompDirectiveNoFileComp
+	})
+	newServiceCmd.Flags().BoolP("list", "", false, "List the entries of the built-in library")
+	newServiceCmd.Flags().StringP("image", "", "", "Image of the service")
+	newServiceCmd.Flags().StringArrayP("port", "p", nil, "Port mapping, e.g. 8080:80 (repeatable)")
+	newServiceCmd.Flags().StringArrayP("network", "n", nil, "Network to attach the service to (repeatable)")
+	newServiceCmd.Flags().StringArrayP("volume", "v", nil, "Volume mount, e.g. data:/data (repeatable)")
+}
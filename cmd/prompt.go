@@ -0,0 +1,34 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+// newPrompter returns the prompter selected by the prompt flags of the root command, printing the
+// questions to out
+func newPrompter(cmd *cobra.Command, out io.Writer) (input.Prompter, error) {
+	var options input.Options
+	options.Yes, _ = cmd.Flags().GetBool("yes")
+	options.No, _ = cmd.Flags().GetBool("no")
+	options.NonInteractive, _ = cmd.Flags().GetBool("non-interactive")
+	return input.Select(options, os.Stdin, input.IsTerminal(os.Stdin), out)
+}
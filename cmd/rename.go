@@ -0,0 +1,69 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/refactor"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+// renameCmd represents the rename command
+var renameCmd = &cobra.Command{
+	Use:   "rename OLD NEW",
+	Short: "Renames a service and rewrites the references to it",
+	Long: `The rename command renames the service OLD to NEW in its service file and renames
+services/OLD.yml to services/NEW.yml. Every reference to the service in the service files
+is rewritten as well: depends_on (list and map forms), links, network_mode: service:OLD,
+volumes_from and extends.service. Only the names are replaced, comments and formatting
+are preserved. Host names inside values, e.g. in environment variables, are not changed.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeServices(false),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+
+		// Create paths
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
+
+		var result *refactor.RenameResult
+		withProjectTx(cmd, buildDirectory, func(tx *path.Transaction) error {
+			var err error
+			result, err = refactor.NewRenamer(buildDirectory, serviceDirectoryPath).Stage(tx, args[0], args[1])
+			return err
+		})
+
+		fmt.Printf("Service '%v' renamed to '%v'\n", args[0], args[1])
+		if result.Target != result.Source {
+			fmt.Printf("Service file '%v' renamed to '%v'\n", result.Source, result.Target)
+		}
+		for _, reference := range result.References {
+			fmt.Printf("  %v:%d %v (%v)\n", reference.Source, reference.Line, reference.Service, reference.Kind)
+		}
+		fmt.Printf("%d reference(s) rewritten, run 'dcm build' to regenerate the compose file\n", len(result.References))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+
+	wd, _ := os.Getwd()
+	renameCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+}
@@ -0,0 +1,98 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/refactor"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+// rmCmd represents the rm command
+var rmCmd = &cobra.Command{
+	Use:   "rm SERVICE",
+	Short: "Removes a service and lists the services referencing it",
+	Long: `The rm command removes the service from its service file, and the file itself when it
+defines no other service. Every other service referencing the removed one is listed.
+
+With --prune, the depends_on entries referring to the service are removed as well, and so
+are the networks and named volumes of the template that no other service uses. The changes
+are shown as a diff and have to be confirmed unless --force is given. Every changed or removed
+file is backed up next to itself with a date-based name, e.g. services/db-20241231.yml.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeServices(false),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+		templateFileName, _ := cmd.Flags().GetString("template")
+		prune, _ := cmd.Flags().GetBool("prune")
+		forceOverwrite, _ := cmd.Flags().GetBool("force")
+		prompter, err := newPrompter(cmd, os.Stdout)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		// Create paths
+		templateFilePath := filepath.Join(buildDirectory, templateFileName)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
+
+		var result *refactor.RemoveResult
+		tx := withProjectTx(cmd, buildDirectory, func(tx *path.Transaction) error {
+			tx.SetBackup(true)
+			var err error
+			result, err = refactor.NewRemover(buildDirectory, templateFilePath, serviceDirectoryPath).Stage(tx, args[0], prune)
+			if err != nil {
+				return err
+			}
+			return result.Confirm(prompter, os.Stdout, buildDirectory, forceOverwrite)
+		})
+
+		fmt.Printf("Service '%v' removed\n", args[0])
+		for _, backup := range tx.Backups() {
+			name, _ := filepath.Rel(buildDirectory, backup)
+			fmt.Printf("  backup   %v\n", name)
+		}
+		for _, reference := range result.Pruned {
+			fmt.Printf("  pruned   %v:%d %v (%v)\n", reference.Source, reference.Line, reference.Service, reference.Kind)
+		}
+		remaining := result.Remaining()
+		for _, reference := range remaining {
+			fmt.Printf("  dangling %v:%d %v (%v)\n", reference.Source, reference.Line, reference.Service, reference.Kind)
+		}
+		if len(remaining) > 0 {
+			hint := ""
+			if !prune {
+				hint = ", use --prune to remove the depends_on entries"
+			}
+			fmt.Printf("%d reference(s) to '%v' left%v\n", len(remaining), args[0], hint)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rmCmd)
+
+	wd, _ := os.Getwd()
+	rmCmd.Flags().StringP("directory", "d", wd, "Specify the project directory")
+	rmCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	rmCmd.Flags().BoolP("prune", "", false, "Remove the depends_on entries and the template networks and volumes left without users")
+	rmCmd.Flags().BoolP("force", "f", false, "Apply the changes without asking")
+}
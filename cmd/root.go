@@ -20,6 +20,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/config"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -28,7 +31,7 @@ var cfgFile string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "ZigzagDockerComposeMake",
+	Use:   "dcm",
 	Short: "A brief description of your application",
 	Long: `A longer description that spans multiple lines and likely contains
 examples and usage of using your application. For example:
@@ -36,6 +39,9 @@ examples and usage of using your application. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(initConfig(cmd))
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -51,39 +57,61 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
-
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ZigzagDockerComposeMake.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "project config file (default is "+logic.ProjectConfigFileNameConst+" in the project directory or its nearest parent)")
+	rootCmd.PersistentFlags().String("services-dir", logic.ServicesDirectoryConst, "Name of the directory containing the service files")
+	_ = rootCmd.MarkPersistentFlagDirname("services-dir")
+	rootCmd.PersistentFlags().Duration("lock-timeout", logic.LockWaitTimeoutConst, "How long to wait for a project locked by another dcm process")
+	rootCmd.PersistentFlags().Duration("lock-stale", logic.LockStaleTimeoutConst, "Age after which a project lock is considered abandoned")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "Answer yes to every question")
+	rootCmd.PersistentFlags().Bool("no", false, "Answer no to every question")
+	rootCmd.PersistentFlags().Bool("non-interactive", false, "Fail instead of asking questions (default when the standard input is not a terminal)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
-// initConfig reads in config file and ENV variables if set.
-func initConfig() {
-	if cfgFile != "" {
-		// Use config file from the flag.
-		viper.SetConfigFile(cfgFile)
-	} else {
-		// Find home directory.
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-
-		// Search config in home directory with name ".ZigzagDockerComposeMake" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".ZigzagDockerComposeMake")
+// initConfig reads in the project config file and ENV variables if set, and applies them to the flags
+// of the command that were not given
+func initConfig(cmd *cobra.Command) error {
+	path, err := loadProjectConfig(cmd)
+	if err != nil {
+		return err
+	}
+	// Keep the completion output clean
+	switch cmd.Name() {
+	case "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return nil
 	}
+	if path != "" {
+		fmt.Fprintln(os.Stderr, "Using config file:", path)
+	}
+	return nil
+}
 
-	viper.AutomaticEnv() // read in environment variables that match
+// loadProjectConfig reads in the project config file and ENV variables and applies them to the flags of
+// the command that were not given. The config file is searched from the project directory up, its path
+// is returned, an empty string if there is none.
+func loadProjectConfig(cmd *cobra.Command) (string, error) {
+	start, _ := os.Getwd()
+	if directory := cmd.Flags().Lookup("directory"); directory != nil && directory.Changed {
+		start = directory.Value.String()
+	}
+	path := cfgFile
+	if path == "" {
+		found, err := config.Find(afero.NewOsFs(), start)
+		if err != nil {
+			return "", err
+		}
+		path = found
+	}
 
-	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	if err := config.Setup(viper.GetViper(), afero.NewOsFs(), path); err != nil {
+		return "", err
 	}
+	return path, applyProjectConfig(cmd, path, start)
 }
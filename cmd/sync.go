@@ -0,0 +1,100 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Propagates edits made in docker-compose.yml back into the service files",
+	Long: `The sync command compares a hand-edited docker-compose.yml file with its sources,
+splits the changes per service and applies them back into the matching file in the
+'services' directory and into the template file, preserving comments.
+
+The provenance header written by the build command tells which services and sections
+were edited in the compose file and which sources changed since it was generated. Only
+the edited services and sections are written back, sources edited after the build are
+left alone. If a service or the template was edited on both sides, the change is
+reported as a conflict and nothing is written. Use --force to let the compose file win.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+		templateFileName, _ := cmd.Flags().GetString("template")
+		composeFileName, _ := cmd.Flags().GetString("compose")
+		forceOverwrite, _ := cmd.Flags().GetBool("force")
+
+		// Create paths
+		templateFilePath := filepath.Join(buildDirectory, templateFileName)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
+		composeFilePath := filepath.Join(buildDirectory, composeFileName)
+
+		var report *yaml.SyncReport
+		withProjectTx(cmd, buildDirectory, func(tx *path.Transaction) error {
+			var err error
+			report, err = yaml.NewSyncer(buildDirectory, templateFilePath, serviceDirectoryPath, composeFilePath, forceOverwrite).Stage(tx)
+			if err != nil || !report.HandEdited {
+				return err
+			}
+
+			for _, change := range report.Changes {
+				services := ""
+				if len(change.Services) > 0 {
+					services = fmt.Sprintf(" (%v)", strings.Join(change.Services, ", "))
+				}
+				if change.Action == yaml.SyncConflict {
+					fmt.Printf("  %-8s %v%v: %v\n", change.Action, change.Source, services, change.Reason)
+				} else {
+					fmt.Printf("  %-8s %v%v\n", change.Action, change.Source, services)
+				}
+			}
+			if conflicts := report.Conflicts(); len(conflicts) > 0 {
+				return fmt.Errorf("%d conflict(s) found, nothing was written; use --force to let the compose file win", len(conflicts))
+			}
+			return nil
+		})
+
+		if !report.HandEdited {
+			fmt.Printf("Compose file '%v' was not edited since it was generated, nothing to sync\n", composeFileName)
+			return
+		}
+		if len(report.Changes) == 0 {
+			fmt.Println("Compose file and sources are in sync")
+			return
+		}
+		fmt.Println("Sources updated, run 'dcm build' to regenerate the compose file")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	wd, _ := os.Getwd()
+	syncCmd.Flags().StringP("directory", "d", wd, "Specify the directory to sync")
+	syncCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	syncCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the edited compose file")
+	syncCmd.Flags().BoolP("force", "f", false, "Let the compose file win all conflicts")
+}
@@ -0,0 +1,93 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies that the docker-compose.yml file matches its sources",
+	Long: `The verify command reads the provenance header written by the build command,
+re-hashes the template, the service files and the compose file itself, and reports
+whether the compose file is stale (the sources changed since it was generated) or
+was edited by hand after generation. It exits with a non-zero code in both cases.
+Compose files built in JSON format carry no provenance header and cannot be verified.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Read the flags
+		buildDirectory, _ := cmd.Flags().GetString("directory")
+		templateFileName, _ := cmd.Flags().GetString("template")
+		composeFileName, _ := cmd.Flags().GetString("compose")
+
+		// Create paths
+		templateFilePath := filepath.Join(buildDirectory, templateFileName)
+		serviceDirectoryPath := filepath.Join(buildDirectory, servicesDirectory(cmd))
+		composeFilePath := filepath.Join(buildDirectory, composeFileName)
+
+		report, err := provenance.Verify(afero.NewOsFs(), buildDirectory, templateFilePath, serviceDirectoryPath, composeFilePath)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		if report.JSON {
+			cobra.CheckErr(fmt.Errorf("compose file '%v' is in JSON format, which carries no provenance header and cannot be verified", composeFileName))
+		}
+		if report.Header == nil {
+			cobra.CheckErr(fmt.Errorf("compose file '%v' has no provenance header, rebuild it with 'dcm build'", composeFileName))
+		}
+
+		fmt.Printf("Compose file: %v\n", composeFileName)
+		fmt.Printf("Generated by: dcm %v\n", report.Header.Version)
+		for _, source := range report.Changed {
+			fmt.Printf("  changed: %v\n", source)
+		}
+		for _, source := range report.Added {
+			fmt.Printf("  added:   %v\n", source)
+		}
+		for _, source := range report.Removed {
+			fmt.Printf("  removed: %v\n", source)
+		}
+
+		switch {
+		case report.UpToDate():
+			fmt.Println("Status: up to date")
+		case report.HandEdited && report.Stale():
+			cobra.CheckErr(fmt.Errorf("compose file '%v' is stale and was edited by hand after generation", composeFileName))
+		case report.HandEdited:
+			cobra.CheckErr(fmt.Errorf("compose file '%v' was edited by hand after generation", composeFileName))
+		default:
+			cobra.CheckErr(fmt.Errorf("compose file '%v' is stale, run 'dcm build'", composeFileName))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	wd, _ := os.Getwd()
+	verifyCmd.Flags().StringP("directory", "d", wd, "Specify the directory to verify")
+	verifyCmd.Flags().StringP("template", "t", logic.TemplateFileNameDefaultConst, "Specify the template file")
+	verifyCmd.Flags().StringP("compose", "c", logic.ComposeFileNameConst, "Specify the compose file to verify")
+}
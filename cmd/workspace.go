@@ -0,0 +1,242 @@
+// Package cmd /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/config"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/decompose"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/workspace"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// workspaceCmd represents the workspace command
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Runs an operation on every dcm project below a root directory",
+	Long: `The workspace commands find every directory below the root directory that contains
+a template file (docker-compose-dcm.yml) or a project config file (dcm.yaml) and run the
+chosen operation on each of them in parallel, with the file names and the engine of the
+project config file of each project. An aggregated report with the status of
+each project is printed at the end, and the exit code is non-zero if any project failed.
+
+The root directory is the current directory, or the one given as argument or with --root.
+The operations never ask questions: changed files are backed up next to themselves and
+overwritten. Like dcm decompose, workspace decompose leaves the service files of services
+not in the compose file untouched.`,
+}
+
+// workspaceBuildCmd represents the workspace build command
+var workspaceBuildCmd = &cobra.Command{
+	Use:   "build [root]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Builds the docker-compose.yml file of every project",
+	Run: func(cmd *cobra.Command, args []string) {
+		runWorkspace(cmd, args, func(dir string, project config.Project) error {
+			templateFilePath := filepath.Join(dir, project.Template)
+			serviceDirectoryPath := filepath.Join(dir, project.ServicesDir)
+
+			selected, _, err := engine.Resolve(projectEngine(cmd, project), func() (engine.Detection, error) {
+				return engine.DetectBuild(afero.NewOsFs(), templateFilePath, serviceDirectoryPath)
+			})
+			if err != nil {
+				return err
+			}
+			builder := selected.NewBuilder(dir, templateFilePath, serviceDirectoryPath,
+				filepath.Join(dir, project.Compose), true)
+			outputFormat, err := format.Parse(project.Build.Format)
+			if err != nil {
+				return err
+			}
+			builder.SetFormat(outputFormat)
+			builder.SetProvenance(!project.Build.NoHeader)
+			return builder.Build()
+		})
+	},
+}
+
+// workspaceDecomposeCmd represents the workspace decompose command
+var workspaceDecomposeCmd = &cobra.Command{
+	Use:   "decompose [root]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Decomposes the docker-compose.yml file of every project",
+	Run: func(cmd *cobra.Command, args []string) {
+		runWorkspace(cmd, args, func(dir string, project config.Project) error {
+			templateFilePath := filepath.Join(dir, project.Template)
+			serviceDirectoryPath := filepath.Join(dir, project.ServicesDir)
+			composeFilePath := filepath.Join(dir, project.Compose)
+
+			selected, _, err := engine.Resolve(projectEngine(cmd, project), func() (engine.Detection, error) {
+				return engine.DetectDecompose(afero.NewOsFs(), composeFilePath)
+			})
+			if err != nil {
+				return err
+			}
+
+			content, err := afero.ReadFile(afero.NewOsFs(), composeFilePath)
+			if err != nil {
+				return err
+			}
+			decomposition, err := selected.Split(content)
+			if err != nil {
+				return err
+			}
+
+			// Like dcm decompose, without asking: the changed files are overwritten and backed up next
+			// to themselves, and service files of services not in the compose file are left untouched
+			planner := decompose.NewPlanner(templateFilePath, serviceDirectoryPath)
+			changes, err := planner.Plan(decomposition)
+			if err != nil {
+				return err
+			}
+
+			tx, err := path.NewTransaction(afero.NewOsFs(), dir)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			tx.SetBackup(true)
+			if err := planner.Stage(tx, changes); err != nil {
+				return err
+			}
+			return tx.Commit()
+		})
+	},
+}
+
+// workspaceCheckCmd represents the workspace check command
+var workspaceCheckCmd = &cobra.Command{
+	Use:   "check [root]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Verifies that the docker-compose.yml file of every project matches its sources",
+	Run: func(cmd *cobra.Command, args []string) {
+		runWorkspace(cmd, args, func(dir string, project config.Project) error {
+			report, err := provenance.Verify(afero.NewOsFs(), dir, filepath.Join(dir, project.Template),
+				filepath.Join(dir, project.ServicesDir), filepath.Join(dir, project.Compose))
+			if err != nil {
+				return err
+			}
+			switch {
+			case report.JSON:
+				return fmt.Errorf("JSON format, cannot be verified")
+			case report.Header == nil:
+				return fmt.Errorf("no provenance header")
+			case report.HandEdited && report.Stale():
+				return fmt.Errorf("stale and edited by hand")
+			case report.HandEdited:
+				return fmt.Errorf("edited by hand")
+			case report.Stale():
+				return fmt.Errorf("stale")
+			}
+			return nil
+		})
+	},
+}
+
+// runWorkspace discovers the projects, runs the operation on each of them holding the project lock
+// with the configuration of its project config file, and prints the aggregated report
+func runWorkspace(cmd *cobra.Command, args []string, operation func(dir string, project config.Project) error) {
+	root, err := workspaceRoot(cmd, args)
+	if err != nil {
+		cobra.CheckErr(err)
+	}
+	jobs, _ := cmd.Flags().GetInt("jobs")
+
+	projects, err := workspace.Discover(afero.NewOsFs(), root, logic.TemplateFileNameDefaultConst, logic.ProjectConfigFileNameConst)
+	if err != nil {
+		cobra.CheckErr(err)
+	}
+	if len(projects) == 0 {
+		cobra.CheckErr(fmt.Errorf("no dcm projects found in '%v'", root))
+	}
+	fmt.Printf("Found %d project(s) in '%v'\n", len(projects), root)
+
+	results := workspace.Run(projects, jobs, func(dir string) error {
+		lock, err := acquireLock(cmd, dir)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		project, err := config.Load(afero.NewOsFs(), dir)
+		if err != nil {
+			return err
+		}
+		return operation(dir, project)
+	})
+
+	fmt.Println()
+	fmt.Printf("%-8s %-40s %10s  %s\n", "STATUS", "PROJECT", "DURATION", "DETAILS")
+	for _, result := range results {
+		name, err := filepath.Rel(root, result.Dir)
+		if err != nil {
+			name = result.Dir
+		}
+		status, details := "ok", ""
+		if result.Err != nil {
+			status, details = "FAILED", strings.TrimSpace(result.Err.Error())
+		}
+		fmt.Printf("%-8s %-40s %10v  %s\n", status, name, result.Duration.Round(1e6), details)
+	}
+
+	if failed := workspace.Failed(results); failed > 0 {
+		cobra.CheckErr(fmt.Errorf("%d of %d project(s) failed", failed, len(results)))
+	}
+}
+
+// workspaceRoot returns the root directory given as argument, or by the root flag without it
+func workspaceRoot(cmd *cobra.Command, args []string) (string, error) {
+	root, _ := cmd.Flags().GetString("root")
+	if len(args) == 0 {
+		return root, nil
+	}
+	if cmd.Flags().Changed("root") {
+		return "", fmt.Errorf("the root directory is given both as argument and with --root")
+	}
+	return args[0], nil
+}
+
+// projectEngine returns the engine given by the flags of the command, or by the project config without them
+func projectEngine(cmd *cobra.Command, project config.Project) string {
+	if cmd.Flags().Changed("engine") || cmd.Flags().Changed("yaml-mode") {
+		return engineName(cmd)
+	}
+	return project.Engine
+}
+
+func init() {
+	rootCmd.AddCommand(workspaceCmd)
+	workspaceCmd.AddCommand(workspaceBuildCmd)
+	workspaceCmd.AddCommand(workspaceDecomposeCmd)
+	workspaceCmd.AddCommand(workspaceCheckCmd)
+
+	wd, _ := os.Getwd()
+	workspaceCmd.PersistentFlags().StringP("root", "r", wd, "Specify the root directory to search for projects")
+	workspaceCmd.PersistentFlags().IntP("jobs", "j", runtime.NumCPU(), "Maximum number of projects processed in parallel")
+	addEngineFlags(workspaceBuildCmd)
+	addEngineFlags(workspaceDecomposeCmd)
+}
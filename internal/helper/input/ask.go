@@ -36,7 +36,12 @@ import (
 //
 //	AskForYesOrNot("yes", "no") will return true if the user inputs "yes", and false otherwise.
 func AskForYesOrNot(answerYes string, defaultAnswer string) (answer bool) {
-	input := getInput()
+	return askForYesOrNot(bufio.NewReader(os.Stdin), answerYes, defaultAnswer)
+}
+
+// askForYesOrNot is AskForYesOrNot reading the answer from the reader
+func askForYesOrNot(reader *bufio.Reader, answerYes string, defaultAnswer string) (answer bool) {
+	input := getInput(reader)
 	if input == "" {
 		input = strings.ToLower(defaultAnswer)
 	}
@@ -61,7 +66,12 @@ func AskForYesOrNot(answerYes string, defaultAnswer string) (answer bool) {
 //	AskForYesOrNotOrForAll("yes", "all", "no") will return true if the user inputs "yes" or "all", and false otherwise.
 //	It will also return true for the second return value if the user inputs "all", and false otherwise.
 func AskForYesOrNotOrForAll(answerYes string, answerForAll string, defaultAnswer string) (answer bool, forAll bool) {
-	input := getInput()
+	return askForYesOrNotOrForAll(bufio.NewReader(os.Stdin), answerYes, answerForAll, defaultAnswer)
+}
+
+// askForYesOrNotOrForAll is AskForYesOrNotOrForAll reading the answer from the reader
+func askForYesOrNotOrForAll(reader *bufio.Reader, answerYes string, answerForAll string, defaultAnswer string) (answer bool, forAll bool) {
+	input := getInput(reader)
 	if input == "" {
 		input = strings.ToLower(defaultAnswer)
 	}
@@ -70,9 +80,8 @@ func AskForYesOrNotOrForAll(answerYes string, answerForAll string, defaultAnswer
 	return
 }
 
-// getInput reads a line of input from the user in the console and returns it as a lowercase string with whitespace trimmed.
-func getInput() string {
-	reader := bufio.NewReader(os.Stdin)
+// getInput reads a line of input from the user and returns it as a lowercase string with whitespace trimmed.
+func getInput(reader *bufio.Reader) string {
 	input, _ := reader.ReadString('\n')
 	return strings.ToLower(strings.TrimSpace(input))
 }
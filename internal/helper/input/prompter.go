@@ -0,0 +1,154 @@
+package input
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNonInteractive is returned by the non-interactive prompter for every question
+var ErrNonInteractive = errors.New("confirmation required but running non-interactively")
+
+// ErrCanceled is returned by Require when the question is answered with no
+var ErrCanceled = errors.New("operation canceled")
+
+// Prompter asks the user to confirm operations. The question is printed without the list of answers,
+// e.g. "Compose file 'docker-compose.yml' already exists. Overwrite".
+type Prompter interface {
+	// Confirm asks a yes or no question, no is the default
+	Confirm(question string) (bool, error)
+	// ConfirmOrAll asks a yes, no or all question, no is the default. All confirms this and every
+	// following question of the same kind, which is reported by forAll.
+	ConfirmOrAll(question string) (answer bool, forAll bool, err error)
+}
+
+// Options are the prompt flags of a command
+type Options struct {
+	// Yes answers every question with yes
+	Yes bool
+	// No answers every question with no
+	No bool
+	// NonInteractive fails every question instead of asking it
+	NonInteractive bool
+}
+
+// Select returns the prompter for the options, printing the questions to out. Without an option,
+// the questions are asked on in when it is a terminal, and fail otherwise, e.g. in CI, instead of
+// blocking or being silently canceled.
+func Select(options Options, in io.Reader, terminal bool, out io.Writer) (Prompter, error) {
+	switch {
+	case options.Yes && options.No:
+		return nil, fmt.Errorf("--yes and --no cannot be used together")
+	case options.Yes:
+		return NewFixed(true, out), nil
+	case options.No:
+		return NewFixed(false, out), nil
+	case options.NonInteractive || !terminal:
+		return NewNonInteractive(), nil
+	}
+	return NewConsole(in, out), nil
+}
+
+// Require asks the yes or no question unless force is set, and returns ErrCanceled when it is
+// answered with no
+func Require(prompter Prompter, question string, force bool) error {
+	if force {
+		return nil
+	}
+	answer, err := prompter.Confirm(question)
+	if err != nil {
+		return err
+	}
+	if !answer {
+		return ErrCanceled
+	}
+	return nil
+}
+
+// console asks the questions on a terminal
+type console struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+// NewConsole returns a prompter printing the questions to out and reading the answers from in
+func NewConsole(in io.Reader, out io.Writer) Prompter {
+	return &console{reader: bufio.NewReader(in), out: out}
+}
+
+// Confirm implements Prompter
+func (c *console) Confirm(question string) (bool, error) {
+	fmt.Fprintf(c.out, "%v[y/N]?", question)
+	return askForYesOrNot(c.reader, "y", "N"), nil
+}
+
+// ConfirmOrAll implements Prompter
+func (c *console) ConfirmOrAll(question string) (bool, bool, error) {
+	fmt.Fprintf(c.out, "%v[y/N/a]?", question)
+	answer, forAll := askForYesOrNotOrForAll(c.reader, "y", "a", "N")
+	return answer, forAll, nil
+}
+
+// fixed answers every question with the same answer, e.g. for --yes and --no
+type fixed struct {
+	answer bool
+	out    io.Writer
+}
+
+// NewFixed returns a prompter answering every question with the given answer.
+// The questions are printed to out together with the answer, so the log shows what was decided.
+func NewFixed(answer bool, out io.Writer) Prompter {
+	return &fixed{answer: answer, out: out}
+}
+
+// Confirm implements Prompter
+func (f *fixed) Confirm(question string) (bool, error) {
+	fmt.Fprintf(f.out, "%v[y/N]? %v\n", question, f.label())
+	return f.answer, nil
+}
+
+// ConfirmOrAll implements Prompter
+func (f *fixed) ConfirmOrAll(question string) (bool, bool, error) {
+	fmt.Fprintf(f.out, "%v[y/N/a]? %v\n", question, f.label())
+	return f.answer, true, nil
+}
+
+// label returns the printed answer
+func (f *fixed) label() string {
+	if f.answer {
+		return "y"
+	}
+	return "n"
+}
+
+// nonInteractive refuses every question
+type nonInteractive struct{}
+
+// NewNonInteractive returns a prompter failing with ErrNonInteractive for every question,
+// so nothing blocks or is silently canceled when no one can answer
+func NewNonInteractive() Prompter {
+	return nonInteractive{}
+}
+
+// Confirm implements Prompter
+func (nonInteractive) Confirm(question string) (bool, error) {
+	return false, fmt.Errorf("%w: %v", ErrNonInteractive, question)
+}
+
+// ConfirmOrAll implements Prompter
+func (nonInteractive) ConfirmOrAll(question string) (bool, bool, error) {
+	return false, false, fmt.Errorf("%w: %v", ErrNonInteractive, question)
+}
+
+// IsTerminal reports whether the file is a terminal, e.g. false for the standard input of a CI job,
+// which is usually a pipe, a file or the null device
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
@@ -0,0 +1,207 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConsole_Confirm(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "y\n", expected: true},
+		{input: " Y \n", expected: true},
+		{input: "n\n", expected: false},
+		{input: "\n", expected: false},
+		{input: "", expected: false},
+		{input: "yes\n", expected: false},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		answer, err := NewConsole(strings.NewReader(tt.input), &out).Confirm("Overwrite")
+		if err != nil {
+			t.Fatalf("input %q: unexpected error: %v", tt.input, err)
+		}
+		if answer != tt.expected {
+			t.Errorf("input %q: expected %v, got %v", tt.input, tt.expected, answer)
+		}
+		if out.String() != "Overwrite[y/N]?" {
+			t.Errorf("input %q: unexpected question %q", tt.input, out.String())
+		}
+	}
+}
+
+func TestConsole_ConfirmOrAll(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedAnswer bool
+		expectedForAll bool
+	}{
+		{input: "y\n", expectedAnswer: true, expectedForAll: false},
+		{input: "a\n", expectedAnswer: true, expectedForAll: true},
+		{input: "n\n", expectedAnswer: false, expectedForAll: false},
+		{input: "\n", expectedAnswer: false, expectedForAll: false},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		answer, forAll, err := NewConsole(strings.NewReader(tt.input), &out).ConfirmOrAll("Overwrite")
+		if err != nil {
+			t.Fatalf("input %q: unexpected error: %v", tt.input, err)
+		}
+		if answer != tt.expectedAnswer || forAll != tt.expectedForAll {
+			t.Errorf("input %q: expected %v/%v, got %v/%v", tt.input, tt.expectedAnswer, tt.expectedForAll, answer, forAll)
+		}
+		if out.String() != "Overwrite[y/N/a]?" {
+			t.Errorf("input %q: unexpected question %q", tt.input, out.String())
+		}
+	}
+
+	// Consecutive questions read consecutive lines of the same input
+	console := NewConsole(strings.NewReader("n\ny\n"), &strings.Builder{})
+	first, _ := console.Confirm("first")
+	second, _, _ := console.ConfirmOrAll("second")
+	if first || !second {
+		t.Errorf("expected no and yes, got %v and %v", first, second)
+	}
+}
+
+func TestFixed(t *testing.T) {
+	var out strings.Builder
+	yes := NewFixed(true, &out)
+	answer, err := yes.Confirm("Overwrite")
+	if err != nil || !answer {
+		t.Errorf("expected yes, got %v (%v)", answer, err)
+	}
+	answer, forAll, err := yes.ConfirmOrAll("Overwrite")
+	if err != nil || !answer || !forAll {
+		t.Errorf("expected yes for all, got %v/%v (%v)", answer, forAll, err)
+	}
+	if out.String() != "Overwrite[y/N]? y\nOverwrite[y/N/a]? y\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	answer, err = NewFixed(false, &out).Confirm("Overwrite")
+	if err != nil || answer {
+		t.Errorf("expected no, got %v (%v)", answer, err)
+	}
+	if out.String() != "Overwrite[y/N]? n\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestNonInteractive(t *testing.T) {
+	prompter := NewNonInteractive()
+	answer, err := prompter.Confirm("Overwrite")
+	if !errors.Is(err, ErrNonInteractive) || answer {
+		t.Errorf("expected ErrNonInteractive, got %v (%v)", answer, err)
+	}
+	if !strings.Contains(err.Error(), "Overwrite") {
+		t.Errorf("expected the question in the error, got %v", err)
+	}
+	answer, forAll, err := prompter.ConfirmOrAll("Overwrite")
+	if !errors.Is(err, ErrNonInteractive) || answer || forAll {
+		t.Errorf("expected ErrNonInteractive, got %v/%v (%v)", answer, forAll, err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  Options
+		terminal bool
+		// expected is the output of Confirm answered with y on the terminal, or its error
+		expected string
+	}{
+		{name: "terminal", terminal: true, expected: "Overwrite[y/N]? true"},
+		{name: "yes", options: Options{Yes: true}, expected: "Overwrite[y/N]? y\n true"},
+		{name: "no", options: Options{No: true}, terminal: true, expected: "Overwrite[y/N]? n\n false"},
+		{name: "non_interactive", options: Options{NonInteractive: true}, terminal: true, expected: ErrNonInteractive.Error()},
+		{name: "no_terminal", expected: ErrNonInteractive.Error()},
+		// An explicit answer wins over the non-interactive mode, so CI jobs can pass --yes
+		{name: "yes_non_interactive", options: Options{Yes: true, NonInteractive: true}, expected: "Overwrite[y/N]? y\n true"},
+		{name: "yes_and_no", options: Options{Yes: true, No: true}, terminal: true, expected: "--yes and --no cannot be used together"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			prompter, err := Select(tt.options, strings.NewReader("y\n"), tt.terminal, &out)
+			result := ""
+			if err == nil {
+				var answer bool
+				answer, err = prompter.Confirm("Overwrite")
+				result = fmt.Sprintf("%v %v", out.String(), answer)
+			}
+			if err != nil {
+				result = err.Error()
+			}
+			if !strings.HasPrefix(result, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompter Prompter
+		force    bool
+		expected error
+	}{
+		{name: "yes", prompter: NewConsole(strings.NewReader("y\n"), io.Discard)},
+		{name: "no", prompter: NewConsole(strings.NewReader("n\n"), io.Discard), expected: ErrCanceled},
+		{name: "yes_flag", prompter: NewFixed(true, io.Discard)},
+		{name: "no_flag", prompter: NewFixed(false, io.Discard), expected: ErrCanceled},
+		{name: "non_interactive", prompter: NewNonInteractive(), expected: ErrNonInteractive},
+		{name: "force", prompter: NewNonInteractive(), force: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Require(tt.prompter, "Overwrite", tt.force)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "input"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer file.Close()
+	if IsTerminal(file) {
+		t.Error("expected a regular file not to be a terminal")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if IsTerminal(r) {
+		t.Error("expected a pipe not to be a terminal")
+	}
+
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Failed to open %v: %v", os.DevNull, err)
+	}
+	defer null.Close()
+	if IsTerminal(null) {
+		t.Error("expected the null device not to be a terminal")
+	}
+}
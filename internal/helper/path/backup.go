@@ -2,6 +2,7 @@ package path
 
 import (
 	"fmt"
+	"github.com/spf13/afero"
 	"os"
 	"path/filepath"
 	"time"
@@ -11,7 +12,7 @@ import (
 // It tries base name with date first (filename-YYYYMMDD.ext), then adds incremental
 // numbers (filename-YYYYMMDD.1.ext, filename-YYYYMMDD.2.ext, etc.) until it finds
 // a name that doesn't exist.
-func CreateBackupFileName(originalPath string) (string, error) {
+func CreateBackupFileName(fs afero.Fs, originalPath string) (string, error) {
 	// Get current date in YYYYMMDD format
 	date := time.Now().Format("20060102")
 
@@ -21,7 +22,7 @@ func CreateBackupFileName(originalPath string) (string, error) {
 	baseBackupName := fmt.Sprintf("%s-%s%s", baseNameWithoutExt, date, ext)
 
 	// Check if base backup name is available
-	_, err := os.Stat(baseBackupName)
+	_, err := fs.Stat(baseBackupName)
 	if err != nil && os.IsNotExist(err) {
 		return baseBackupName, nil
 	}
@@ -30,7 +31,7 @@ func CreateBackupFileName(originalPath string) (string, error) {
 	i := 1
 	for {
 		candidateName := fmt.Sprintf("%s-%s.%d%s", baseNameWithoutExt, date, i, ext)
-		_, err := os.Stat(candidateName)
+		_, err := fs.Stat(candidateName)
 		if err != nil && os.IsNotExist(err) {
 			return candidateName, nil
 		}
@@ -41,13 +42,13 @@ func CreateBackupFileName(originalPath string) (string, error) {
 // BackupExistingFile creates a backup of the existing file with a date-based name.
 // If the target backup file already exists, it will try to create a new name
 // with an incremental number suffix.
-func BackupExistingFile(filePath string) error {
-	backupPath, err := CreateBackupFileName(filePath)
+func BackupExistingFile(fs afero.Fs, filePath string) error {
+	backupPath, err := CreateBackupFileName(fs, filePath)
 	if err != nil {
 		return fmt.Errorf("failed to generate backup file name: %v", err)
 	}
 
-	err = os.Rename(filePath, backupPath)
+	err = fs.Rename(filePath, backupPath)
 	if err != nil {
 		return fmt.Errorf("failed to rename file: %v", err)
 	}
@@ -59,7 +60,7 @@ func BackupExistingFile(filePath string) error {
 // It tries base name with date first (dirname-YYYYMMDD), then adds incremental
 // numbers (dirname-YYYYMMDD.1, dirname-YYYYMMDD.2, etc.) until it finds
 // a name that doesn't exist.
-func CreateBackupDirectoryName(originalPath string) (string, error) {
+func CreateBackupDirectoryName(fs afero.Fs, originalPath string) (string, error) {
 	// Get current date in YYYYMMDD format
 	date := time.Now().Format("20060102")
 
@@ -69,7 +70,7 @@ func CreateBackupDirectoryName(originalPath string) (string, error) {
 	baseBackupName := filepath.Join(parentDir, fmt.Sprintf("%s-%s", baseName, date))
 
 	// Check if base backup name is available
-	_, err := os.Stat(baseBackupName)
+	_, err := fs.Stat(baseBackupName)
 	if err != nil && os.IsNotExist(err) {
 		return baseBackupName, nil
 	}
@@ -78,7 +79,7 @@ func CreateBackupDirectoryName(originalPath string) (string, error) {
 	i := 1
 	for {
 		candidateName := filepath.Join(parentDir, fmt.Sprintf("%s-%s.%d", baseName, date, i))
-		_, err := os.Stat(candidateName)
+		_, err := fs.Stat(candidateName)
 		if err != nil && os.IsNotExist(err) {
 			return candidateName, nil
 		}
@@ -89,9 +90,9 @@ func CreateBackupDirectoryName(originalPath string) (string, error) {
 // BackupExistingDirectory creates a backup of the existing directory with a date-based name.
 // If the target backup directory already exists, it will try to create a new name
 // with an incremental number suffix.
-func BackupExistingDirectory(dirPath string) error {
+func BackupExistingDirectory(fs afero.Fs, dirPath string) error {
 	// Verify the path is a directory
-	info, err := os.Stat(dirPath)
+	info, err := fs.Stat(dirPath)
 	if err != nil {
 		return fmt.Errorf("failed to stat directory: %v", err)
 	}
@@ -99,12 +100,12 @@ func BackupExistingDirectory(dirPath string) error {
 		return fmt.Errorf("path is not a directory: %s", dirPath)
 	}
 
-	backupPath, err := CreateBackupDirectoryName(dirPath)
+	backupPath, err := CreateBackupDirectoryName(fs, dirPath)
 	if err != nil {
 		return fmt.Errorf("failed to generate backup directory name: %v", err)
 	}
 
-	err = os.Rename(dirPath, backupPath)
+	err = fs.Rename(dirPath, backupPath)
 	if err != nil {
 		return fmt.Errorf("failed to rename directory: %v", err)
 	}
@@ -2,7 +2,7 @@ package path
 
 import (
 	"fmt"
-	"os"
+	"github.com/spf13/afero"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -15,8 +15,8 @@ import (
 // - Properly preserves file extensions
 // - Returns paths that don't conflict with existing files
 func TestCreateBackupFileName(t *testing.T) {
-	// Create temporary directory for test files
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	// Test cases cover different scenarios for backup file naming
 	tests := []struct {
@@ -63,20 +63,20 @@ func TestCreateBackupFileName(t *testing.T) {
 			originalPath := filepath.Join(tempDir, tt.originalName)
 
 			// Create a dummy original file
-			if err := os.WriteFile(originalPath, []byte("test content"), 0644); err != nil {
+			if err := afero.WriteFile(fs, originalPath, []byte("test content"), 0644); err != nil {
 				t.Fatalf("Failed to create test file: %v", err)
 			}
 
 			// Create all setup files
 			for _, fileName := range tt.setupFiles {
 				filePath := filepath.Join(tempDir, fileName)
-				if err := os.WriteFile(filePath, []byte("existing backup"), 0644); err != nil {
+				if err := afero.WriteFile(fs, filePath, []byte("existing backup"), 0644); err != nil {
 					t.Fatalf("Failed to create setup file %s: %v", fileName, err)
 				}
 			}
 
 			// Call the function under test
-			result, err := CreateBackupFileName(originalPath)
+			result, err := CreateBackupFileName(fs, originalPath)
 			if err != nil {
 				t.Fatalf("createBackupFileName failed: %v", err)
 			}
@@ -87,7 +87,7 @@ func TestCreateBackupFileName(t *testing.T) {
 			}
 
 			// Verify the generated name doesn't exist yet
-			if _, err := os.Stat(result); err == nil {
+			if _, err := fs.Stat(result); err == nil {
 				t.Error("Generated backup file name already exists")
 			}
 
@@ -106,7 +106,8 @@ func TestCreateBackupFileName(t *testing.T) {
 // - Handles errors appropriately
 // - Preserves file contents during rename
 func TestBackupExistingFile(t *testing.T) {
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	tests := []struct {
 		name                string
@@ -143,20 +144,20 @@ func TestBackupExistingFile(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create original file to backup
 			originalPath := filepath.Join(tempDir, "test.txt")
-			if err := os.WriteFile(originalPath, []byte("test content"), 0644); err != nil {
+			if err := afero.WriteFile(fs, originalPath, []byte("test content"), 0644); err != nil {
 				t.Fatalf("Failed to create test file: %v", err)
 			}
 
 			// Create setup files
 			for _, fileName := range tt.setupFiles {
 				filePath := filepath.Join(tempDir, fileName)
-				if err := os.WriteFile(filePath, []byte("existing backup"), 0644); err != nil {
+				if err := afero.WriteFile(fs, filePath, []byte("existing backup"), 0644); err != nil {
 					t.Fatalf("Failed to create setup file %s: %v", fileName, err)
 				}
 			}
 
 			// Perform backup
-			err := BackupExistingFile(originalPath)
+			err := BackupExistingFile(fs, originalPath)
 
 			// Check error expectation
 			if (err != nil) != tt.expectedError {
@@ -165,13 +166,13 @@ func TestBackupExistingFile(t *testing.T) {
 
 			if err == nil {
 				// Verify original file doesn't exist anymore
-				if _, err := os.Stat(originalPath); err == nil {
+				if _, err := fs.Stat(originalPath); err == nil {
 					t.Error("Original file still exists after backup")
 				}
 
 				// Verify backup files count
 				pattern := filepath.Join(tempDir, fmt.Sprintf("test-%s*", time.Now().Format("20060102")))
-				matches, err := filepath.Glob(pattern)
+				matches, err := afero.Glob(fs, pattern)
 				if err != nil {
 					t.Fatalf("Failed to find backup files: %v", err)
 				}
@@ -189,7 +190,8 @@ func TestBackupExistingFile(t *testing.T) {
 // - Handles existing directories by adding incremental numbers
 // - Returns paths that don't conflict with existing directories
 func TestCreateBackupDirectoryName(t *testing.T) {
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	tests := []struct {
 		name           string
@@ -226,26 +228,26 @@ func TestCreateBackupDirectoryName(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			testDir := filepath.Join(tempDir, t.Name())
-			if err := os.MkdirAll(testDir, 0755); err != nil {
+			if err := fs.MkdirAll(testDir, 0755); err != nil {
 				t.Fatalf("Failed to create test directory structure: %v", err)
 			}
 
 			// Create the original directory path
 			originalPath := filepath.Join(testDir, tt.originalName)
-			if err := os.Mkdir(originalPath, 0755); err != nil {
+			if err := fs.Mkdir(originalPath, 0755); err != nil {
 				t.Fatalf("Failed to create test directory: %v", err)
 			}
 
 			// Create all setup directories
 			for _, dirName := range tt.setupDirs {
 				dirPath := filepath.Join(testDir, dirName)
-				if err := os.Mkdir(dirPath, 0755); err != nil {
+				if err := fs.Mkdir(dirPath, 0755); err != nil {
 					t.Fatalf("Failed to create setup directory %s: %v", dirName, err)
 				}
 			}
 
 			// Call the function under test
-			result, err := CreateBackupDirectoryName(originalPath)
+			result, err := CreateBackupDirectoryName(fs, originalPath)
 			if err != nil {
 				t.Fatalf("CreateBackupDirectoryName failed: %v", err)
 			}
@@ -256,7 +258,7 @@ func TestCreateBackupDirectoryName(t *testing.T) {
 			}
 
 			// Verify the generated name doesn't exist yet
-			if _, err := os.Stat(result); err == nil {
+			if _, err := fs.Stat(result); err == nil {
 				t.Error("Generated backup directory name already exists")
 			}
 		})
@@ -268,7 +270,8 @@ func TestCreateBackupDirectoryName(t *testing.T) {
 // - Generates correct backup names
 // - Handles errors appropriately
 func TestBackupExistingDirectory(t *testing.T) {
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	tests := []struct {
 		name                string
@@ -304,26 +307,26 @@ func TestBackupExistingDirectory(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			testDir := filepath.Join(tempDir, t.Name())
-			if err := os.MkdirAll(testDir, 0755); err != nil {
+			if err := fs.MkdirAll(testDir, 0755); err != nil {
 				t.Fatalf("Failed to create test directory structure: %v", err)
 			}
 
 			// Create setup directories
 			for _, dirName := range tt.setupDirs {
 				dirPath := filepath.Join(testDir, dirName)
-				if err := os.Mkdir(dirPath, 0755); err != nil {
+				if err := fs.Mkdir(dirPath, 0755); err != nil {
 					t.Fatalf("Failed to create setup directory %s: %v", dirName, err)
 				}
 			}
 
 			// Create original directory to backup
 			originalPath := filepath.Join(testDir, "testdir")
-			if err := os.Mkdir(originalPath, 0755); err != nil {
+			if err := fs.Mkdir(originalPath, 0755); err != nil {
 				t.Fatalf("Failed to create test directory: %v", err)
 			}
 
 			// Perform backup
-			err := BackupExistingDirectory(originalPath)
+			err := BackupExistingDirectory(fs, originalPath)
 
 			// Check error expectation
 			if (err != nil) != tt.expectedError {
@@ -332,13 +335,13 @@ func TestBackupExistingDirectory(t *testing.T) {
 
 			if err == nil {
 				// Verify original directory doesn't exist anymore
-				if _, err := os.Stat(originalPath); err == nil {
+				if _, err := fs.Stat(originalPath); err == nil {
 					t.Error("Original directory still exists after backup")
 				}
 
 				// Verify backup directories count
 				pattern := filepath.Join(testDir, fmt.Sprintf("testdir-%s*", time.Now().Format("20060102")))
-				matches, err := filepath.Glob(pattern)
+				matches, err := afero.Glob(fs, pattern)
 				if err != nil {
 					t.Fatalf("Failed to find backup directories: %v", err)
 				}
@@ -17,19 +17,21 @@ limitations under the License.
 package path
 
 import (
+	"github.com/spf13/afero"
 	"os"
 )
 
 // IsExist checks if the given path (file or directory) exists in the file system.
 //
 // Parameters:
+//   - fs: The file system to check in.
 //   - path: The path to the file or directory to check.
 //
 // Returns:
 //   - bool: A boolean indicating whether the path exists (true) or not (false).
 //   - error: An error that will be non-nil in case of failures.
-func IsExist(path string) (bool, error) {
-	if _, err := os.Stat(path); err != nil {
+func IsExist(fs afero.Fs, path string) (bool, error) {
+	if _, err := fs.Stat(path); err != nil {
 		if os.IsNotExist(err) {
 			return false, nil
 		}
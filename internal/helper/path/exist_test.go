@@ -1,7 +1,7 @@
 package path
 
 import (
-	"os"
+	"github.com/spf13/afero"
 	"path/filepath"
 	"testing"
 )
@@ -9,8 +9,8 @@ import (
 // TestIsExist verifies the functionality of the IsExist function by testing various scenarios
 // including existing files, non-existing files, and different error conditions.
 func TestIsExist(t *testing.T) {
-	// Create a temporary directory for test files
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	// Test cases represents different scenarios we want to verify
 	tests := []struct {
@@ -26,7 +26,7 @@ func TestIsExist(t *testing.T) {
 			setupFunc: func() {
 				// Create a test file
 				filePath := filepath.Join(tempDir, "test.txt")
-				if err := os.WriteFile(filePath, []byte("test content"), 0644); err != nil {
+				if err := afero.WriteFile(fs, filePath, []byte("test content"), 0644); err != nil {
 					t.Fatal(err)
 				}
 			},
@@ -48,7 +48,7 @@ func TestIsExist(t *testing.T) {
 			setupFunc: func() {
 				// Create a test directory
 				dirPath := filepath.Join(tempDir, "testdir")
-				if err := os.Mkdir(dirPath, 0755); err != nil {
+				if err := fs.Mkdir(dirPath, 0755); err != nil {
 					t.Fatal(err)
 				}
 			},
@@ -62,7 +62,7 @@ func TestIsExist(t *testing.T) {
 			setupFunc: func() {
 				// Create a file with no read permissions
 				filePath := filepath.Join(tempDir, "noperm.txt")
-				if err := os.WriteFile(filePath, []byte("test content"), 0000); err != nil {
+				if err := afero.WriteFile(fs, filePath, []byte("test content"), 0000); err != nil {
 					t.Fatal(err)
 				}
 			},
@@ -71,7 +71,7 @@ func TestIsExist(t *testing.T) {
 			expectedError: false,
 			cleanupFunc: func() {
 				// Restore permissions to allow cleanup
-				err := os.Chmod(filepath.Join(tempDir, "noperm.txt"), 0644)
+				err := fs.Chmod(filepath.Join(tempDir, "noperm.txt"), 0644)
 				if err != nil {
 					return
 				}
@@ -89,7 +89,7 @@ func TestIsExist(t *testing.T) {
 			defer tt.cleanupFunc()
 
 			// Call the function being tested
-			exists, err := IsExist(tt.path)
+			exists, err := IsExist(fs, tt.path)
 
 			// Verify error expectation
 			if (err != nil) != tt.expectedError {
@@ -0,0 +1,165 @@
+package path
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockFileName is the name of the lock file created in the project directory
+const LockFileName = ".dcm.lock"
+
+// lockPollInterval is the delay between two attempts to acquire a held lock
+const lockPollInterval = 100 * time.Millisecond
+
+// Lock is an exclusive, file-based lock of a project directory.
+// It prevents concurrent invocations from racing on backup names and interleaving writes.
+// While held, the lock file is touched regularly, so a lock held across slow interactive
+// questions is never mistaken for an abandoned one.
+type Lock struct {
+	mu      sync.Mutex
+	fs      afero.Fs
+	path    string
+	content []byte
+	done    chan struct{}
+}
+
+// AcquireLock creates the lock file in the given directory, waiting up to 'wait' while another
+// process holds it. A lock file older than 'stale' is considered abandoned and is taken over, so
+// 'stale' must be positive: otherwise every lock would be abandoned and taken over right away.
+func AcquireLock(fs afero.Fs, dir string, wait, stale time.Duration) (*Lock, error) {
+	if stale <= 0 {
+		return nil, fmt.Errorf("the age after which a lock is abandoned must be positive, got %v", stale)
+	}
+	lockPath := filepath.Join(dir, LockFileName)
+	deadline := time.Now().Add(wait)
+
+	for {
+		file, err := fs.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			hostname, _ := os.Hostname()
+			content := []byte(fmt.Sprintf("pid: %d\nhost: %s\ntime: %s\n", os.Getpid(), hostname, time.Now().Format(time.RFC3339Nano)))
+			_, writeErr := file.Write(content)
+			closeErr := file.Close()
+			if err := errors.Join(writeErr, closeErr); err != nil {
+				_ = fs.Remove(lockPath)
+				return nil, fmt.Errorf("failed to write lock file: %v", err)
+			}
+			lock := &Lock{fs: fs, path: lockPath, content: content, done: make(chan struct{})}
+			go lock.refresh(stale / 3)
+			return lock, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %v", err)
+		}
+
+		info, err := fs.Stat(lockPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Released in the meantime, try again right away
+				continue
+			}
+			return nil, fmt.Errorf("failed to stat lock file: %v", err)
+		}
+		if time.Since(info.ModTime()) > stale {
+			takeOver(fs, lockPath, stale)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("project '%v' is locked by another dcm process (%v); remove '%v' if no other dcm is running",
+				dir, describeLock(fs, lockPath), lockPath)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// takeOver removes an abandoned lock file. The file is renamed first, so only one process removes
+// it. Another process may have taken over the same abandoned lock and created a fresh one between
+// the stat of the caller and the rename, so the renamed file is checked again: a fresh lock is put
+// back instead of being removed.
+func takeOver(fs afero.Fs, lockPath string, stale time.Duration) {
+	abandoned := fmt.Sprintf("%s.%d.stale", lockPath, os.Getpid())
+	if err := fs.Rename(lockPath, abandoned); err != nil {
+		return
+	}
+	defer fs.Remove(abandoned)
+
+	info, err := fs.Stat(abandoned)
+	if err != nil || time.Since(info.ModTime()) > stale {
+		return
+	}
+	content, err := afero.ReadFile(fs, abandoned)
+	if err != nil {
+		return
+	}
+	file, err := fs.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	_, _ = file.Write(content)
+	_ = file.Close()
+}
+
+// refresh touches the lock file at the given interval until the lock is released
+func (l *Lock) refresh(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.path != "" && l.owned() {
+				now := time.Now()
+				_ = l.fs.Chtimes(l.path, now, now)
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// owned reports whether the lock file still is the one created by this lock
+func (l *Lock) owned() bool {
+	content, err := afero.ReadFile(l.fs, l.path)
+	return err == nil && bytes.Equal(content, l.content)
+}
+
+// Release removes the lock file. Releasing an already released lock is a no-op, and a lock
+// file that no longer belongs to this lock is left alone.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" {
+		return nil
+	}
+	close(l.done)
+	var err error
+	if l.owned() {
+		err = l.fs.Remove(l.path)
+	}
+	l.path = ""
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file: %v", err)
+	}
+	return nil
+}
+
+// describeLock returns the owner information stored in the lock file on a single line
+func describeLock(fs afero.Fs, lockPath string) string {
+	content, err := afero.ReadFile(fs, lockPath)
+	if err != nil {
+		return "owner unknown"
+	}
+	return strings.ReplaceAll(strings.TrimSpace(string(content)), "\n", ", ")
+}
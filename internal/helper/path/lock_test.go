@@ -0,0 +1,209 @@
+package path
+
+import (
+	"github.com/spf13/afero"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAcquireLock_Exclusive verifies that a held lock cannot be acquired again until it is released
+func TestAcquireLock_Exclusive(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
+
+	lock, err := AcquireLock(fs, tempDir, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	if exists, _ := IsExist(fs, filepath.Join(tempDir, LockFileName)); !exists {
+		t.Fatal("Lock file was not created")
+	}
+
+	_, err = AcquireLock(fs, tempDir, 200*time.Millisecond, time.Minute)
+	if err == nil {
+		t.Fatal("Expected second AcquireLock to fail while the lock is held")
+	}
+	if !strings.Contains(err.Error(), "pid:") {
+		t.Errorf("Expected error to describe the lock owner, got: %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("Second Release must be a no-op, got: %v", err)
+	}
+
+	lock, err = AcquireLock(fs, tempDir, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock after release failed: %v", err)
+	}
+	_ = lock.Release()
+}
+
+// TestAcquireLock_NonPositiveStale verifies that a lock is never acquired without a positive stale age,
+// which would take over the lock of any other process
+func TestAcquireLock_NonPositiveStale(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
+
+	lock, err := AcquireLock(fs, tempDir, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	defer lock.Release()
+
+	for _, stale := range []time.Duration{0, -time.Second} {
+		if _, err := AcquireLock(fs, tempDir, 0, stale); err == nil {
+			t.Errorf("Expected AcquireLock with stale age %v to fail", stale)
+		}
+	}
+	if !lock.owned() {
+		t.Error("The held lock was taken over")
+	}
+}
+
+// TestAcquireLock_Stale verifies that an abandoned lock is taken over
+func TestAcquireLock_Stale(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
+	lockPath := filepath.Join(tempDir, LockFileName)
+
+	if err := afero.WriteFile(fs, lockPath, []byte("pid: 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := fs.Chtimes(lockPath, old, old); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+
+	lock, err := AcquireLock(fs, tempDir, 0, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected stale lock to be taken over, got: %v", err)
+	}
+	defer lock.Release()
+
+	content, err := afero.ReadFile(fs, lockPath)
+	if err != nil {
+		t.Fatalf("Failed to read lock file: %v", err)
+	}
+	if strings.Contains(string(content), "pid: 1\n") {
+		t.Error("Lock file still belongs to the abandoned owner")
+	}
+}
+
+// TestAcquireLock_Concurrent verifies that concurrent holders never overlap
+func TestAcquireLock_Concurrent(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
+
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := AcquireLock(fs, tempDir, 10*time.Second, time.Minute)
+			if err != nil {
+				t.Errorf("AcquireLock failed: %v", err)
+				return
+			}
+
+			mu.Lock()
+			holders++
+			if holders > maxHolders {
+				maxHolders = holders
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+
+			if err := lock.Release(); err != nil {
+				t.Errorf("Release failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Errorf("Expected exactly one holder at a time, got %d", maxHolders)
+	}
+}
+
+// TestAcquireLock_Refresh verifies that a lock held longer than the stale timeout is not taken over
+func TestAcquireLock_Refresh(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	stale := 300 * time.Millisecond
+
+	lock, err := AcquireLock(fs, "/project", 0, stale)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	defer lock.Release()
+
+	// Held across a slow question
+	time.Sleep(2 * stale)
+	if _, err := AcquireLock(fs, "/project", 100*time.Millisecond, stale); err == nil {
+		t.Fatal("Expected a refreshed lock not to be taken over")
+	}
+}
+
+// TestTakeOver_Fresh verifies that a fresh lock renamed by a process that saw the previous,
+// abandoned lock is put back instead of being removed
+func TestTakeOver_Fresh(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	lockPath := filepath.Join("/project", LockFileName)
+
+	// Process A took over the abandoned lock, process B stat'ed the abandoned one before
+	lock, err := AcquireLock(fs, "/project", 0, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	takeOver(fs, lockPath, time.Minute)
+
+	content, err := afero.ReadFile(fs, lockPath)
+	if err != nil {
+		t.Fatalf("Fresh lock file was removed: %v", err)
+	}
+	if string(content) != string(lock.content) {
+		t.Errorf("Expected the fresh lock to be restored, got %q", content)
+	}
+	if _, err := AcquireLock(fs, "/project", 0, time.Minute); err == nil {
+		t.Fatal("Expected the restored lock to be held")
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if exists, _ := afero.Exists(fs, lockPath); exists {
+		t.Error("Expected the restored lock to be released by its owner")
+	}
+}
+
+// TestLock_ReleaseForeign verifies that releasing a lock does not remove a lock file of another owner
+func TestLock_ReleaseForeign(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	lockPath := filepath.Join("/project", LockFileName)
+
+	lock, err := AcquireLock(fs, "/project", 0, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	if err := afero.WriteFile(fs, lockPath, []byte("pid: 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to replace lock file: %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if exists, _ := afero.Exists(fs, lockPath); !exists {
+		t.Error("Lock file of another owner was removed")
+	}
+}
@@ -0,0 +1,301 @@
+package path
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// StagingDirectoryPrefix is the name prefix of the temporary directories used by transactions
+const StagingDirectoryPrefix = ".dcm-tx-"
+
+// Transaction stages file writes in a temporary directory and commits them with atomic renames.
+// Existing files replaced by the transaction are kept aside until the commit succeeds, so any
+// failure during the commit restores the previous state of the project.
+type Transaction struct {
+	fs         afero.Fs
+	stagingDir string
+	staged     []stagedFile
+	backups    []string
+	removed    []string
+	keepBackup bool
+	backedUp   []string
+	applied    []appliedOperation
+	finished   bool
+}
+
+// stagedFile is a file written to the staging directory waiting to be moved to its target
+type stagedFile struct {
+	target  string
+	staging string
+}
+
+// appliedOperation records a single step of the commit so that it can be reverted
+type appliedOperation struct {
+	kind operationKind
+	from string
+	to   string
+}
+
+type operationKind int
+
+const (
+	// operationRename moved 'from' to 'to', reverted by moving it back
+	operationRename operationKind = iota
+	// operationCreateDirectory created the directory 'to', reverted by removing it
+	operationCreateDirectory
+)
+
+// NewTransaction creates a new transaction operating on the given file system with its staging
+// directory inside baseDir. The staging directory must be on the same device as the targets for
+// the renames to be atomic.
+func NewTransaction(fs afero.Fs, baseDir string) (*Transaction, error) {
+	stagingDir, err := afero.TempDir(fs, baseDir, StagingDirectoryPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return &Transaction{fs: fs, stagingDir: stagingDir}, nil
+}
+
+// WriteFile stages the content of the target file. The target is not touched until Commit is called.
+func (t *Transaction) WriteFile(target string, data []byte, perm os.FileMode) error {
+	if t.finished {
+		return fmt.Errorf("transaction already finished")
+	}
+
+	staging := filepath.Join(t.stagingDir, "new", strconv.Itoa(len(t.staged)))
+	if err := t.fs.MkdirAll(filepath.Dir(staging), 0755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := afero.WriteFile(t.fs, staging, data, perm); err != nil {
+		return fmt.Errorf("failed to stage file %s: %w", target, err)
+	}
+
+	// A later write of the same target replaces the earlier one
+	for i := range t.staged {
+		if t.staged[i].target == target {
+			t.staged[i].staging = staging
+			return nil
+		}
+	}
+	t.staged = append(t.staged, stagedFile{target: target, staging: staging})
+	return nil
+}
+
+// Backup schedules a date-based backup of the target file or directory. The backup is taken
+// at commit time, before any staged file is moved into place, and only if the target exists.
+func (t *Transaction) Backup(target string) {
+	t.backups = append(t.backups, target)
+}
+
+// SetBackup enables date-based backups of every file the commit replaces or removes. The backups are
+// kept next to the files and named like the ones of BackupExistingFile.
+func (t *Transaction) SetBackup(enabled bool) {
+	t.keepBackup = enabled
+}
+
+// Remove schedules the removal of the target file. The file is kept aside until the commit
+// succeeds, so it is restored if the transaction fails.
+func (t *Transaction) Remove(target string) {
+	t.removed = append(t.removed, target)
+}
+
+// Files returns the targets of all staged files in the order they were staged
+func (t *Transaction) Files() []string {
+	files := make([]string, 0, len(t.staged))
+	for _, file := range t.staged {
+		files = append(files, file.target)
+	}
+	return files
+}
+
+// Backups returns the backups taken by the commit in the order they were taken
+func (t *Transaction) Backups() []string {
+	return t.backedUp
+}
+
+// Commit takes the scheduled backups, removes the scheduled files and moves all staged files into place.
+// If any step fails, all previous steps are reverted and the error is returned.
+func (t *Transaction) Commit() error {
+	if t.finished {
+		return fmt.Errorf("transaction already finished")
+	}
+
+	if err := t.apply(); err != nil {
+		if rollbackErr := t.revert(); rollbackErr != nil {
+			err = errors.Join(err, fmt.Errorf("rollback failed: %w", rollbackErr))
+		}
+		t.cleanup()
+		return err
+	}
+
+	t.cleanup()
+	return nil
+}
+
+// Rollback discards all staged files. It is a no-op after a successful commit,
+// which makes it safe to defer right after creating the transaction.
+func (t *Transaction) Rollback() {
+	if !t.finished {
+		t.cleanup()
+	}
+}
+
+// apply performs the commit steps, recording each of them
+func (t *Transaction) apply() error {
+	for _, target := range t.backups {
+		info, err := t.fs.Stat(target)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to stat %s: %w", target, err)
+		}
+
+		var backupPath string
+		if info.IsDir() {
+			backupPath, err = CreateBackupDirectoryName(t.fs, target)
+		} else {
+			backupPath, err = CreateBackupFileName(t.fs, target)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to generate backup name for %s: %w", target, err)
+		}
+		if err := t.rename(target, backupPath); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", target, err)
+		}
+		t.backedUp = append(t.backedUp, backupPath)
+	}
+
+	for i, target := range t.removed {
+		if _, err := lstat(t.fs, target); err != nil {
+			continue
+		}
+		if t.keepBackup {
+			if err := t.backupFile(target); err != nil {
+				return err
+			}
+			continue
+		}
+		removed := filepath.Join(t.stagingDir, "removed", strconv.Itoa(i))
+		if err := t.fs.MkdirAll(filepath.Dir(removed), 0755); err != nil {
+			return fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		if err := t.rename(target, removed); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+	}
+
+	for i, file := range t.staged {
+		if err := t.createParents(filepath.Dir(file.target)); err != nil {
+			return err
+		}
+
+		// Keep the replaced file aside so that it can be restored, or as its backup
+		if _, err := lstat(t.fs, file.target); err == nil {
+			if t.keepBackup {
+				if err := t.backupFile(file.target); err != nil {
+					return err
+				}
+			} else {
+				replaced := filepath.Join(t.stagingDir, "old", strconv.Itoa(i))
+				if err := t.fs.MkdirAll(filepath.Dir(replaced), 0755); err != nil {
+					return fmt.Errorf("failed to create staging directory: %w", err)
+				}
+				if err := t.rename(file.target, replaced); err != nil {
+					return fmt.Errorf("failed to replace %s: %w", file.target, err)
+				}
+			}
+		}
+
+		if err := t.rename(file.staging, file.target); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.target, err)
+		}
+	}
+
+	return nil
+}
+
+// backupFile moves the file to its date-based backup and records the operation
+func (t *Transaction) backupFile(target string) error {
+	backupPath, err := CreateBackupFileName(t.fs, target)
+	if err != nil {
+		return fmt.Errorf("failed to generate backup name for %s: %w", target, err)
+	}
+	if err := t.rename(target, backupPath); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", target, err)
+	}
+	t.backedUp = append(t.backedUp, backupPath)
+	return nil
+}
+
+// rename moves a file or directory and records the operation
+func (t *Transaction) rename(from, to string) error {
+	if err := t.fs.Rename(from, to); err != nil {
+		return err
+	}
+	t.applied = append(t.applied, appliedOperation{kind: operationRename, from: from, to: to})
+	return nil
+}
+
+// createParents creates the missing directories of the given path and records each of them
+func (t *Transaction) createParents(dir string) error {
+	var missing []string
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := t.fs.Stat(current); err == nil {
+			break
+		}
+		missing = append(missing, current)
+		if parent := filepath.Dir(current); parent == current {
+			break
+		}
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := t.fs.Mkdir(missing[i], 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", missing[i], err)
+		}
+		t.applied = append(t.applied, appliedOperation{kind: operationCreateDirectory, to: missing[i]})
+	}
+	return nil
+}
+
+// revert undoes the recorded operations in reverse order
+func (t *Transaction) revert() error {
+	var errs []error
+	for i := len(t.applied) - 1; i >= 0; i-- {
+		operation := t.applied[i]
+		switch operation.kind {
+		case operationRename:
+			if err := t.fs.Rename(operation.to, operation.from); err != nil {
+				errs = append(errs, err)
+			}
+		case operationCreateDirectory:
+			if err := t.fs.Remove(operation.to); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	t.applied = nil
+	t.backedUp = nil
+	return errors.Join(errs...)
+}
+
+// cleanup removes the staging directory and marks the transaction as finished
+func (t *Transaction) cleanup() {
+	t.finished = true
+	_ = t.fs.RemoveAll(t.stagingDir)
+}
+
+// lstat returns the file info of the name without following a final symbolic link
+// when the file system supports it
+func lstat(fs afero.Fs, name string) (os.FileInfo, error) {
+	if lstater, ok := fs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(name)
+		return info, err
+	}
+	return fs.Stat(name)
+}
@@ -0,0 +1,234 @@
+package path
+
+import (
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readFile is a test helper returning the content of the file or failing the test
+func readFile(t *testing.T, fs afero.Fs, filePath string) string {
+	t.Helper()
+	content, err := afero.ReadFile(fs, filePath)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", filePath, err)
+	}
+	return string(content)
+}
+
+// assertNoStagingDirectory verifies that no staging directory was left behind
+func assertNoStagingDirectory(t *testing.T, fs afero.Fs, dir string) {
+	t.Helper()
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), StagingDirectoryPrefix) {
+			t.Errorf("Staging directory %s was not removed", entry.Name())
+		}
+	}
+}
+
+// TestTransaction_Commit verifies that staged files are written only on commit,
+// that backups are taken and that missing directories are created
+func TestTransaction_Commit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
+	composePath := filepath.Join(tempDir, "docker-compose.yml")
+	servicePath := filepath.Join(tempDir, "services", "app.yml")
+
+	if err := afero.WriteFile(fs, composePath, []byte("old content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tx, err := NewTransaction(fs, tempDir)
+	if err != nil {
+		t.Fatalf("NewTransaction failed: %v", err)
+	}
+	defer tx.Rollback()
+
+	tx.Backup(composePath)
+	if err := tx.WriteFile(composePath, []byte("new content"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := tx.WriteFile(servicePath, []byte("app:"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// Nothing is written before the commit
+	if content := readFile(t, fs, composePath); content != "old content" {
+		t.Errorf("Target modified before commit: %q", content)
+	}
+	if exists, _ := IsExist(fs, servicePath); exists {
+		t.Error("Service file created before commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if content := readFile(t, fs, composePath); content != "new content" {
+		t.Errorf("Expected new content, got %q", content)
+	}
+	if content := readFile(t, fs, servicePath); content != "app:" {
+		t.Errorf("Expected service content, got %q", content)
+	}
+
+	backupPath, err := CreateBackupFileName(fs, composePath)
+	if err != nil {
+		t.Fatalf("CreateBackupFileNameFs failed: %v", err)
+	}
+	// The first free backup name must be the next one, so the commit used the base name
+	if !strings.Contains(backupPath, ".1.yml") {
+		t.Errorf("Expected backup to be taken, next free name is %s", backupPath)
+	}
+
+	assertNoStagingDirectory(t, fs, tempDir)
+}
+
+// TestTransaction_Rollback verifies that a rolled back transaction leaves the project untouched
+func TestTransaction_Rollback(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
+	targetPath := filepath.Join(tempDir, "docker-compose.yml")
+
+	tx, err := NewTransaction(fs, tempDir)
+	if err != nil {
+		t.Fatalf("NewTransaction failed: %v", err)
+	}
+	if err := tx.WriteFile(targetPath, []byte("content"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	tx.Rollback()
+
+	if exists, _ := IsExist(fs, targetPath); exists {
+		t.Error("Rolled back file must not exist")
+	}
+	if err := tx.Commit(); err == nil {
+		t.Error("Commit after rollback must fail")
+	}
+	assertNoStagingDirectory(t, fs, tempDir)
+}
+
+// TestTransaction_SetBackup verifies that replaced and removed files are backed up next to themselves
+func TestTransaction_SetBackup(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
+	templatePath := filepath.Join(tempDir, "docker-compose-dcm.yml")
+	appPath := filepath.Join(tempDir, "services", "app.yml")
+	dbPath := filepath.Join(tempDir, "services", "db.yml")
+	for filePath, content := range map[string]string{templatePath: "template", appPath: "app", dbPath: "db"} {
+		if err := afero.WriteFile(fs, filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	templateBackup, _ := CreateBackupFileName(fs, templatePath)
+	appBackup, _ := CreateBackupFileName(fs, appPath)
+	dbBackup, _ := CreateBackupFileName(fs, dbPath)
+
+	tx, err := NewTransaction(fs, tempDir)
+	if err != nil {
+		t.Fatalf("NewTransaction failed: %v", err)
+	}
+	defer tx.Rollback()
+	tx.SetBackup(true)
+
+	if err := tx.WriteFile(templatePath, []byte("new template"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := tx.WriteFile(appPath, []byte("new app"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	tx.Remove(dbPath)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	backups := tx.Backups()
+	expected := []string{dbBackup, templateBackup, appBackup}
+	if strings.Join(backups, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected backups %v, got %v", expected, backups)
+	}
+	for backupPath, content := range map[string]string{templateBackup: "template", appBackup: "app", dbBackup: "db"} {
+		if actual := readFile(t, fs, backupPath); actual != content {
+			t.Errorf("Expected backup %s to hold %q, got %q", backupPath, content, actual)
+		}
+	}
+	if exists, _ := IsExist(fs, dbPath); exists {
+		t.Error("Removed file must not exist")
+	}
+	assertNoStagingDirectory(t, fs, tempDir)
+}
+
+// failingFs fails every rename to the target, e.g. to simulate a full disk during a commit
+type failingFs struct {
+	afero.Fs
+	target string
+}
+
+// Rename implements afero.Fs
+func (f failingFs) Rename(oldname, newname string) error {
+	if newname == f.target {
+		return os.ErrPermission
+	}
+	return f.Fs.Rename(oldname, newname)
+}
+
+// TestTransaction_CommitFailureRestoresState verifies that a failure in the middle of a commit
+// reverts the backups and the files already moved into place
+func TestTransaction_CommitFailureRestoresState(t *testing.T) {
+	tempDir := "/project"
+	templatePath := filepath.Join(tempDir, "docker-compose-dcm.yml")
+	servicesDir := filepath.Join(tempDir, "services")
+	failing := filepath.Join(tempDir, "failing.yml")
+	fs := failingFs{Fs: afero.NewMemMapFs(), target: failing}
+
+	if err := afero.WriteFile(fs, templatePath, []byte("old template"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := afero.WriteFile(fs, filepath.Join(servicesDir, "app.yml"), []byte("old app"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tx, err := NewTransaction(fs, tempDir)
+	if err != nil {
+		t.Fatalf("NewTransaction failed: %v", err)
+	}
+	tx.Backup(servicesDir)
+	if err := tx.WriteFile(templatePath, []byte("new template"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := tx.WriteFile(filepath.Join(servicesDir, "app.yml"), []byte("new app"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	// The last write fails
+	if err := tx.WriteFile(failing, []byte("x"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Expected commit to fail")
+	}
+
+	if content := readFile(t, fs, templatePath); content != "old template" {
+		t.Errorf("Template not restored, got %q", content)
+	}
+	if content := readFile(t, fs, filepath.Join(servicesDir, "app.yml")); content != "old app" {
+		t.Errorf("Services directory not restored, got %q", content)
+	}
+
+	entries, err := afero.ReadDir(fs, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 2 {
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected only the original entries, got %v", names)
+	}
+}
@@ -0,0 +1,36 @@
+package path
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileConfirmed writes a single file in a transaction. An existing file is only overwritten
+// when the prompter confirms it or force is set, and it is backed up next to itself first.
+func WriteFileConfirmed(fs afero.Fs, file string, content []byte, perm os.FileMode, prompter input.Prompter, force bool) error {
+	exists, err := IsExist(fs, file)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err := input.Require(prompter, fmt.Sprintf("File '%v' already exists. Overwrite", file), force); err != nil {
+			return err
+		}
+	}
+
+	tx, err := NewTransaction(fs, filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if exists {
+		tx.Backup(file)
+	}
+	if err := tx.WriteFile(file, content, perm); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,57 @@
+package path
+
+import (
+	"errors"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
+	"github.com/spf13/afero"
+	"io"
+	"testing"
+)
+
+// TestWriteFileConfirmed verifies that an existing file is only overwritten and backed up when confirmed
+func TestWriteFileConfirmed(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompter input.Prompter
+		force    bool
+		expected error
+		// content is the content of the file afterwards
+		content string
+	}{
+		{name: "yes", prompter: input.NewFixed(true, io.Discard), content: "new"},
+		{name: "no", prompter: input.NewFixed(false, io.Discard), expected: input.ErrCanceled, content: "old"},
+		{name: "non_interactive", prompter: input.NewNonInteractive(), expected: input.ErrNonInteractive, content: "old"},
+		{name: "force", prompter: input.NewNonInteractive(), force: true, content: "new"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			if err := afero.WriteFile(fs, "/project/dcm.yaml", []byte("old"), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			err := WriteFileConfirmed(fs, "/project/dcm.yaml", []byte("new"), 0644, tt.prompter, tt.force)
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, err)
+			}
+			if content := readFile(t, fs, "/project/dcm.yaml"); content != tt.content {
+				t.Errorf("Expected %q, got %q", tt.content, content)
+			}
+			entries, _ := afero.ReadDir(fs, "/project")
+			if backup := tt.content == "new"; (len(entries) == 2) != backup {
+				t.Errorf("Expected a backup only for an overwritten file, got %d files", len(entries))
+			}
+			assertNoStagingDirectory(t, fs, "/project")
+		})
+	}
+
+	// A new file is written without asking
+	fs := afero.NewMemMapFs()
+	if err := WriteFileConfirmed(fs, "/project/dcm.yaml", []byte("new"), 0644, input.NewNonInteractive(), false); err != nil {
+		t.Fatalf("WriteFileConfirmed failed: %v", err)
+	}
+	if content := readFile(t, fs, "/project/dcm.yaml"); content != "new" {
+		t.Errorf("Expected %q, got %q", "new", content)
+	}
+}
@@ -0,0 +1,62 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	var old, new strings.Builder
+	for i := 1; i <= 20; i++ {
+		line := "line " + string(rune('a'+i-1)) + "\n"
+		old.WriteString(line)
+		switch i {
+		case 2:
+			new.WriteString("changed\n")
+		case 18:
+		default:
+			new.WriteString(line)
+		}
+	}
+
+	expected := `--- a.yml
++++ b.yml
+@@ -1,5 +1,5 @@
+ line a
+-line b
++changed
+ line c
+ line d
+ line e
+@@ -15,6 +15,5 @@
+ line o
+ line p
+ line q
+-line r
+ line s
+ line t
+`
+	if got := Unified("a.yml", "b.yml", []byte(old.String()), []byte(new.String())); got != expected {
+		t.Errorf("Unified() =\n%v\nexpected\n%v", got, expected)
+	}
+}
+
+func TestUnified_Files(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new []byte
+		expected string
+	}{
+		{"equal", []byte("a\n"), []byte("a\n"), ""},
+		{"created", nil, []byte("a\nb\n"), "--- /dev/null\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"removed", []byte("a\n"), nil, "--- old\n+++ /dev/null\n@@ -1 +0,0 @@\n-a\n"},
+		{"no newline", []byte("a\n"), []byte("a\nb"), "--- old\n+++ new\n@@ -1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.old, tt.new); got != tt.expected {
+				t.Errorf("Unified() =\n%q\nexpected\n%q", got, tt.expected)
+			}
+		})
+	}
+}
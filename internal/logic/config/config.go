@@ -0,0 +1,173 @@
+// Package config reads the per-directory project config file (dcm.yaml) and the DCM_* environment variables
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"strings"
+)
+
+// Keys of the project config, named after the flags they provide the values for
+const (
+	ServicesDirKey   = "services-dir"
+	TemplateKey      = "template"
+	ComposeKey       = "compose"
+	EngineKey        = "engine"
+	IndentKey        = "indent"
+	BuildFormatKey   = "build.format"
+	BuildNoHeaderKey = "build.no-header"
+)
+
+// Keys are all keys of the project config
+var Keys = []string{ServicesDirKey, TemplateKey, ComposeKey, EngineKey, IndentKey, BuildFormatKey, BuildNoHeaderKey}
+
+// EnvPrefix is the prefix of the environment variables overriding the project config. The rest of the
+// name is the key in upper case with dots and dashes replaced by underscores, e.g. DCM_BUILD_NO_HEADER.
+const EnvPrefix = "DCM"
+
+// Project is the configuration of a project
+type Project struct {
+	// ServicesDir is the name of the directory containing the service files
+	ServicesDir string `mapstructure:"services-dir" yaml:"services-dir"`
+	Template    string `mapstructure:"template" yaml:"template"`
+	Compose     string `mapstructure:"compose" yaml:"compose"`
+	Engine      string `mapstructure:"engine" yaml:"engine"`
+	// Indent is the indentation of the service names in new and formatted service files,
+	// 0 detects it from the existing service files
+	Indent int   `mapstructure:"indent" yaml:"indent"`
+	Build  Build `mapstructure:"build" yaml:"build"`
+}
+
+// Build are the options of the build command
+type Build struct {
+	Format   string `mapstructure:"format" yaml:"format"`
+	NoHeader bool   `mapstructure:"no-header" yaml:"no-header"`
+}
+
+// Default returns the configuration used without a project config file
+func Default() Project {
+	return Project{
+		ServicesDir: logic.ServicesDirectoryConst,
+		Template:    logic.TemplateFileNameDefaultConst,
+		Compose:     logic.ComposeFileNameConst,
+		Engine:      engine.Text,
+		Build:       Build{Format: string(format.YAML)},
+	}
+}
+
+// Find returns the path of the project config file in the directory or the nearest of its parents,
+// an empty string if there is none
+func Find(fs afero.Fs, dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, logic.ProjectConfigFileNameConst)
+		info, err := fs.Stat(candidate)
+		if err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Setup makes v read the project config file at the path and the DCM_* environment variables,
+// which take precedence over the file. Without a path, only the environment variables are read.
+// No defaults are set, so v reports exactly the keys given by the file or the environment.
+func Setup(v *viper.Viper, fs afero.Fs, path string) error {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	v.AutomaticEnv()
+	for _, key := range Keys {
+		if err := v.BindEnv(key); err != nil {
+			return err
+		}
+	}
+
+	if path == "" {
+		return nil
+	}
+	v.SetFs(fs)
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read project config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load returns the configuration of the project in the directory from its project config file,
+// when it has one, and the DCM_* environment variables on top of the defaults
+func Load(fs afero.Fs, dir string) (Project, error) {
+	project := Default()
+	v := viper.New()
+	path := filepath.Join(dir, logic.ProjectConfigFileNameConst)
+	exists, err := afero.Exists(fs, path)
+	if err != nil {
+		return project, err
+	}
+	if !exists {
+		path = ""
+	}
+	if err := Setup(v, fs, path); err != nil {
+		return project, err
+	}
+	err = v.Unmarshal(&project)
+	if err == nil {
+		err = project.Validate()
+	}
+	if err != nil {
+		if path == "" {
+			return project, fmt.Errorf("invalid %v_* environment variable: %w", EnvPrefix, err)
+		}
+		return project, fmt.Errorf("invalid project config %s: %w", path, err)
+	}
+	return project, nil
+}
+
+// Validate returns an error if a value of the configuration cannot be used
+func (p Project) Validate() error {
+	names := map[string]string{ServicesDirKey: p.ServicesDir, TemplateKey: p.Template, ComposeKey: p.Compose}
+	for _, key := range []string{ServicesDirKey, TemplateKey, ComposeKey} {
+		if names[key] == "" {
+			return fmt.Errorf("%v must not be empty", key)
+		}
+	}
+	if p.Engine != engine.Auto {
+		if _, err := engine.Get(p.Engine); err != nil {
+			return err
+		}
+	}
+	if p.Indent < 0 {
+		return fmt.Errorf("%v must not be negative", IndentKey)
+	}
+	_, err := format.Parse(p.Build.Format)
+	return err
+}
+
+// Marshal returns the configuration as the content of a project config file
+func (p Project) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("# dcm project config, flags and DCM_* environment variables take precedence\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(p); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
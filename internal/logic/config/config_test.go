@@ -0,0 +1,89 @@
+package config
+
+import (
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFind(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/project/dcm.yaml", []byte("engine: yaml\n"), 0644))
+	assert.NoError(t, fs.MkdirAll("/project/services/nested", 0755))
+	assert.NoError(t, fs.MkdirAll("/other/dcm.yaml", 0755))
+
+	path, err := Find(fs, "/project/services/nested")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/project/dcm.yaml"), path)
+
+	path, err = Find(fs, "/other")
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+}
+
+func TestLoad(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/project/dcm.yaml", []byte(`services-dir: stack
+template: stack-dcm.yml
+indent: 4
+build:
+  format: json
+`), 0644))
+	t.Setenv("DCM_TEMPLATE", "env-dcm.yml")
+	t.Setenv("DCM_BUILD_NO_HEADER", "true")
+
+	project, err := Load(fs, "/project")
+	assert.NoError(t, err)
+	expected := Default()
+	expected.ServicesDir = "stack"
+	expected.Template = "env-dcm.yml"
+	expected.Indent = 4
+	expected.Build = Build{Format: "json", NoHeader: true}
+	assert.Equal(t, expected, project)
+
+	// Without a project config file, only the environment variables change the defaults
+	project, err = Load(fs, "/")
+	assert.NoError(t, err)
+	assert.Equal(t, "env-dcm.yml", project.Template)
+	assert.Equal(t, Default().ServicesDir, project.ServicesDir)
+}
+
+func TestSetup(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content, err := Default().Marshal()
+	assert.NoError(t, err)
+	assert.NoError(t, afero.WriteFile(fs, "/project/dcm.yaml", content, 0644))
+	t.Setenv("DCM_ENGINE", "auto")
+
+	v := viper.New()
+	assert.NoError(t, Setup(v, fs, "/project/dcm.yaml"))
+	assert.Equal(t, "auto", v.GetString(EngineKey))
+	assert.Equal(t, "services", v.GetString(ServicesDirKey))
+	assert.False(t, v.GetBool(BuildNoHeaderKey))
+	assert.True(t, v.IsSet(BuildFormatKey))
+
+	v = viper.New()
+	assert.NoError(t, Setup(v, fs, ""))
+	assert.False(t, v.IsSet(TemplateKey))
+	assert.Error(t, Setup(viper.New(), fs, "/missing/dcm.yaml"))
+}
+
+func TestProject_Validate(t *testing.T) {
+	assert.NoError(t, Default().Validate())
+
+	project := Default()
+	project.Engine = "unknown"
+	assert.Error(t, project.Validate())
+
+	project = Default()
+	project.Indent = -1
+	assert.Error(t, project.Validate())
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/project/dcm.yaml", []byte("template: \"\"\n"), 0644))
+	_, err := Load(fs, "/project")
+	assert.ErrorContains(t, err, "template must not be empty")
+}
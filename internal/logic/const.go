@@ -16,6 +16,8 @@ limitations under the License.
 */
 package logic
 
+import "time"
+
 var (
 	// VersionConst will be set during build
 	VersionConst = "development"
@@ -32,6 +34,9 @@ const (
 	// TemplateFileNameDefaultConst is the default template filename
 	TemplateFileNameDefaultConst = "docker-compose-dcm.yml"
 
+	// ProjectConfigFileNameConst is the name of the per-directory project config file
+	ProjectConfigFileNameConst = "dcm.yaml"
+
 	// ServicesDirectoryConst is the directory containing service definitions
 	ServicesDirectoryConst = "services"
 
@@ -40,4 +45,10 @@ const (
 
 	// BuildDirectoryConst is the default build directory
 	BuildDirectoryConst = "."
+
+	// LockWaitTimeoutConst is how long a command waits for a project locked by another process
+	LockWaitTimeoutConst = 10 * time.Second
+
+	// LockStaleTimeoutConst is the age after which a project lock is considered abandoned
+	LockStaleTimeoutConst = 2 * time.Minute
 )
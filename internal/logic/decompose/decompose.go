@@ -0,0 +1,175 @@
+// Package decompose compares the files written by a decomposition with the existing template and
+// service files, so that only the changed files are overwritten and backed up.
+package decompose
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/textdiff"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/spf13/afero"
+	"io"
+	"path/filepath"
+)
+
+// Status is the difference between a file of the decomposition and the existing file
+type Status string
+
+const (
+	// Created files do not exist yet
+	Created Status = "created"
+	// Changed files exist with a different content
+	Changed Status = "changed"
+	// Unchanged files exist with the same content and are not written
+	Unchanged Status = "unchanged"
+	// Kept files are changed files the user declined to overwrite
+	Kept Status = "kept"
+)
+
+// Change is a file of the template or the services directory written by the decomposition
+type Change struct {
+	Path   string
+	Status Status
+	// Old is the existing content, nil for created files
+	Old []byte
+	// New is the content of the decomposition
+	New []byte
+	// Template is set for the template file
+	Template bool
+}
+
+// Planner compares decompositions with the files of a project
+type Planner struct {
+	fs           afero.Fs
+	templatePath string
+	servicesDir  string
+}
+
+// NewPlanner creates a new instance of Planner
+func NewPlanner(templatePath, servicesDir string) *Planner {
+	return &Planner{
+		fs:           afero.NewOsFs(),
+		templatePath: templatePath,
+		servicesDir:  servicesDir,
+	}
+}
+
+// SetFs selects the file system the files are read from and written to
+func (p *Planner) SetFs(fs afero.Fs) {
+	p.fs = fs
+}
+
+// Plan returns the changes of the decomposition, the template first, then the service files in the
+// order of the decomposition. Existing service files the decomposition has no service file for are
+// not part of the plan and are left untouched.
+func (p *Planner) Plan(decomposition *logic.Decomposition) ([]Change, error) {
+	template, err := p.compare(p.templatePath, decomposition.Template)
+	if err != nil {
+		return nil, err
+	}
+	template.Template = true
+	changes := []Change{template}
+
+	for _, service := range decomposition.Services {
+		change, err := p.compare(filepath.Join(p.servicesDir, service.Name), service.Content)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Confirm shows the diff of every changed file on out and asks whether to overwrite it. Created
+// files are accepted and unchanged files skipped without asking, the answer all accepts the
+// remaining files, and with force nothing is asked. Declined files are marked Kept.
+func Confirm(changes []Change, prompter input.Prompter, out io.Writer, baseDir string, force bool) ([]Change, error) {
+	confirmed := make([]Change, 0, len(changes))
+	all := force
+	for _, change := range changes {
+		if all || change.Status != Changed {
+			confirmed = append(confirmed, change)
+			continue
+		}
+
+		name := relative(baseDir, change.Path)
+		fmt.Fprint(out, textdiff.Unified(name, name, change.Old, change.New))
+		answer, forAll, err := prompter.ConfirmOrAll(fmt.Sprintf("Overwrite '%v'", name))
+		if err != nil {
+			return nil, err
+		}
+		if !answer {
+			change.Status = Kept
+		}
+		all = answer && forAll
+		confirmed = append(confirmed, change)
+	}
+	return confirmed, nil
+}
+
+// Verify returns an error if a file to be written no longer has the content it was planned with,
+// e.g. because it was edited while the changes were being confirmed
+func (p *Planner) Verify(changes []Change) error {
+	for _, change := range changes {
+		if change.Status != Created && change.Status != Changed {
+			continue
+		}
+		current, err := p.compare(change.Path, change.New)
+		if err != nil {
+			return err
+		}
+		if (current.Status == Created) != (change.Status == Created) || !bytes.Equal(current.Old, change.Old) {
+			return fmt.Errorf("'%v' changed while decomposing, run decompose again", change.Path)
+		}
+	}
+	return nil
+}
+
+// Stage stages the created and changed files in the given transaction, the unchanged and kept
+// ones are skipped. The replaced files are backed up by the transaction when it keeps backups.
+func (p *Planner) Stage(tx *path.Transaction, changes []Change) error {
+	for _, change := range changes {
+		if change.Status != Created && change.Status != Changed {
+			continue
+		}
+		if err := tx.WriteFile(change.Path, change.New, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", change.Path, err)
+		}
+	}
+	return nil
+}
+
+// compare returns the change writing the content to the file
+func (p *Planner) compare(file string, content []byte) (Change, error) {
+	change := Change{Path: file, New: content}
+	existing, err := afero.ReadFile(p.fs, file)
+	switch {
+	case err == nil:
+		change.Old = existing
+		change.Status = Changed
+		if string(existing) == string(content) {
+			change.Status = Unchanged
+		}
+	case isNotExist(p.fs, file):
+		change.Status = Created
+	default:
+		return change, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return change, nil
+}
+
+// relative returns the path of the file relative to the base directory, the path itself if it has none
+func relative(baseDir, file string) string {
+	if name, err := filepath.Rel(baseDir, file); err == nil {
+		return name
+	}
+	return file
+}
+
+// isNotExist reports whether the file does not exist
+func isNotExist(fs afero.Fs, file string) bool {
+	exists, err := afero.Exists(fs, file)
+	return err == nil && !exists
+}
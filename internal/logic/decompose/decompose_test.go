@@ -0,0 +1,217 @@
+package decompose
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/spf13/afero"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupProject writes a project whose app service was edited locally and whose hand-written tools
+// service is not in the compose file
+func setupProject(t *testing.T) (afero.Fs, *logic.Decomposition) {
+	fs := afero.NewMemMapFs()
+	files := map[string]string{
+		"/project/docker-compose-dcm.yml": "services:\n<dcm: include services\\>\n",
+		"/project/services/app.yml":       "  app:\n    image: app:1.0 # edited\n",
+		"/project/services/db.yml":        "  db:\n    image: postgres:16\n",
+		"/project/services/tools.yml":     "  tools:\n    image: tools:1.0\n",
+		"/project/services/notes.txt":     "not a service file",
+	}
+	for name, content := range files {
+		assert.NoError(t, afero.WriteFile(fs, filepath.FromSlash(name), []byte(content), 0644))
+	}
+
+	decomposition := &logic.Decomposition{
+		Template: []byte("services:\n<dcm: include services\\>\n"),
+		Services: []logic.ServiceFile{
+			{Name: "app.yml", Content: []byte("  app:\n    image: app:1.0\n")},
+			{Name: "db.yml", Content: []byte("  db:\n    image: postgres:16\n")},
+			{Name: "redis.yml", Content: []byte("  redis:\n    image: redis:7\n")},
+		},
+	}
+	return fs, decomposition
+}
+
+func TestPlanner_Plan(t *testing.T) {
+	fs, decomposition := setupProject(t)
+	planner := NewPlanner(filepath.FromSlash("/project/docker-compose-dcm.yml"), filepath.FromSlash("/project/services"))
+	planner.SetFs(fs)
+
+	changes, err := planner.Plan(decomposition)
+	assert.NoError(t, err)
+
+	var statuses []string
+	for _, change := range changes {
+		statuses = append(statuses, filepath.ToSlash(change.Path)+" "+string(change.Status))
+	}
+	assert.Equal(t, []string{
+		"/project/docker-compose-dcm.yml unchanged",
+		"/project/services/app.yml changed",
+		"/project/services/db.yml unchanged",
+		"/project/services/redis.yml created",
+	}, statuses)
+	assert.True(t, changes[0].Template)
+	assert.Equal(t, "  app:\n    image: app:1.0 # edited\n", string(changes[1].Old))
+	assert.Nil(t, changes[3].Old)
+
+	// Without an existing project, everything is created
+	planner = NewPlanner("/empty/docker-compose-dcm.yml", "/empty/services")
+	planner.SetFs(fs)
+	changes, err = planner.Plan(decomposition)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 4)
+	for _, change := range changes {
+		assert.Equal(t, Created, change.Status)
+	}
+}
+
+func TestPlanner_Stage(t *testing.T) {
+	fs, decomposition := setupProject(t)
+	servicesDir := filepath.FromSlash("/project/services")
+	planner := NewPlanner(filepath.FromSlash("/project/docker-compose-dcm.yml"), servicesDir)
+	planner.SetFs(fs)
+	changes, err := planner.Plan(decomposition)
+	assert.NoError(t, err)
+
+	tx, err := path.NewTransaction(fs, "/project")
+	assert.NoError(t, err)
+	defer tx.Rollback()
+	tx.SetBackup(true)
+	assert.NoError(t, planner.Stage(tx, changes))
+	assert.NoError(t, tx.Commit())
+
+	content, err := afero.ReadFile(fs, filepath.Join(servicesDir, "app.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "  app:\n    image: app:1.0\n", string(content))
+	content, err = afero.ReadFile(fs, filepath.Join(servicesDir, "redis.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "  redis:\n    image: redis:7\n", string(content))
+	// Only the replaced service file is backed up, next to itself
+	backupPath := filepath.Join(servicesDir, "app-"+time.Now().Format("20060102")+".yml")
+	assert.Equal(t, []string{backupPath}, tx.Backups())
+	content, err = afero.ReadFile(fs, backupPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "  app:\n    image: app:1.0 # edited\n", string(content))
+
+	entries, err := afero.ReadDir(fs, servicesDir)
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{filepath.Base(backupPath), "app.yml", "db.yml", "notes.txt", "redis.yml", "tools.yml"}, names)
+}
+
+func TestPlanner_Stage_NoBackup(t *testing.T) {
+	fs, decomposition := setupProject(t)
+	planner := NewPlanner(filepath.FromSlash("/project/docker-compose-dcm.yml"), filepath.FromSlash("/project/services"))
+	planner.SetFs(fs)
+	changes, err := planner.Plan(decomposition)
+	assert.NoError(t, err)
+
+	// The kept and unchanged files are never written
+	changes[1].Status = Kept
+	tx, err := path.NewTransaction(fs, "/project")
+	assert.NoError(t, err)
+	defer tx.Rollback()
+	assert.NoError(t, planner.Stage(tx, changes))
+	assert.Equal(t, []string{filepath.FromSlash("/project/services/redis.yml")}, tx.Files())
+}
+
+func TestConfirm(t *testing.T) {
+	changes := []Change{
+		{Path: "/project/docker-compose-dcm.yml", Status: Changed, Old: []byte("a\n"), New: []byte("b\n"), Template: true},
+		{Path: "/project/services/app.yml", Status: Changed, Old: []byte("a\n"), New: []byte("b\n")},
+		{Path: "/project/services/db.yml", Status: Changed, Old: []byte("a\n"), New: []byte("b\n")},
+		{Path: "/project/services/redis.yml", Status: Created, New: []byte("b\n")},
+		{Path: "/project/services/web.yml", Status: Unchanged, Old: []byte("a\n"), New: []byte("a\n")},
+	}
+	statuses := func(changes []Change) []Status {
+		var result []Status
+		for _, change := range changes {
+			result = append(result, change.Status)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name      string
+		prompter  input.Prompter
+		force     bool
+		expected  []Status
+		questions int
+	}{
+		{
+			name:      "no_yes_all",
+			prompter:  input.NewConsole(strings.NewReader("n\ny\na\n"), io.Discard),
+			expected:  []Status{Kept, Changed, Changed, Created, Unchanged},
+			questions: 3,
+		},
+		{
+			name:      "all",
+			prompter:  input.NewConsole(strings.NewReader("a\n"), io.Discard),
+			expected:  []Status{Changed, Changed, Changed, Created, Unchanged},
+			questions: 1,
+		},
+		{
+			name:      "yes_flag",
+			prompter:  input.NewFixed(true, io.Discard),
+			expected:  []Status{Changed, Changed, Changed, Created, Unchanged},
+			questions: 1,
+		},
+		{
+			name:      "no_flag",
+			prompter:  input.NewFixed(false, io.Discard),
+			expected:  []Status{Kept, Kept, Kept, Created, Unchanged},
+			questions: 3,
+		},
+		{
+			name:      "force",
+			prompter:  input.NewNonInteractive(),
+			force:     true,
+			expected:  []Status{Changed, Changed, Changed, Created, Unchanged},
+			questions: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			confirmed, err := Confirm(changes, tt.prompter, &out, filepath.FromSlash("/project"), tt.force)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, statuses(confirmed))
+			assert.Equal(t, tt.questions, strings.Count(out.String(), "+++ "))
+		})
+	}
+
+	// Without a terminal, a changed file fails the decomposition instead of being kept or overwritten
+	_, err := Confirm(changes, input.NewNonInteractive(), io.Discard, "/project", false)
+	assert.ErrorIs(t, err, input.ErrNonInteractive)
+}
+
+func TestPlanner_Verify(t *testing.T) {
+	fs, decomposition := setupProject(t)
+	planner := NewPlanner(filepath.FromSlash("/project/docker-compose-dcm.yml"), filepath.FromSlash("/project/services"))
+	planner.SetFs(fs)
+	changes, err := planner.Plan(decomposition)
+	assert.NoError(t, err)
+	assert.NoError(t, planner.Verify(changes))
+
+	// A file edited while the changes were confirmed
+	assert.NoError(t, afero.WriteFile(fs, filepath.FromSlash("/project/services/app.yml"), []byte("  app:\n    image: app:2.0\n"), 0644))
+	assert.ErrorContains(t, planner.Verify(changes), "changed while decomposing")
+
+	// A file created while the changes were confirmed
+	changes, err = planner.Plan(decomposition)
+	assert.NoError(t, err)
+	assert.NoError(t, afero.WriteFile(fs, filepath.FromSlash("/project/services/redis.yml"), []byte("  redis:\n"), 0644))
+	assert.Error(t, planner.Verify(changes))
+}
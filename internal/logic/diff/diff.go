@@ -0,0 +1,359 @@
+// Package diff compares two compose files structurally, service by service.
+// Formatting, comments, key order and the short syntax format.Normalize converts are not reported as
+// differences, the short and long syntax of other keys such as ports are.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/helper"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"sort"
+)
+
+// Kind tells whether something was added, removed or changed
+type Kind string
+
+const (
+	// Added means it exists only in the second compose file
+	Added Kind = "added"
+	// Removed means it exists only in the first compose file
+	Removed Kind = "removed"
+	// Changed means it exists in both compose files with different values
+	Changed Kind = "changed"
+)
+
+// Entry is a single difference inside a service
+type Entry struct {
+	// Key is the key of the service the difference belongs to, e.g. image or ports
+	Key  string
+	Kind Kind
+	// Old is the removed or the previous value, empty for added entries
+	Old string
+	// New is the added or the new value, empty for removed entries
+	New string
+}
+
+// ServiceChange lists the differences of a single service. Added and removed services have no entries.
+type ServiceChange struct {
+	Name    string
+	Kind    Kind
+	Entries []Entry
+}
+
+// SectionChange is a top-level section other than services that differs
+type SectionChange struct {
+	Name string
+	Kind Kind
+}
+
+// Report is the result of comparing two compose files
+type Report struct {
+	// Services are the services that differ, in the order of the first file followed by the added services
+	Services []ServiceChange
+	// Sections are the differing top-level sections other than services
+	Sections []SectionChange
+}
+
+// Empty reports whether the compose files are equivalent
+func (r *Report) Empty() bool {
+	return len(r.Services) == 0 && len(r.Sections) == 0
+}
+
+// Count returns the number of added, removed and changed services
+func (r *Report) Count() (added, removed, changed int) {
+	for _, service := range r.Services {
+		switch service.Kind {
+		case Added:
+			added++
+		case Removed:
+			removed++
+		case Changed:
+			changed++
+		}
+	}
+	return added, removed, changed
+}
+
+// orderedKeys are the service keys whose lists are compared as a whole, since the order of their items matters
+var orderedKeys = map[string]bool{
+	"command":    true,
+	"entrypoint": true,
+}
+
+// composeFile is a normalized compose file
+type composeFile struct {
+	serviceNames []string
+	services     map[string]interface{}
+	sectionNames []string
+	sections     map[string]interface{}
+}
+
+// Compare compares the old compose file with the new one
+func Compare(oldContent, newContent []byte) (*Report, error) {
+	oldFile, err := parse(oldContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the first compose file: %w", err)
+	}
+	newFile, err := parse(newContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the second compose file: %w", err)
+	}
+
+	report := &Report{}
+	for _, name := range union(oldFile.serviceNames, newFile.serviceNames) {
+		oldService, inOld := oldFile.services[name]
+		newService, inNew := newFile.services[name]
+		switch {
+		case !inNew:
+			report.Services = append(report.Services, ServiceChange{Name: name, Kind: Removed})
+		case !inOld:
+			report.Services = append(report.Services, ServiceChange{Name: name, Kind: Added})
+		default:
+			if entries := compareService(oldService, newService); len(entries) > 0 {
+				report.Services = append(report.Services, ServiceChange{Name: name, Kind: Changed, Entries: entries})
+			}
+		}
+	}
+
+	for _, name := range union(oldFile.sectionNames, newFile.sectionNames) {
+		oldSection, inOld := oldFile.sections[name]
+		newSection, inNew := newFile.sections[name]
+		switch {
+		case !inNew:
+			report.Sections = append(report.Sections, SectionChange{Name: name, Kind: Removed})
+		case !inOld:
+			report.Sections = append(report.Sections, SectionChange{Name: name, Kind: Added})
+		case !reflect.DeepEqual(oldSection, newSection):
+			report.Sections = append(report.Sections, SectionChange{Name: name, Kind: Changed})
+		}
+	}
+
+	return report, nil
+}
+
+// parse normalizes the compose file and decodes its services and sections.
+// The services are found the same way the decomposers find them.
+func parse(content []byte) (*composeFile, error) {
+	root, err := format.Normalize(provenance.Strip(content))
+	if err != nil {
+		return nil, err
+	}
+
+	file := &composeFile{
+		services: make(map[string]interface{}),
+		sections: make(map[string]interface{}),
+	}
+	servicesNode := helper.FindServicesNode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}})
+	if servicesNode != nil && servicesNode.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+			var service interface{}
+			if err := servicesNode.Content[i+1].Decode(&service); err != nil {
+				return nil, fmt.Errorf("failed to decode service %s: %w", servicesNode.Content[i].Value, err)
+			}
+			file.serviceNames = append(file.serviceNames, servicesNode.Content[i].Value)
+			file.services[servicesNode.Content[i].Value] = service
+		}
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name := root.Content[i].Value
+		if name == "services" {
+			continue
+		}
+		var section interface{}
+		if err := root.Content[i+1].Decode(&section); err != nil {
+			return nil, fmt.Errorf("failed to decode section %s: %w", name, err)
+		}
+		file.sectionNames = append(file.sectionNames, name)
+		file.sections[name] = section
+	}
+
+	return file, nil
+}
+
+// compareService returns the differences between two versions of a service, in canonical key order
+func compareService(oldService, newService interface{}) []Entry {
+	oldMap, oldOk := oldService.(map[string]interface{})
+	newMap, newOk := newService.(map[string]interface{})
+	if !oldOk || !newOk {
+		if reflect.DeepEqual(oldService, newService) {
+			return nil
+		}
+		return []Entry{{Kind: Changed, Old: render(oldService), New: render(newService)}}
+	}
+
+	var entries []Entry
+	for _, key := range serviceKeys(oldMap, newMap) {
+		oldValue, inOld := oldMap[key]
+		newValue, inNew := newMap[key]
+		switch {
+		case !inNew && (!isCollection(oldValue) || orderedKeys[key]):
+			entries = append(entries, Entry{Key: key, Kind: Removed, Old: render(oldValue)})
+		case !inOld && (!isCollection(newValue) || orderedKeys[key]):
+			entries = append(entries, Entry{Key: key, Kind: Added, New: render(newValue)})
+		case !inNew:
+			// Lists and mappings are reported entry by entry, like a change of their content
+			entries = append(entries, compareValue(key, oldValue, emptyLike(oldValue))...)
+		case !inOld:
+			entries = append(entries, compareValue(key, emptyLike(newValue), newValue)...)
+		default:
+			entries = append(entries, compareValue(key, oldValue, newValue)...)
+		}
+	}
+	return entries
+}
+
+// compareValue compares the values of a service key. Lists are compared as sets of entries and mappings
+// key by key, so that a single added port or environment variable is reported as such.
+func compareValue(key string, oldValue, newValue interface{}) []Entry {
+	if reflect.DeepEqual(oldValue, newValue) {
+		return nil
+	}
+
+	switch oldTyped := oldValue.(type) {
+	case []interface{}:
+		newTyped, ok := newValue.([]interface{})
+		if !ok || orderedKeys[key] {
+			break
+		}
+		oldItems, newItems := renderAll(oldTyped), renderAll(newTyped)
+		var entries []Entry
+		for _, item := range difference(oldItems, newItems) {
+			entries = append(entries, Entry{Key: key, Kind: Removed, Old: item})
+		}
+		for _, item := range difference(newItems, oldItems) {
+			entries = append(entries, Entry{Key: key, Kind: Added, New: item})
+		}
+		return entries
+	case map[string]interface{}:
+		newTyped, ok := newValue.(map[string]interface{})
+		if !ok {
+			break
+		}
+		var entries []Entry
+		for _, name := range mapKeys(oldTyped, newTyped) {
+			oldItem, inOld := oldTyped[name]
+			newItem, inNew := newTyped[name]
+			switch {
+			case !inNew:
+				entries = append(entries, Entry{Key: key, Kind: Removed, Old: renderPair(name, oldItem)})
+			case !inOld:
+				entries = append(entries, Entry{Key: key, Kind: Added, New: renderPair(name, newItem)})
+			case !reflect.DeepEqual(oldItem, newItem):
+				entries = append(entries, Entry{Key: key, Kind: Changed, Old: renderPair(name, oldItem), New: renderPair(name, newItem)})
+			}
+		}
+		return entries
+	}
+
+	return []Entry{{Key: key, Kind: Changed, Old: render(oldValue), New: render(newValue)}}
+}
+
+// isCollection reports whether the value is a list or a mapping
+func isCollection(value interface{}) bool {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return true
+	}
+	return false
+}
+
+// emptyLike returns an empty list or mapping of the same type as the value
+func emptyLike(value interface{}) interface{} {
+	if _, ok := value.([]interface{}); ok {
+		return []interface{}{}
+	}
+	return map[string]interface{}{}
+}
+
+// render returns a value on a single line: scalars as they are, lists and mappings as JSON
+func render(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return typed
+	case []interface{}, map[string]interface{}:
+		encoded, err := json.Marshal(typed)
+		if err != nil {
+			return fmt.Sprint(typed)
+		}
+		return string(encoded)
+	}
+	return fmt.Sprint(value)
+}
+
+// renderPair returns an entry of a mapping on a single line, e.g. an environment variable
+func renderPair(name string, value interface{}) string {
+	if value == nil {
+		return name
+	}
+	return name + "=" + render(value)
+}
+
+// renderAll renders every item of a list
+func renderAll(items []interface{}) []string {
+	rendered := make([]string, 0, len(items))
+	for _, item := range items {
+		rendered = append(rendered, render(item))
+	}
+	return rendered
+}
+
+// difference returns the items of a missing in b, in the order of a
+func difference(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, item := range b {
+		inB[item] = true
+	}
+	var result []string
+	for _, item := range a {
+		if !inB[item] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// union returns the names of a followed by the names of b missing in a
+func union(a, b []string) []string {
+	return append(append([]string(nil), a...), difference(b, a)...)
+}
+
+// serviceKeys returns the keys of both services in canonical Compose order
+func serviceKeys(a, b map[string]interface{}) []string {
+	rank := make(map[string]int, len(format.ServiceKeyOrder))
+	for i, key := range format.ServiceKeyOrder {
+		rank[key] = i
+	}
+	keys := mapKeys(a, b)
+	sort.SliceStable(keys, func(i, j int) bool {
+		ri, iKnown := rank[keys[i]]
+		rj, jKnown := rank[keys[j]]
+		if iKnown != jKnown {
+			return iKnown
+		}
+		return iKnown && ri < rj
+	})
+	return keys
+}
+
+// mapKeys returns the sorted keys of both mappings
+func mapKeys(a, b map[string]interface{}) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, m := range []map[string]interface{}{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
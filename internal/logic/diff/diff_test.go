@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	oldContent := `services:
+  app:
+    image: app:1.0
+    ports:
+      - 8080:80
+      - "7000:7000"
+    environment:
+      - LOG_LEVEL=info
+      - DEBUG
+    volumes: [data:/data]
+    command: ["serve", "--port", "80"]
+  cache:
+    image: redis:alpine
+networks:
+  innernet:
+    driver: bridge
+volumes:
+  data:
+`
+	newContent := `# Generated by dcm 1.0. Do not edit by hand: edit the sources and run 'dcm build'.
+# dcm:output sha256:0000000000000000000000000000000000000000000000000000000000000000
+volumes:
+  data:
+services:
+  app:
+    command: ["serve", "80", "--port"]
+    environment:
+      LOG_LEVEL: debug
+      TZ: UTC
+    ports: ["7000:7000", "8080:80", "9090:90"]
+    image: app:1.1
+    volumes:
+      - data:/data
+    labels:
+      tier: web
+  db:
+    image: postgres:16
+networks:
+  innernet:
+    driver: overlay
+`
+
+	report, err := Compare([]byte(oldContent), []byte(newContent))
+	assert.NoError(t, err)
+	assert.Equal(t, []ServiceChange{
+		{Name: "app", Kind: Changed, Entries: []Entry{
+			{Key: "image", Kind: Changed, Old: "app:1.0", New: "app:1.1"},
+			{Key: "command", Kind: Changed, Old: `["serve","--port","80"]`, New: `["serve","80","--port"]`},
+			{Key: "environment", Kind: Removed, Old: "DEBUG"},
+			{Key: "environment", Kind: Changed, Old: "LOG_LEVEL=info", New: "LOG_LEVEL=debug"},
+			{Key: "environment", Kind: Added, New: "TZ=UTC"},
+			{Key: "ports", Kind: Added, New: "9090:90"},
+			{Key: "labels", Kind: Added, New: "tier=web"},
+		}},
+		{Name: "cache", Kind: Removed},
+		{Name: "db", Kind: Added},
+	}, report.Services)
+	assert.Equal(t, []SectionChange{{Name: "networks", Kind: Changed}}, report.Sections)
+
+	added, removed, changed := report.Count()
+	assert.Equal(t, []int{1, 1, 1}, []int{added, removed, changed})
+}
+
+func TestCompare_FormattingOnly(t *testing.T) {
+	oldContent := "services:\n  app: # Main application\n    image: app\n    environment: [A=1, B=2]\n"
+	newContent := "services:\n    app:\n        environment:\n            B: \"2\"\n            A: 1\n        image: app\n"
+
+	report, err := Compare([]byte(oldContent), []byte(newContent))
+	assert.NoError(t, err)
+	assert.True(t, report.Empty())
+}
+
+func TestCompare_InvalidYAML(t *testing.T) {
+	_, err := Compare([]byte("services: ["), []byte("services: {}\n"))
+	assert.ErrorContains(t, err, "failed to parse the first compose file")
+}
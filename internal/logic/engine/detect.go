@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// servicesIndent is the indentation of the services the text engine expects
+const servicesIndent = 2
+
+// Detection is the engine picked by auto together with the reasons for the choice
+type Detection struct {
+	Engine  string
+	Reasons []string
+}
+
+// DetectBuild picks the engine for building from the template and the service files.
+// The text engine is picked unless the files contain something only the yaml engine handles.
+func DetectBuild(fs afero.Fs, templatePath, servicesDir string) (Detection, error) {
+	template, err := afero.ReadFile(fs, templatePath)
+	if err != nil {
+		return Detection{}, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	names, err := logic.ReadServiceFiles(fs, servicesDir)
+	if err != nil {
+		return Detection{}, fmt.Errorf("failed to read services directory: %w", err)
+	}
+	var services []logic.ServiceFile
+	for _, name := range names {
+		content, err := afero.ReadFile(fs, filepath.Join(servicesDir, name))
+		if err != nil {
+			return Detection{}, fmt.Errorf("failed to read service file %s: %w", name, err)
+		}
+		services = append(services, logic.ServiceFile{Name: name, Content: content})
+	}
+
+	return DetectBuildContent(filepath.Base(templatePath), template, filepath.Base(servicesDir), services)
+}
+
+// DetectBuildContent picks the engine for building from the content of the template and the service files.
+// The names are only used to describe the reasons.
+func DetectBuildContent(templateName string, template []byte, servicesDirName string, services []logic.ServiceFile) (Detection, error) {
+	var reasons []string
+
+	if !strings.Contains(string(template), "<dcm: include services\\>") {
+		reasons = append(reasons, fmt.Sprintf("%v: no '<dcm: include services\\>' directive", templateName))
+	}
+
+	sorted := append([]logic.ServiceFile(nil), services...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, service := range sorted {
+		var node yaml.Node
+		if err := yaml.Unmarshal(service.Content, &node); err != nil {
+			return Detection{}, fmt.Errorf("failed to parse service file %s: %w", service.Name, err)
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+
+		label := filepath.Join(servicesDirName, service.Name)
+		reasons = append(reasons, inspectServices(label, node.Content[0])...)
+		reasons = append(reasons, inspectAnchors(label, &node)...)
+	}
+
+	return decide(reasons), nil
+}
+
+// DetectDecompose picks the engine for decomposing the compose file.
+// The text engine is picked unless the file contains something only the yaml engine handles.
+func DetectDecompose(fs afero.Fs, composePath string) (Detection, error) {
+	content, err := afero.ReadFile(fs, composePath)
+	if err != nil {
+		return Detection{}, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	return DetectDecomposeContent(filepath.Base(composePath), content)
+}
+
+// DetectDecomposeContent picks the engine for decomposing the content of a compose file.
+// The name is only used to describe the reasons.
+func DetectDecomposeContent(label string, content []byte) (Detection, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return Detection{}, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	if len(node.Content) == 0 {
+		return decide(nil), nil
+	}
+
+	var reasons []string
+	root := node.Content[0]
+	if root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0 {
+		reasons = append(reasons, fmt.Sprintf("%v: the document is not a block mapping", label))
+		return decide(reasons), nil
+	}
+
+	afterServices := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Value == "services" {
+			afterServices = true
+			if (value.Line == key.Line && !isNull(value)) || key.LineComment != "" {
+				reasons = append(reasons, fmt.Sprintf("%v:%d: more than the key on the services line", label, key.Line))
+			}
+			reasons = append(reasons, inspectServices(label, value)...)
+			continue
+		}
+
+		// A section with its value on the key line looks like a service line to the text engine
+		if afterServices && value.Line == key.Line && !isNull(value) {
+			reasons = append(reasons, fmt.Sprintf("%v:%d: section '%v' after the services has its value on the key line", label, key.Line, key.Value))
+		}
+	}
+	reasons = append(reasons, inspectAnchors(label, &node)...)
+
+	return decide(reasons), nil
+}
+
+// inspectServices returns the reasons why the text engine cannot handle the mapping of services
+func inspectServices(label string, services *yaml.Node) []string {
+	if services.Kind != yaml.MappingNode {
+		return nil
+	}
+	if services.Style&yaml.FlowStyle != 0 {
+		return []string{fmt.Sprintf("%v:%d: services written in flow style", label, services.Line)}
+	}
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		key := services.Content[i]
+		if indent := key.Column - 1; indent != servicesIndent {
+			return []string{fmt.Sprintf("%v:%d: service '%v' indented by %d spaces instead of %d",
+				label, key.Line, key.Value, indent, servicesIndent)}
+		}
+	}
+	return nil
+}
+
+// inspectAnchors returns a reason when the document uses anchors or aliases, which only
+// the yaml engine checks while splitting and merging the files
+func inspectAnchors(label string, node *yaml.Node) []string {
+	if found := findAnchor(node); found != nil {
+		return []string{fmt.Sprintf("%v:%d: uses anchors or aliases", label, found.Line)}
+	}
+	return nil
+}
+
+// findAnchor returns the first node defining an anchor or referring to one
+func findAnchor(node *yaml.Node) *yaml.Node {
+	if node.Anchor != "" || node.Kind == yaml.AliasNode {
+		return node
+	}
+	for _, child := range node.Content {
+		if found := findAnchor(child); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// isNull reports whether the node is an empty value
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null" && node.Value == ""
+}
+
+// decide picks the yaml engine when there is any reason for it and the text engine otherwise
+func decide(reasons []string) Detection {
+	if len(reasons) > 0 {
+		return Detection{Engine: YAML, Reasons: reasons}
+	}
+	return Detection{
+		Engine:  Text,
+		Reasons: []string{fmt.Sprintf("plain block layout with services indented by %d spaces", servicesIndent)},
+	}
+}
@@ -0,0 +1,126 @@
+// Package engine provides the processing engines building and decomposing compose files
+package engine
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/text"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml"
+	"github.com/spf13/afero"
+	"sort"
+	"strings"
+)
+
+const (
+	// Text processes the files line by line
+	Text = "text"
+	// YAML processes the files with a YAML parser
+	YAML = "yaml"
+	// Auto picks the engine based on the content of the files
+	Auto = "auto"
+)
+
+// Builder combines the template and the service files into a compose file. Builders print nothing,
+// the commands report the outcome, so that projects can be built in parallel.
+type Builder interface {
+	Build() error
+	SetFs(fs afero.Fs)
+	SetFormat(f format.Format)
+	SetProvenance(enabled bool)
+	SetBackup(enabled bool)
+	SetPrompter(p input.Prompter)
+}
+
+// Decomposer splits a compose file into the template and the service files
+type Decomposer interface {
+	Decompose() error
+	SetFs(fs afero.Fs)
+	Stage(tx *path.Transaction) error
+}
+
+// Engine creates the builders and decomposers of one processing mode
+type Engine struct {
+	Name          string
+	Description   string
+	NewBuilder    func(buildDir, templatePath, servicesDir, outputPath string, forceOverwrite bool) Builder
+	NewDecomposer func(fileSrc, fileTemplate, servicesDir string) Decomposer
+	// Assemble merges the service files into the template without touching the file system
+	Assemble func(template []byte, services []logic.ServiceFile) ([]byte, error)
+	// Split splits a compose file into the template and the service files without touching the file system
+	Split func(content []byte) (*logic.Decomposition, error)
+}
+
+var engines = make(map[string]Engine)
+
+// Register adds the engine to the registry, replacing an engine with the same name
+func Register(e Engine) {
+	engines[e.Name] = e
+}
+
+// Get returns the registered engine with the given name
+func Get(name string) (Engine, error) {
+	e, ok := engines[name]
+	if !ok {
+		return Engine{}, fmt.Errorf("unknown engine '%v', expected one of: %v", name, strings.Join(Names(), ", "))
+	}
+	return e, nil
+}
+
+// Resolve returns the engine with the given name. The auto engine is resolved by the detect function,
+// the reasons of its choice are returned with it.
+func Resolve(name string, detect func() (Detection, error)) (Engine, []string, error) {
+	var reasons []string
+	if name == Auto {
+		detection, err := detect()
+		if err != nil {
+			return Engine{}, nil, fmt.Errorf("failed to detect the engine: %w", err)
+		}
+		name, reasons = detection.Engine, detection.Reasons
+	}
+
+	e, err := Get(name)
+	if err != nil {
+		return Engine{}, nil, err
+	}
+	return e, reasons, nil
+}
+
+// Names returns the names of the registered engines followed by auto
+func Names() []string {
+	names := make([]string, 0, len(engines)+1)
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(names, Auto)
+}
+
+func init() {
+	Register(Engine{
+		Name:        Text,
+		Description: "processing at text level",
+		NewBuilder: func(buildDir, templatePath, servicesDir, outputPath string, forceOverwrite bool) Builder {
+			return text.NewBuilder(buildDir, templatePath, servicesDir, outputPath, forceOverwrite)
+		},
+		NewDecomposer: func(fileSrc, fileTemplate, servicesDir string) Decomposer {
+			return text.NewServiceDecomposer(fileSrc, fileTemplate, servicesDir)
+		},
+		Assemble: text.Assemble,
+		Split:    text.Split,
+	})
+	Register(Engine{
+		Name:        YAML,
+		Description: "processing using yaml parser",
+		NewBuilder: func(buildDir, templatePath, servicesDir, outputPath string, forceOverwrite bool) Builder {
+			return yaml.NewBuilder(buildDir, templatePath, servicesDir, outputPath, forceOverwrite)
+		},
+		NewDecomposer: func(fileSrc, fileTemplate, servicesDir string) Decomposer {
+			return yaml.NewServiceDecomposer(fileSrc, fileTemplate, servicesDir)
+		},
+		Assemble: yaml.Assemble,
+		Split:    yaml.Split,
+	})
+}
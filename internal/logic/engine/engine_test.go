@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"github.com/spf13/afero"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	e, err := Get(YAML)
+	assert.NoError(t, err)
+	assert.Equal(t, YAML, e.Name)
+	assert.NotNil(t, e.NewBuilder("dir", "template", "services", "output", true))
+	assert.NotNil(t, e.NewDecomposer("compose", "template", "services"))
+
+	_, err = Get("xml")
+	assert.EqualError(t, err, "unknown engine 'xml', expected one of: text, yaml, auto")
+}
+
+// memFs creates an in-memory file system with the files with the given content below the directory
+func memFs(t *testing.T, dir string, files map[string]string) afero.Fs {
+	fs := afero.NewMemMapFs()
+	for name, content := range files {
+		assert.NoError(t, afero.WriteFile(fs, filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return fs
+}
+
+func TestDetectBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected string
+		reason   string
+	}{
+		{
+			name: "plain",
+			files: map[string]string{
+				"services/app.yml": "  app:\n    image: app\n",
+			},
+			expected: Text,
+			reason:   "plain block layout with services indented by 2 spaces",
+		},
+		{
+			name: "indentation",
+			files: map[string]string{
+				"services/app.yml": "app:\n  image: app\n",
+			},
+			expected: YAML,
+			reason:   "services/app.yml:1: service 'app' indented by 0 spaces instead of 2",
+		},
+		{
+			name: "flow style",
+			files: map[string]string{
+				"services/app.yml": "{app: {image: app}}\n",
+			},
+			expected: YAML,
+			reason:   "services/app.yml:1: services written in flow style",
+		},
+		{
+			name: "anchors",
+			files: map[string]string{
+				"services/app.yml": "  app:\n    environment: &env\n      A: 1\n",
+			},
+			expected: YAML,
+			reason:   "services/app.yml:2: uses anchors or aliases",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := "/project"
+			tt.files["docker-compose-dcm.yml"] = "services:\n<dcm: include services\\>\n"
+			fs := memFs(t, dir, tt.files)
+
+			detection, err := DetectBuild(fs, filepath.Join(dir, "docker-compose-dcm.yml"), filepath.Join(dir, "services"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, detection.Engine)
+			assert.Equal(t, []string{filepath.FromSlash(tt.reason)}, detection.Reasons)
+		})
+	}
+}
+
+func TestDetectDecompose(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		reasons  []string
+	}{
+		{
+			name:     "plain",
+			content:  "version: '3'\nservices:\n  app:\n    image: app\n\nvolumes:\n  data:\n",
+			expected: Text,
+			reasons:  []string{"plain block layout with services indented by 2 spaces"},
+		},
+		{
+			name:     "indentation and comment",
+			content:  "services: # All services\n    app:\n        image: app\n",
+			expected: YAML,
+			reasons: []string{
+				"docker-compose.yml:1: more than the key on the services line",
+				"docker-compose.yml:2: service 'app' indented by 4 spaces instead of 2",
+			},
+		},
+		{
+			name:     "inline section after services",
+			content:  "services:\n  app:\n    image: app\nversion: '3'\n",
+			expected: YAML,
+			reasons:  []string{"docker-compose.yml:4: section 'version' after the services has its value on the key line"},
+		},
+		{
+			name:     "aliases",
+			content:  "x-env: &env\n  A: 1\nservices:\n  app:\n    environment: *env\n",
+			expected: YAML,
+			reasons:  []string{"docker-compose.yml:1: uses anchors or aliases"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := "/project"
+			fs := memFs(t, dir, map[string]string{"docker-compose.yml": tt.content})
+
+			detection, err := DetectDecompose(fs, filepath.Join(dir, "docker-compose.yml"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, detection.Engine)
+			assert.Equal(t, tt.reasons, detection.Reasons)
+		})
+	}
+}
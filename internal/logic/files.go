@@ -0,0 +1,120 @@
+// Package logic /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package logic
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// serviceNamePattern matches the service names accepted by docker compose
+var serviceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// backupFilePattern matches the date-based backup files created by dcm, e.g. app-20241231.yml or app-20241231.2.yml
+var backupFilePattern = regexp.MustCompile(`-\d{8}(\.\d+)?\.yml$`)
+
+// ServiceFile is a service file of a project, the name is relative to the services directory
+type ServiceFile struct {
+	Name    string
+	Content []byte
+}
+
+// Decomposition is a compose file split into the template and the service files
+type Decomposition struct {
+	Template []byte
+	Services []ServiceFile
+}
+
+// ValidateServiceName returns an error if the name cannot be used as a service name
+func ValidateServiceName(name string) error {
+	if !serviceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid service name '%v': use letters, digits, '_', '.' and '-' only", name)
+	}
+	return nil
+}
+
+// IsServiceFile reports whether the file name can be a service file, excluding editor temporary files
+// (.app.yml.swp, app.yml~, #app.yml#, .#app.yml). Whether a name with a date-based suffix is a backup
+// depends on the other files of the directory, see ServiceFiles.
+func IsServiceFile(name string) bool {
+	if filepath.Ext(name) != ".yml" {
+		return false
+	}
+	return !strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "#")
+}
+
+// ServiceFiles returns the names of the service files among the names of the files in a services
+// directory, sorted. A name with a date-based suffix is a backup created by dcm when the file it was
+// backed up from is in the directory too, e.g. app-20241231.yml next to app.yml; without it, e.g.
+// db-20240101.yml alone, it is a service file. The builders and every command reading the services
+// directory include the same files.
+func ServiceFiles(names []string) []string {
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+	}
+
+	var services []string
+	for _, name := range names {
+		if !IsServiceFile(name) {
+			continue
+		}
+		if source, ok := BackupSource(name); ok && present[source] {
+			continue
+		}
+		services = append(services, name)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// BackupSource returns the name of the file a backup created by dcm would have been made of, e.g.
+// app.yml for app-20241231.yml, and false for names without a date-based suffix
+func BackupSource(name string) (string, bool) {
+	if !backupFilePattern.MatchString(name) {
+		return "", false
+	}
+	return backupFilePattern.ReplaceAllString(name, ".yml"), true
+}
+
+// ReadServiceFiles returns the names of the service files in the directory, sorted, see ServiceFiles
+func ReadServiceFiles(fs afero.Fs, dir string) ([]string, error) {
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return ServiceFiles(names), nil
+}
+
+// RelativePath returns the slash-separated path of the file relative to the project directory, the
+// path itself if it has none. The sources in messages and in the provenance header are written this way.
+func RelativePath(buildDir, filePath string) string {
+	if rel, err := filepath.Rel(buildDir, filePath); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filePath)
+}
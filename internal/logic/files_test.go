@@ -0,0 +1,58 @@
+package logic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsServiceFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{name: "app.yml", expected: true},
+		{name: "my-app.yml", expected: true},
+		{name: "app.yaml", expected: false},
+		{name: ".app.yml.swp", expected: false},
+		{name: ".app.yml", expected: false},
+		{name: "app.yml~", expected: false},
+		{name: "#app.yml#", expected: false},
+		{name: ".#app.yml", expected: false},
+		// Whether it is a backup depends on the other files, see ServiceFiles
+		{name: "app-20241231.yml", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsServiceFile(tt.name))
+		})
+	}
+}
+
+func TestServiceFiles(t *testing.T) {
+	names := []string{"web.yml", "app-20241231.3.yml", "app.yml", "app-20241231.yml", "db-20240101.yml",
+		"notes.txt", ".app.yml.swp", "cache-12345678.yml"}
+
+	// Dated names are backups only next to the file they were made of
+	assert.Equal(t, []string{"app.yml", "cache-12345678.yml", "db-20240101.yml", "web.yml"}, ServiceFiles(names))
+	assert.Empty(t, ServiceFiles(nil))
+}
+
+func TestBackupSource(t *testing.T) {
+	source, ok := BackupSource("my-app-20241231.2.yml")
+	assert.True(t, ok)
+	assert.Equal(t, "my-app.yml", source)
+
+	_, ok = BackupSource("my-app.yml")
+	assert.False(t, ok)
+}
+
+func TestRelativePath(t *testing.T) {
+	buildDir := filepath.FromSlash("/project")
+	assert.Equal(t, "services/app.yml", RelativePath(buildDir, filepath.Join(buildDir, "services", "app.yml")))
+	assert.Equal(t, "../shared/db.yml", RelativePath(buildDir, filepath.FromSlash("/shared/db.yml")))
+	// Without a relative path, the path itself is returned
+	assert.Equal(t, "services/app.yml", RelativePath(buildDir, filepath.FromSlash("services/app.yml")))
+}
@@ -0,0 +1,358 @@
+// Package format converts assembled compose files into the supported output formats
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format identifies the output format of the build command
+type Format string
+
+const (
+	// YAML keeps the assembled compose file as is, including comments
+	YAML Format = "yaml"
+	// YAMLNormalized emits canonical YAML without comments, suitable for clean diffs
+	YAMLNormalized Format = "yaml-normalized"
+	// JSON emits the normalized compose file as JSON
+	JSON Format = "json"
+)
+
+// Names returns the names of all supported formats
+func Names() []string {
+	return []string{string(YAML), string(YAMLNormalized), string(JSON)}
+}
+
+// Parse converts the given name into a Format
+func Parse(name string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(name))) {
+	case YAML, "":
+		return YAML, nil
+	case YAMLNormalized:
+		return YAMLNormalized, nil
+	case JSON:
+		return JSON, nil
+	}
+	return "", fmt.Errorf("unknown format '%v' (supported: %v)", name, strings.Join(Names(), ", "))
+}
+
+// TopLevelKeyOrder is the canonical order of the top-level compose sections.
+// Extension fields (x-*) follow the listed keys, any other keys are sorted alphabetically after them.
+var TopLevelKeyOrder = []string{"version", "name", "include", "services", "networks", "volumes", "configs", "secrets"}
+
+// ServiceKeyOrder is the canonical order of the keys inside a service definition
+var ServiceKeyOrder = []string{
+	"image", "build", "container_name", "hostname", "platform", "profiles",
+	"command", "entrypoint", "working_dir", "user",
+	"environment", "env_file", "ports", "expose", "volumes", "volumes_from", "tmpfs",
+	"networks", "network_mode", "extra_hosts", "dns", "links", "external_links",
+	"depends_on", "extends", "healthcheck", "restart", "deploy",
+	"labels", "logging", "secrets", "configs",
+}
+
+// Convert re-encodes the assembled compose content in the requested format
+func Convert(content []byte, f Format) ([]byte, error) {
+	if f == YAML || f == "" {
+		return content, nil
+	}
+
+	node, err := Normalize(content)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f {
+	case YAMLNormalized:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(node); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML: %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML: %w", err)
+		}
+		return buf.Bytes(), nil
+	case JSON:
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, node, ""); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported format '%v'", f)
+}
+
+// Normalize parses the compose content and returns its canonical form: comments, anchors and merge keys
+// are resolved away, short syntax is converted consistently and keys are sorted in canonical Compose order.
+func Normalize(content []byte) (*yaml.Node, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(content, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	if value == nil {
+		value = map[string]interface{}{}
+	}
+
+	// Encoding the decoded value drops comments, anchors and aliases
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, fmt.Errorf("failed to normalize compose file: %w", err)
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("compose file must be a mapping")
+	}
+
+	SortMapping(&node, TopLevelKeyOrder)
+	if services := mappingValue(&node, "services"); services != nil && services.Kind == yaml.MappingNode {
+		for i := 1; i < len(services.Content); i += 2 {
+			normalizeService(services.Content[i])
+		}
+	}
+
+	return &node, nil
+}
+
+// normalizeService converts the short syntax of a single service and sorts its keys
+func normalizeService(service *yaml.Node) {
+	if service.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i < len(service.Content); i += 2 {
+		key := service.Content[i].Value
+		value := service.Content[i+1]
+
+		switch key {
+		case "environment", "labels", "annotations":
+			service.Content[i+1] = listToMapping(value)
+		case "ports", "expose":
+			quoteScalars(value)
+		case "build":
+			if value.Kind == yaml.MappingNode {
+				if args := mappingValue(value, "args"); args != nil {
+					setMappingValue(value, "args", listToMapping(args))
+				}
+				SortMapping(value, nil)
+			} else if value.Kind == yaml.ScalarNode {
+				// build: ./dir is the short form of build.context
+				service.Content[i+1] = &yaml.Node{
+					Kind:    yaml.MappingNode,
+					Tag:     "!!map",
+					Content: []*yaml.Node{scalar("context"), value},
+				}
+			}
+		}
+	}
+
+	SortMapping(service, ServiceKeyOrder)
+}
+
+// listToMapping converts a KEY=VALUE sequence into a mapping, leaving mappings untouched
+func listToMapping(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.MappingNode {
+		for i := 1; i < len(node.Content); i += 2 {
+			if node.Content[i].Kind == yaml.ScalarNode && node.Content[i].Tag != "!!null" {
+				node.Content[i] = scalar(node.Content[i].Value)
+			}
+		}
+		SortMapping(node, nil)
+		return node
+	}
+	if node.Kind != yaml.SequenceNode {
+		return node
+	}
+
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, item := range node.Content {
+		name, value, found := strings.Cut(item.Value, "=")
+		valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		if found {
+			valueNode = scalar(value)
+		}
+		mapping.Content = append(mapping.Content, scalar(name), valueNode)
+	}
+	SortMapping(mapping, nil)
+	return mapping
+}
+
+// quoteScalars makes every scalar item of a sequence a double-quoted string
+func quoteScalars(node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, item := range node.Content {
+		if item.Kind == yaml.ScalarNode {
+			item.Tag = "!!str"
+			item.Style = yaml.DoubleQuotedStyle
+		} else if item.Kind == yaml.MappingNode {
+			SortMapping(item, nil)
+		}
+	}
+}
+
+// SortMapping sorts the keys of a mapping node, keys listed in order come first in the given order,
+// extension keys (x-*) follow and all remaining keys are sorted alphabetically
+func SortMapping(node *yaml.Node, order []string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	rank := func(key string) (int, string) {
+		for i, name := range order {
+			if name == key {
+				return i, ""
+			}
+		}
+		if strings.HasPrefix(key, "x-") {
+			return len(order), key
+		}
+		return len(order) + 1, key
+	}
+
+	type pair struct{ key, value *yaml.Node }
+	pairs := make([]pair, 0, len(node.Content)/2)
+	for i := 0; i < len(node.Content); i += 2 {
+		pairs = append(pairs, pair{node.Content[i], node.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		ri, ni := rank(pairs[i].key.Value)
+		rj, nj := rank(pairs[j].key.Value)
+		if ri != rj {
+			return ri < rj
+		}
+		return ni < nj
+	})
+
+	node.Content = node.Content[:0]
+	for _, p := range pairs {
+		node.Content = append(node.Content, p.key, p.value)
+	}
+}
+
+// mappingValue returns the value stored under the given key of a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue replaces the value stored under the given key of a mapping node
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+}
+
+// scalar creates a plain string scalar node
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// writeJSON writes the node as indented JSON keeping the order of mapping keys
+func writeJSON(buf *bytes.Buffer, node *yaml.Node, indent string) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSON(buf, node.Content[0], indent)
+	case yaml.AliasNode:
+		return writeJSON(buf, node.Alias, indent)
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i := 0; i < len(node.Content); i += 2 {
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return fmt.Errorf("failed to encode JSON key: %w", err)
+			}
+			buf.WriteString(indent + "  ")
+			buf.Write(key)
+			buf.WriteString(": ")
+			if err := writeJSON(buf, node.Content[i+1], indent+"  "); err != nil {
+				return err
+			}
+			if i+2 < len(node.Content) {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, item := range node.Content {
+			buf.WriteString(indent + "  ")
+			if err := writeJSON(buf, item, indent+"  "); err != nil {
+				return err
+			}
+			if i+1 < len(node.Content) {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "]")
+	case yaml.ScalarNode:
+		return writeJSONScalar(buf, node)
+	}
+	return nil
+}
+
+// writeJSONScalar writes a scalar node as the matching JSON literal
+func writeJSONScalar(buf *bytes.Buffer, node *yaml.Node) error {
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 {
+		switch node.ShortTag() {
+		case "!!null":
+			buf.WriteString("null")
+			return nil
+		case "!!bool":
+			value, err := strconv.ParseBool(node.Value)
+			if err == nil {
+				buf.WriteString(strconv.FormatBool(value))
+				return nil
+			}
+		case "!!int":
+			var number int64
+			if err := node.Decode(&number); err == nil {
+				buf.WriteString(strconv.FormatInt(number, 10))
+				return nil
+			}
+		case "!!float":
+			var number float64
+			if err := node.Decode(&number); err == nil {
+				encoded, err := json.Marshal(number)
+				if err == nil {
+					buf.Write(encoded)
+					return nil
+				}
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(node.Value)
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON value: %w", err)
+	}
+	buf.Write(encoded)
+	return nil
+}
@@ -0,0 +1,114 @@
+package format
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const composeContent = `# Main configuration
+networks:
+  innernet:
+    driver: bridge
+services:
+  app: # Main application
+    restart: unless-stopped
+    ports:
+      - 8080:80
+      - 9000
+    environment:
+      - REDIS_URL=redis:6379
+      - DEBUG
+    build: ./app
+    image: app:1.0
+x-common: &common
+  restart: always
+volumes:
+  data: {}
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Format
+		wantErr  bool
+	}{
+		{name: "empty_defaults_to_yaml", input: "", expected: YAML},
+		{name: "yaml", input: "yaml", expected: YAML},
+		{name: "normalized_upper_case", input: "YAML-Normalized", expected: YAMLNormalized},
+		{name: "json", input: "json", expected: JSON},
+		{name: "unknown", input: "toml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestConvert_YAMLIsUnchanged(t *testing.T) {
+	result, err := Convert([]byte(composeContent), YAML)
+	assert.NoError(t, err)
+	assert.Equal(t, composeContent, string(result))
+}
+
+func TestConvert_YAMLNormalized(t *testing.T) {
+	result, err := Convert([]byte(composeContent), YAMLNormalized)
+	assert.NoError(t, err)
+
+	expected := `services:
+  app:
+    image: app:1.0
+    build:
+      context: ./app
+    environment:
+      DEBUG: null
+      REDIS_URL: redis:6379
+    ports:
+      - "8080:80"
+      - "9000"
+    restart: unless-stopped
+networks:
+  innernet:
+    driver: bridge
+volumes:
+  data: {}
+x-common:
+  restart: always
+`
+	assert.Equal(t, expected, string(result))
+	assert.NotContains(t, string(result), "#")
+}
+
+func TestConvert_JSON(t *testing.T) {
+	result, err := Convert([]byte(composeContent), JSON)
+	assert.NoError(t, err)
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(result, &decoded))
+
+	services := decoded["services"].(map[string]interface{})
+	app := services["app"].(map[string]interface{})
+	assert.Equal(t, "app:1.0", app["image"])
+	assert.Equal(t, []interface{}{"8080:80", "9000"}, app["ports"])
+
+	// Keys keep the canonical order instead of the alphabetical one
+	text := string(result)
+	assert.Less(t, strings.Index(text, `"services"`), strings.Index(text, `"networks"`))
+	assert.Less(t, strings.Index(text, `"image"`), strings.Index(text, `"build"`))
+}
+
+func TestConvert_InvalidYAML(t *testing.T) {
+	_, err := Convert([]byte("services: [unclosed"), JSON)
+	assert.Error(t, err)
+}
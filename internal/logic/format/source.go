@@ -0,0 +1,199 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// blockScalarPattern matches a line starting a literal or folded block scalar
+var blockScalarPattern = regexp.MustCompile(`(^|:|-)\s*[|>][-+0-9]*\s*(#.*)?$`)
+
+// SourceStyle describes the canonical formatting of service files
+type SourceStyle struct {
+	// KeyOrder is the order of the keys inside a service definition, ServiceKeyOrder when empty
+	KeyOrder []string
+	// Indent is the indentation of the service names
+	Indent int
+}
+
+// DetectIndent returns the indentation of the service names used by most of the service files,
+// 2 when there is no service file or no indentation is more common than the others
+func DetectIndent(files [][]byte) int {
+	counts := make(map[int]int)
+	for _, content := range files {
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil || len(node.Content) == 0 {
+			continue
+		}
+		if root := node.Content[0]; root.Kind == yaml.MappingNode && len(root.Content) > 0 {
+			counts[root.Content[0].Column-1]++
+		}
+	}
+
+	columns := make([]int, 0, len(counts))
+	for column := range counts {
+		columns = append(columns, column)
+	}
+	sort.Ints(columns)
+
+	indent, best := 2, counts[2]
+	for _, column := range columns {
+		if counts[column] > best {
+			indent, best = column, counts[column]
+		}
+	}
+	return indent
+}
+
+// FormatServiceFile returns the service file in the canonical style: the keys of every service in
+// canonical order, two spaces per indentation level, quoted port strings and a blank line between
+// services. Blank lines inside a service are dropped, except in block scalars. Comments are kept, the
+// comments at the top and the bottom of the file stay at column 0.
+func FormatServiceFile(content []byte, style SourceStyle) ([]byte, error) {
+	document, root, err := parseSource(content)
+	if err != nil || root == nil {
+		return content, err
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("service file must be a mapping of service names to definitions")
+	}
+
+	order := style.KeyOrder
+	if len(order) == 0 {
+		order = ServiceKeyOrder
+	}
+	for i := 1; i < len(root.Content); i += 2 {
+		service := root.Content[i]
+		if service.Kind != yaml.MappingNode {
+			continue
+		}
+		SortMapping(service, order)
+		for _, key := range []string{"ports", "expose"} {
+			if ports := mappingValue(service, key); ports != nil && ports.Kind == yaml.SequenceNode {
+				for _, item := range ports.Content {
+					if item.Kind == yaml.ScalarNode {
+						item.Tag, item.Style = "!!str", yaml.DoubleQuotedStyle
+					}
+				}
+			}
+		}
+	}
+
+	return encodeDocument(document, root, content, style.Indent, func(string) bool { return true })
+}
+
+// FormatTemplate returns the template in the canonical style: two spaces per indentation level and a
+// blank line between the top-level sections, and none inside them. The order of the sections and
+// comments are kept, and the service inclusion directive stays right below the services key.
+func FormatTemplate(content []byte) ([]byte, error) {
+	document, root, err := parseSource(content)
+	if err != nil || root == nil {
+		return content, err
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("template must be a mapping")
+	}
+
+	return encodeDocument(document, root, content, 0, func(key string) bool {
+		return !strings.HasPrefix(key, "<dcm")
+	})
+}
+
+// parseSource parses a service file or template and returns the document and its root node,
+// a nil root for an empty document
+func parseSource(content []byte) (*yaml.Node, *yaml.Node, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, nil, err
+	}
+	if len(node.Content) == 0 {
+		return &node, nil, nil
+	}
+	return &node, node.Content[0], nil
+}
+
+// encodeDocument encodes the entries of the mapping with encodeEntries. The comments of the document
+// are written at column 0 before and after them: the head comment of the document, the head comment of
+// the first entry if it starts at column 0 and the foot comment of the document.
+func encodeDocument(document, mapping *yaml.Node, content []byte, indent int, separate func(key string) bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if document.HeadComment != "" {
+		buf.WriteString(document.HeadComment + "\n\n")
+	}
+	if len(mapping.Content) > 0 {
+		if key := mapping.Content[0]; key.HeadComment != "" && commentAtColumnZero(content, key.Line) {
+			buf.WriteString(key.HeadComment + "\n")
+			key.HeadComment = ""
+		}
+	}
+
+	entries, err := encodeEntries(mapping, indent, separate)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(entries)
+
+	if document.FootComment != "" {
+		buf.WriteString("\n" + document.FootComment + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// commentAtColumnZero reports whether the last non-blank line above the line is a comment at column 0
+func commentAtColumnZero(content []byte, line int) bool {
+	lines := strings.Split(string(content), "\n")
+	for i := line - 2; i >= 0 && i < len(lines); i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			return strings.HasPrefix(lines[i], "#")
+		}
+	}
+	return false
+}
+
+// encodeEntries encodes every entry of the mapping on its own with the keys at the given indentation.
+// Entries for which separate returns true are separated from the previous entry by a blank line.
+func encodeEntries(mapping *yaml.Node, indent int, separate func(key string) bool) ([]byte, error) {
+	var buf bytes.Buffer
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+
+		var entry bytes.Buffer
+		encoder := yaml.NewEncoder(&entry)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, value}}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", key.Value, err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+
+		if i > 0 && separate(key.Value) {
+			buf.WriteByte('\n')
+		}
+		prefix := strings.Repeat(" ", indent)
+		// Blank lines inside an entry are dropped, e.g. the ones the encoder writes around comments,
+		// except in block scalars: those are the lines indented deeper than the line starting the scalar
+		scalarIndent := -1
+		for _, line := range strings.Split(strings.TrimRight(entry.String(), "\n"), "\n") {
+			lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+			if scalarIndent < 0 || (line != "" && lineIndent <= scalarIndent) {
+				scalarIndent = -1
+				if line == "" {
+					continue
+				}
+				if blockScalarPattern.MatchString(line) {
+					scalarIndent = lineIndent
+				}
+			}
+			if line != "" {
+				line = prefix + line
+			}
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
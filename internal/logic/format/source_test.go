@@ -0,0 +1,150 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatServiceFile(t *testing.T) {
+	content := `
+app:   # Main application
+      restart: unless-stopped
+      ports:
+          - 8080:80
+          - '9000'
+      command: |
+        first
+
+        second
+      image: app:1.0
+
+
+worker:
+  depends_on: [app]
+  image: worker # Background jobs
+`
+	expected := `  app: # Main application
+    image: app:1.0
+    command: |
+      first
+
+      second
+    ports:
+      - "8080:80"
+      - "9000"
+    restart: unless-stopped
+
+  worker:
+    image: worker # Background jobs
+    depends_on: [app]
+`
+	formatted, err := FormatServiceFile([]byte(content), SourceStyle{Indent: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(formatted))
+
+	// Formatting is idempotent
+	again, err := FormatServiceFile(formatted, SourceStyle{Indent: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(again))
+
+	// The key order is configurable
+	formatted, err = FormatServiceFile([]byte("web:\n  image: nginx\n  restart: always\n"), SourceStyle{KeyOrder: []string{"restart"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "web:\n  restart: always\n  image: nginx\n", string(formatted))
+}
+
+func TestFormatServiceFile_Comments(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "file_comment",
+			content:  "# Services of the shop\n\n# The web shop\napp:\n  image: app\n",
+			expected: "# Services of the shop\n\n# The web shop\n  app:\n    image: app\n",
+		},
+		{
+			name:     "service_comment",
+			content:  "  # The web shop\n  app:\n    image: app\n",
+			expected: "  # The web shop\n  app:\n    image: app\n",
+		},
+		{
+			name:     "foot_comment",
+			content:  "  app:\n    image: app\n\n# End of the services\n",
+			expected: "  app:\n    image: app\n\n# End of the services\n",
+		},
+		{
+			name: "blank_lines",
+			content: `  app:
+    image: app
+
+    # Published ports
+
+    ports:
+      - 80:80
+    # Old port
+
+    environment:
+      A: b
+
+  db:
+    image: db
+`,
+			expected: `  app:
+    image: app
+    environment:
+      A: b
+    # Published ports
+    ports:
+      - "80:80"
+    # Old port
+
+  db:
+    image: db
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted, err := FormatServiceFile([]byte(tt.content), SourceStyle{Indent: 2})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(formatted))
+
+			again, err := FormatServiceFile(formatted, SourceStyle{Indent: 2})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(again))
+		})
+	}
+}
+
+func TestFormatTemplate(t *testing.T) {
+	content := `services:
+<dcm: include services\>
+volumes:
+    data: {}   # Shared data
+# Networks
+networks:
+    front:
+`
+	formatted, err := FormatTemplate([]byte(content))
+	assert.NoError(t, err)
+	assert.Equal(t, `services:
+<dcm: include services\>
+
+volumes:
+  data: {} # Shared data
+
+# Networks
+networks:
+  front:
+`, string(formatted))
+}
+
+func TestDetectIndent(t *testing.T) {
+	assert.Equal(t, 2, DetectIndent(nil))
+	assert.Equal(t, 0, DetectIndent([][]byte{[]byte("a:\n  image: a\n"), []byte("b:\n  image: b\n"), []byte("  c:\n    image: c\n")}))
+	assert.Equal(t, 2, DetectIndent([][]byte{[]byte("a:\n  image: a\n"), []byte("  c:\n    image: c\n")}))
+}
@@ -0,0 +1,285 @@
+// Package graph draws the dependencies between the services of a project as DOT or Mermaid
+package graph
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/inventory"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Format identifies the output format of the graph
+type Format string
+
+const (
+	// DOT is the format of Graphviz
+	DOT Format = "dot"
+	// Mermaid is the flowchart format rendered by GitHub and GitLab in Markdown files
+	Mermaid Format = "mermaid"
+)
+
+// Options selects the optional nodes of the graph
+type Options struct {
+	// Networks adds a node for every network shared by at least two services
+	Networks bool
+	// Volumes adds a node for every named volume shared by at least two services
+	Volumes bool
+}
+
+// Graph is the dependency graph of the services of a project
+type Graph struct {
+	services []inventory.Service
+	defined  map[string]bool
+}
+
+// New creates the graph of the given services
+func New(services []inventory.Service) *Graph {
+	defined := make(map[string]bool, len(services))
+	for _, service := range services {
+		defined[service.Name] = true
+	}
+	return &Graph{services: services, defined: defined}
+}
+
+// Cycles returns the groups of services depending on each other, each group sorted by name
+func (g *Graph) Cycles() [][]string {
+	// Tarjan's algorithm for strongly connected components
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+
+	edges := make(map[string][]string, len(g.services))
+	selfLoops := make(map[string]bool)
+	for _, service := range g.services {
+		for _, dependency := range service.DependsOn {
+			if g.defined[dependency.Service] {
+				edges[service.Name] = append(edges[service.Name], dependency.Service)
+			}
+			if dependency.Service == service.Name {
+				selfLoops[service.Name] = true
+			}
+		}
+	}
+
+	var visit func(name string)
+	visit = func(name string) {
+		index[name] = len(index)
+		lowLink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, next := range edges[name] {
+			if _, visited := index[next]; !visited {
+				visit(next)
+				lowLink[name] = min(lowLink[name], lowLink[next])
+			} else if onStack[next] {
+				lowLink[name] = min(lowLink[name], index[next])
+			}
+		}
+
+		if lowLink[name] == index[name] {
+			var component []string
+			for {
+				last := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[last] = false
+				component = append(component, last)
+				if last == name {
+					break
+				}
+			}
+			if len(component) > 1 || selfLoops[name] {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+
+	for _, service := range g.services {
+		if _, visited := index[service.Name]; !visited {
+			visit(service.Name)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// Orphans returns the services that neither depend on another service nor are depended on
+func (g *Graph) Orphans() []string {
+	connected := make(map[string]bool)
+	for _, service := range g.services {
+		for _, dependency := range service.DependsOn {
+			connected[service.Name] = true
+			connected[dependency.Service] = true
+		}
+	}
+
+	var orphans []string
+	for _, service := range g.services {
+		if !connected[service.Name] {
+			orphans = append(orphans, service.Name)
+		}
+	}
+	return orphans
+}
+
+// Missing returns the dependencies on services that are not defined, as 'service -> dependency'
+func (g *Graph) Missing() []string {
+	var missing []string
+	for _, service := range g.services {
+		for _, dependency := range service.DependsOn {
+			if !g.defined[dependency.Service] {
+				missing = append(missing, service.Name+" -> "+dependency.Service)
+			}
+		}
+	}
+	return missing
+}
+
+// Render returns the graph in the given format
+func (g *Graph) Render(f Format, opts Options) (string, error) {
+	switch f {
+	case DOT:
+		return g.dot(opts), nil
+	case Mermaid:
+		return g.mermaid(opts), nil
+	}
+	return "", fmt.Errorf("unknown graph format '%v', expected one of: dot, mermaid", f)
+}
+
+// dot renders the graph in the Graphviz format
+func (g *Graph) dot(opts Options) string {
+	var b strings.Builder
+	b.WriteString("digraph services {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for _, service := range g.services {
+		fmt.Fprintf(&b, "  %q;\n", service.Name)
+	}
+	for _, service := range g.services {
+		for _, dependency := range service.DependsOn {
+			if dependency.Condition != "" {
+				fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", service.Name, dependency.Service, dependency.Condition)
+			} else {
+				fmt.Fprintf(&b, "  %q -> %q;\n", service.Name, dependency.Service)
+			}
+		}
+	}
+
+	for _, resource := range g.resources(opts) {
+		fmt.Fprintf(&b, "  %q [label=%q, shape=%v, style=dashed];\n", resource.id, resource.name, map[string]string{
+			"network": "hexagon",
+			"volume":  "cylinder",
+		}[resource.kind])
+		for _, service := range resource.services {
+			fmt.Fprintf(&b, "  %q -> %q [style=dashed, arrowhead=none];\n", service, resource.id)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaid renders the graph as a Mermaid flowchart
+func (g *Graph) mermaid(opts Options) string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	for _, service := range g.services {
+		fmt.Fprintf(&b, "  %v[%q]\n", mermaidID("service", service.Name), service.Name)
+	}
+	for _, service := range g.services {
+		for _, dependency := range service.DependsOn {
+			from, to := mermaidID("service", service.Name), mermaidID("service", dependency.Service)
+			if dependency.Condition != "" {
+				fmt.Fprintf(&b, "  %v -->|%v| %v\n", from, dependency.Condition, to)
+			} else {
+				fmt.Fprintf(&b, "  %v --> %v\n", from, to)
+			}
+		}
+	}
+
+	for _, resource := range g.resources(opts) {
+		id := mermaidID(resource.kind, resource.name)
+		if resource.kind == "network" {
+			fmt.Fprintf(&b, "  %v{{%q}}\n", id, resource.name)
+		} else {
+			fmt.Fprintf(&b, "  %v[(%q)]\n", id, resource.name)
+		}
+		for _, service := range resource.services {
+			fmt.Fprintf(&b, "  %v -.- %v\n", mermaidID("service", service), id)
+		}
+	}
+
+	return b.String()
+}
+
+// resource is a network or a named volume shared by several services
+type resource struct {
+	kind     string
+	name     string
+	id       string
+	services []string
+}
+
+// resources returns the shared networks and named volumes selected by the options, sorted by name
+func (g *Graph) resources(opts Options) []resource {
+	var resources []resource
+	collect := func(kind string, namesOf func(inventory.Service) []string) {
+		users := make(map[string][]string)
+		for _, service := range g.services {
+			for _, name := range namesOf(service) {
+				users[name] = append(users[name], service.Name)
+			}
+		}
+		var names []string
+		for name, services := range users {
+			if len(services) > 1 {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			resources = append(resources, resource{kind: kind, name: name, id: kind + ":" + name, services: users[name]})
+		}
+	}
+
+	if opts.Networks {
+		collect("network", func(service inventory.Service) []string {
+			var networks []string
+			for _, network := range service.Networks {
+				if !strings.HasPrefix(network, "network_mode:") {
+					networks = append(networks, network)
+				}
+			}
+			return networks
+		})
+	}
+	if opts.Volumes {
+		collect("volume", func(service inventory.Service) []string {
+			var volumes []string
+			for _, volume := range service.Volumes {
+				source, _, found := strings.Cut(volume, ":")
+				// Bind mounts start with a path, anonymous volumes have no source
+				if found && source != "" && !strings.ContainsAny(source[:1], "/.~$") {
+					volumes = append(volumes, source)
+				}
+			}
+			return volumes
+		})
+	}
+	return resources
+}
+
+// mermaidUnsafe matches the characters not allowed in Mermaid node identifiers
+var mermaidUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// mermaidID returns a Mermaid node identifier for the named node of the given kind
+func mermaidID(kind, name string) string {
+	return kind + "_" + mermaidUnsafe.ReplaceAllString(name, "_")
+}
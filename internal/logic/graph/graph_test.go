@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/inventory"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testServices() []inventory.Service {
+	return []inventory.Service{
+		{
+			Name:      "app",
+			Networks:  []string{"back", "front"},
+			Volumes:   []string{"data:/data", "./conf:/etc/app"},
+			DependsOn: []inventory.Dependency{{Service: "db", Condition: "service_healthy"}, {Service: "cache"}},
+		},
+		{Name: "db", Networks: []string{"back"}, Volumes: []string{"data:/var/lib/db"}},
+		{Name: "cache", Networks: []string{"back"}, Volumes: []string{"./conf:/etc/cache"}},
+		{Name: "mail-relay", Networks: []string{"front"}},
+	}
+}
+
+func TestRender_Mermaid(t *testing.T) {
+	output, err := New(testServices()).Render(Mermaid, Options{Networks: true, Volumes: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `flowchart LR
+  service_app["app"]
+  service_db["db"]
+  service_cache["cache"]
+  service_mail_relay["mail-relay"]
+  service_app -->|service_healthy| service_db
+  service_app --> service_cache
+  network_back{{"back"}}
+  service_app -.- network_back
+  service_db -.- network_back
+  service_cache -.- network_back
+  network_front{{"front"}}
+  service_app -.- network_front
+  service_mail_relay -.- network_front
+  volume_data[("data")]
+  service_app -.- volume_data
+  service_db -.- volume_data
+`, output)
+}
+
+func TestRender_DOT(t *testing.T) {
+	output, err := New(testServices()).Render(DOT, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, `digraph services {
+  rankdir=LR;
+  node [shape=box];
+  "app";
+  "db";
+  "cache";
+  "mail-relay";
+  "app" -> "db" [label="service_healthy"];
+  "app" -> "cache";
+}
+`, output)
+
+	_, err = New(nil).Render("svg", Options{})
+	assert.EqualError(t, err, "unknown graph format 'svg', expected one of: dot, mermaid")
+}
+
+func TestReport(t *testing.T) {
+	g := New([]inventory.Service{
+		{Name: "a", DependsOn: []inventory.Dependency{{Service: "b"}}},
+		{Name: "b", DependsOn: []inventory.Dependency{{Service: "c"}}},
+		{Name: "c", DependsOn: []inventory.Dependency{{Service: "a"}, {Service: "missing"}}},
+		{Name: "self", DependsOn: []inventory.Dependency{{Service: "self"}}},
+		{Name: "lonely"},
+	})
+
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"self"}}, g.Cycles())
+	assert.Equal(t, []string{"c -> missing"}, g.Missing())
+	assert.Equal(t, []string{"lonely"}, g.Orphans())
+	assert.Empty(t, New(testServices()).Cycles())
+}
@@ -0,0 +1,274 @@
+// Package inventory lists the services of a project with the properties relevant for audits and runbooks
+package inventory
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/helper"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
+)
+
+// Dependency is an entry of depends_on
+type Dependency struct {
+	Service string `json:"service"`
+	// Condition is the condition of the long syntax, e.g. service_healthy, empty for the short syntax
+	Condition string `json:"condition,omitempty"`
+}
+
+// Service is a single service of the project
+type Service struct {
+	Name string `json:"name"`
+	// Source is the slash-separated path of the file defining the service relative to the project directory
+	Source string `json:"source"`
+	// Line is the line of the service name in the source
+	Line  int    `json:"line"`
+	Image string `json:"image,omitempty"`
+	// Build is the build context
+	Build string `json:"build,omitempty"`
+	// Ports are the published host ports, with the host IP and protocol when given
+	Ports []string `json:"ports,omitempty"`
+	// Networks are the networks the service is attached to, or its network_mode
+	Networks []string `json:"networks,omitempty"`
+	// Volumes are the mounts as source:target
+	Volumes   []string     `json:"volumes,omitempty"`
+	DependsOn []Dependency `json:"depends_on,omitempty"`
+	Profiles  []string     `json:"profiles,omitempty"`
+	Restart   string       `json:"restart,omitempty"`
+}
+
+// DependencyNames returns the names of the services the service depends on
+func (s *Service) DependencyNames() []string {
+	names := make([]string, 0, len(s.DependsOn))
+	for _, dependency := range s.DependsOn {
+		names = append(names, dependency.Service)
+	}
+	return names
+}
+
+// FromProject reads the services of the project as they are assembled from the template and the
+// service files, ordered by file name, with the anchors of the template resolved. Every service
+// points at the file and line it is defined in.
+func FromProject(fs afero.Fs, buildDir, templatePath, servicesDir string) ([]Service, error) {
+	project, err := logic.ReadProject(fs, buildDir, templatePath, servicesDir)
+	if err != nil {
+		return nil, err
+	}
+	node, err := project.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	servicesNode := helper.FindServicesNode(node)
+	if servicesNode == nil || servicesNode.Tag == "!!null" {
+		return nil, nil
+	}
+	if servicesNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: services must be a mapping", logic.RelativePath(buildDir, templatePath))
+	}
+	return parseServices(servicesNode, func(line int) (string, int) {
+		source, line, _ := project.Position(line, 0)
+		return source, line
+	})
+}
+
+// FromCompose reads the services of a compose file in the order they are defined
+func FromCompose(fs afero.Fs, buildDir, composePath string) ([]Service, error) {
+	content, err := afero.ReadFile(fs, composePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	return Parse(logic.RelativePath(buildDir, composePath), content, true)
+}
+
+// Parse reads the services of a compose file, or of a service file when compose is false.
+// The source is only used to describe where the services come from.
+func Parse(source string, content []byte, compose bool) ([]Service, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	if len(node.Content) == 0 {
+		return nil, nil
+	}
+
+	servicesNode := node.Content[0]
+	if compose {
+		servicesNode = helper.FindServicesNode(&node)
+		if servicesNode == nil {
+			return nil, fmt.Errorf("%s: services section not found", source)
+		}
+	}
+	if servicesNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: services must be a mapping", source)
+	}
+
+	return parseServices(servicesNode, func(line int) (string, int) {
+		return source, line
+	})
+}
+
+// parseServices reads the services of the mapping node, position returns the file and line a line
+// of the parsed content comes from
+func parseServices(servicesNode *yaml.Node, position func(line int) (string, int)) ([]Service, error) {
+	var services []Service
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+		keyNode := servicesNode.Content[i]
+		source, line := position(keyNode.Line)
+		var definition map[string]interface{}
+		if err := servicesNode.Content[i+1].Decode(&definition); err != nil {
+			return nil, fmt.Errorf("%s:%d: failed to decode service %s: %w", source, line, keyNode.Value, err)
+		}
+		service := describe(definition)
+		service.Name, service.Source, service.Line = keyNode.Value, source, line
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// describe extracts the listed properties from the definition of a service
+func describe(definition map[string]interface{}) Service {
+	service := Service{
+		Image:   scalar(definition["image"]),
+		Restart: scalar(definition["restart"]),
+	}
+
+	switch build := definition["build"].(type) {
+	case string:
+		service.Build = build
+	case map[string]interface{}:
+		service.Build = scalar(build["context"])
+		if service.Build == "" {
+			service.Build = "."
+		}
+	}
+
+	for _, port := range list(definition["ports"]) {
+		if published := hostPort(port); published != "" {
+			service.Ports = append(service.Ports, published)
+		}
+	}
+
+	service.Networks = keys(definition["networks"])
+	if mode := scalar(definition["network_mode"]); mode != "" {
+		service.Networks = append(service.Networks, "network_mode:"+mode)
+	}
+
+	for _, volume := range list(definition["volumes"]) {
+		service.Volumes = append(service.Volumes, mount(volume))
+	}
+
+	switch dependsOn := definition["depends_on"].(type) {
+	case []interface{}:
+		for _, name := range dependsOn {
+			service.DependsOn = append(service.DependsOn, Dependency{Service: scalar(name)})
+		}
+	case map[string]interface{}:
+		for _, name := range keys(dependsOn) {
+			dependency := Dependency{Service: name}
+			if options, ok := dependsOn[name].(map[string]interface{}); ok {
+				dependency.Condition = scalar(options["condition"])
+			}
+			service.DependsOn = append(service.DependsOn, dependency)
+		}
+	}
+
+	for _, profile := range list(definition["profiles"]) {
+		service.Profiles = append(service.Profiles, scalar(profile))
+	}
+
+	return service
+}
+
+// hostPort returns the published part of a port mapping, empty if the port is not published.
+// Short syntax: [HOST_IP:]HOST:CONTAINER[/PROTOCOL], long syntax: a mapping with published.
+func hostPort(port interface{}) string {
+	if long, ok := port.(map[string]interface{}); ok {
+		published := scalar(long["published"])
+		if published == "" {
+			return ""
+		}
+		if ip := scalar(long["host_ip"]); ip != "" {
+			published = ip + ":" + published
+		}
+		if protocol := scalar(long["protocol"]); protocol != "" && protocol != "tcp" {
+			published += "/" + protocol
+		}
+		return published
+	}
+
+	mapping, protocol, _ := strings.Cut(scalar(port), "/")
+	ip := ""
+	// An IPv6 host IP is enclosed in brackets and contains colons itself
+	if strings.HasPrefix(mapping, "[") {
+		if end := strings.Index(mapping, "]:"); end >= 0 {
+			ip, mapping = mapping[:end+1], mapping[end+2:]
+		}
+	}
+	parts := strings.Split(mapping, ":")
+	if len(parts) < 2 {
+		return ""
+	}
+	published := parts[len(parts)-2]
+	if len(parts) > 2 {
+		ip = parts[0]
+	}
+	if ip != "" {
+		published = ip + ":" + published
+	}
+	if protocol != "" && protocol != "tcp" {
+		published += "/" + protocol
+	}
+	return published
+}
+
+// mount returns a volume entry as source:target, or the target alone for anonymous volumes
+func mount(volume interface{}) string {
+	if long, ok := volume.(map[string]interface{}); ok {
+		source, target := scalar(long["source"]), scalar(long["target"])
+		if source == "" {
+			return target
+		}
+		return source + ":" + target
+	}
+
+	parts := strings.Split(scalar(volume), ":")
+	// Drop the access mode, e.g. ro or rw
+	if len(parts) == 3 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, ":")
+}
+
+// scalar returns a scalar value as a string, empty for missing values
+func scalar(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// list returns the items of a sequence, nil for anything else
+func list(value interface{}) []interface{} {
+	items, _ := value.([]interface{})
+	return items
+}
+
+// keys returns the items of a sequence or the sorted keys of a mapping as strings
+func keys(value interface{}) []string {
+	var result []string
+	switch typed := value.(type) {
+	case []interface{}:
+		for _, item := range typed {
+			result = append(result, scalar(item))
+		}
+	case map[string]interface{}:
+		for key := range typed {
+			result = append(result, key)
+		}
+		sort.Strings(result)
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package inventory
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_Compose(t *testing.T) {
+	content := `# Generated by dcm
+services:
+  app:
+    build:
+      dockerfile: Dockerfile
+    ports:
+      - "8080:80"
+      - 127.0.0.1:9090:90/udp
+      - "[::1]:7000:7000"
+      - "3000"
+      - target: 443
+        published: 8443
+    networks: [front, back]
+    volumes:
+      - data:/data:ro
+      - ./conf:/etc/app
+      - type: tmpfs
+        target: /tmp
+    depends_on:
+      redis:
+        condition: service_healthy
+      db:
+        condition: service_started
+    profiles: [web]
+    restart: unless-stopped
+  redis:
+    image: redis:alpine
+    network_mode: host
+    depends_on: [db]
+`
+
+	services, err := Parse("docker-compose.yml", []byte(content), true)
+	assert.NoError(t, err)
+	assert.Equal(t, []Service{
+		{
+			Name:     "app",
+			Source:   "docker-compose.yml",
+			Line:     3,
+			Build:    ".",
+			Ports:    []string{"8080", "127.0.0.1:9090/udp", "[::1]:7000", "8443"},
+			Networks: []string{"front", "back"},
+			Volumes:  []string{"data:/data", "./conf:/etc/app", "/tmp"},
+			DependsOn: []Dependency{
+				{Service: "db", Condition: "service_started"},
+				{Service: "redis", Condition: "service_healthy"},
+			},
+			Profiles: []string{"web"},
+			Restart:  "unless-stopped",
+		},
+		{
+			Name:      "redis",
+			Source:    "docker-compose.yml",
+			Line:      26,
+			Image:     "redis:alpine",
+			Networks:  []string{"network_mode:host"},
+			DependsOn: []Dependency{{Service: "db"}},
+		},
+	}, services)
+	assert.Equal(t, []string{"db", "redis"}, services[0].DependencyNames())
+}
+
+func TestParse_NoServices(t *testing.T) {
+	_, err := Parse("docker-compose.yml", []byte("volumes:\n  data:\n"), true)
+	assert.EqualError(t, err, "docker-compose.yml: services section not found")
+}
+
+func TestFromProject(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	template := "x-common: &common\n  restart: unless-stopped\n\nservices:\n<dcm: include services\\>\n"
+	assert.NoError(t, afero.WriteFile(fs, "/project/docker-compose-dcm.yml", []byte(template), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/web.yml", []byte("  web:\n    image: nginx\n  proxy:\n    image: traefik\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/app.yml", []byte("app:\n  <<: *common\n  build: ./app\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/notes.txt", []byte("not a service"), 0644))
+
+	services, err := FromProject(fs, "/project", "/project/docker-compose-dcm.yml", "/project/services")
+	assert.NoError(t, err)
+
+	var described []string
+	for _, service := range services {
+		described = append(described, fmt.Sprintf("%v %v:%d %v%v %v", service.Name, service.Source, service.Line, service.Image, service.Build, service.Restart))
+	}
+	assert.Equal(t, []string{
+		"app services/app.yml:1 ./app unless-stopped",
+		"web services/web.yml:1 nginx ",
+		"proxy services/web.yml:3 traefik ",
+	}, described)
+}
@@ -0,0 +1,402 @@
+// Package lint checks the services of a project against built-in rules for common compose mistakes.
+// Every finding points at the service file and line it comes from.
+package lint
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/helper"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"strings"
+)
+
+// Severity is the level of a finding, the values are the levels of SARIF
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Note    Severity = "note"
+	// Off disables the rule
+	Off Severity = "off"
+)
+
+// rank orders the severities, a higher rank is more severe
+var rank = map[Severity]int{Off: 0, Note: 1, Warning: 2, Error: 3}
+
+// ParseSeverity returns the severity with the given name
+func ParseSeverity(name string) (Severity, error) {
+	severity := Severity(strings.ToLower(name))
+	if _, ok := rank[severity]; !ok {
+		return "", fmt.Errorf("unknown severity '%v', expected one of: error, warning, note, off", name)
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether the severity is at least as severe as the other one
+func (s Severity) AtLeast(other Severity) bool {
+	return rank[s] >= rank[other]
+}
+
+// Rule is a built-in check of a service definition
+type Rule struct {
+	ID          string
+	Description string
+	// Severity is the default severity of the findings
+	Severity Severity
+
+	check func(definition *yaml.Node) []hit
+}
+
+// hit is a violation of a rule, node is the place it is reported at, nil for the service name
+type hit struct {
+	node    *yaml.Node
+	message string
+}
+
+// Finding is a violation of a rule by a service
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Service  string   `json:"service"`
+	// Source is the slash-separated path of the file defining the service relative to the project directory
+	Source  string `json:"source"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// String returns the finding in the file:line:column format of compilers
+func (f Finding) String() string {
+	return fmt.Sprintf("%v:%d:%d: %v: %v (%v)", f.Source, f.Line, f.Column, f.Severity, f.Message, f.Rule)
+}
+
+// rules are the built-in rules in the order they are checked
+var rules = []Rule{
+	{ID: "image-latest", Description: "Images should be pinned to a tag other than latest", Severity: Warning, check: checkImageTag},
+	{ID: "restart-missing", Description: "Services should have a restart policy", Severity: Warning, check: checkRestart},
+	{ID: "healthcheck-missing", Description: "Services should have a healthcheck", Severity: Note, check: checkHealthcheck},
+	{ID: "privileged", Description: "Services should not run privileged", Severity: Error, check: checkPrivileged},
+	{ID: "host-network", Description: "Services should not use the host network", Severity: Warning, check: checkHostNetwork},
+	{ID: "docker-socket", Description: "The Docker socket should not be mounted into a container", Severity: Error, check: checkDockerSocket},
+	{ID: "logging-limits", Description: "Container logs should be limited in size", Severity: Warning, check: checkLoggingLimits},
+}
+
+// Rules returns the built-in rules
+func Rules() []Rule {
+	return append([]Rule(nil), rules...)
+}
+
+// mergeTag is the tag of the << key merging mappings into a mapping
+const mergeTag = "!!merge"
+
+// ignorePattern matches the inline comments disabling rules, e.g. # dcm:ignore image-latest, privileged
+var ignorePattern = regexp.MustCompile(`#\s*dcm:ignore\s+([\w\-]+(?:[\s,]+[\w\-]+)*)`)
+
+// Linter checks services against the built-in rules
+type Linter struct {
+	fs         afero.Fs
+	severities map[string]Severity
+}
+
+// NewLinter creates a new instance of Linter with the default severities of the rules
+func NewLinter() *Linter {
+	severities := make(map[string]Severity)
+	for _, rule := range rules {
+		severities[rule.ID] = rule.Severity
+	}
+	return &Linter{
+		fs:         afero.NewOsFs(),
+		severities: severities,
+	}
+}
+
+// SetFs selects the file system the files are read from
+func (l *Linter) SetFs(fs afero.Fs) {
+	l.fs = fs
+}
+
+// SetSeverity changes the severity of the findings of a rule, Off disables the rule
+func (l *Linter) SetSeverity(id string, severity Severity) error {
+	if _, ok := l.severities[id]; !ok {
+		return fmt.Errorf("unknown rule '%v'", id)
+	}
+	if _, ok := rank[severity]; !ok {
+		return fmt.Errorf("unknown severity '%v' for rule '%v'", severity, id)
+	}
+	l.severities[id] = severity
+	return nil
+}
+
+// Severity returns the severity of the findings of a rule
+func (l *Linter) Severity(id string) Severity {
+	return l.severities[id]
+}
+
+// Project checks the services of the project as they are assembled from the template and the service
+// files, so anchors defined in the template are resolved. Every finding points at the file and line
+// of the template or the service file it comes from.
+func (l *Linter) Project(buildDir, templatePath, servicesDir string) ([]Finding, error) {
+	project, err := logic.ReadProject(l.fs, buildDir, templatePath, servicesDir)
+	if err != nil {
+		return nil, err
+	}
+	node, err := project.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	servicesNode := helper.FindServicesNode(node)
+	if servicesNode == nil || servicesNode.Tag == "!!null" {
+		return nil, nil
+	}
+	if servicesNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: services must be a mapping", logic.RelativePath(buildDir, templatePath))
+	}
+	return l.check(servicesNode, string(project.Content), project.Position), nil
+}
+
+// Compose checks the services of a compose file
+func (l *Linter) Compose(buildDir, composePath string) ([]Finding, error) {
+	content, err := afero.ReadFile(l.fs, composePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	return l.Lint(logic.RelativePath(buildDir, composePath), content, true)
+}
+
+// Lint checks the services of a compose file, or of a service file when compose is false.
+// A # dcm:ignore comment drops the findings of its rules on its line, or on the next line when
+// it stands on a line of its own. On the line of a service name it applies to the whole service.
+// The source is used as the location of the findings.
+func (l *Linter) Lint(source string, content []byte, compose bool) ([]Finding, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	if len(node.Content) == 0 {
+		return nil, nil
+	}
+
+	servicesNode := node.Content[0]
+	if compose {
+		servicesNode = helper.FindServicesNode(&node)
+		if servicesNode == nil {
+			return nil, fmt.Errorf("%s: services section not found", source)
+		}
+	}
+	if servicesNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: services must be a mapping", source)
+	}
+
+	return l.check(servicesNode, string(content), func(line, column int) (string, int, int) {
+		return source, line, column
+	}), nil
+}
+
+// check checks the services of the mapping node, position returns the file, line and column the
+// findings at a line and column of the content are reported at
+func (l *Linter) check(servicesNode *yaml.Node, content string, position func(line, column int) (string, int, int)) []Finding {
+	ignored := ignoredRules(content)
+	var findings []Finding
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+		key, definition := servicesNode.Content[i], resolve(servicesNode.Content[i+1])
+		if definition.Kind != yaml.MappingNode {
+			continue
+		}
+		for _, rule := range rules {
+			severity := l.severities[rule.ID]
+			if severity == Off {
+				continue
+			}
+			for _, h := range rule.check(definition) {
+				at := h.node
+				if at == nil {
+					at = key
+				}
+				if ignored[at.Line][rule.ID] || ignored[key.Line][rule.ID] {
+					continue
+				}
+				source, line, column := position(at.Line, at.Column)
+				findings = append(findings, Finding{
+					Rule:     rule.ID,
+					Severity: severity,
+					Service:  key.Value,
+					Source:   source,
+					Line:     line,
+					Column:   column,
+					Message:  fmt.Sprintf("service %v: %v", key.Value, h.message),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// ignoredRules returns the rules ignored per line. A comment after a value applies to its own line,
+// a comment on a line of its own applies to the next line.
+func ignoredRules(content string) map[int]map[string]bool {
+	ignored := make(map[int]map[string]bool)
+	for i, line := range strings.Split(content, "\n") {
+		match := ignorePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		lineNumber := i + 1
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lineNumber++
+		}
+		if ignored[lineNumber] == nil {
+			ignored[lineNumber] = make(map[string]bool)
+		}
+		for _, id := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			ignored[lineNumber][id] = true
+		}
+	}
+	return ignored
+}
+
+// checkImageTag reports images without a tag or with the latest tag. Images given by a variable
+// and images pinned by digest are not reported.
+func checkImageTag(definition *yaml.Node) []hit {
+	key, image := mappingEntry(definition, "image")
+	if image == nil || image.Kind != yaml.ScalarNode || strings.Contains(image.Value, "$") || strings.Contains(image.Value, "@") {
+		return nil
+	}
+	// The tag follows the last colon after the last slash, a colon before it belongs to a registry port
+	name := image.Value[strings.LastIndex(image.Value, "/")+1:]
+	_, tag, found := strings.Cut(name, ":")
+	switch {
+	case !found:
+		return []hit{{key, fmt.Sprintf("image '%v' has no tag and uses latest", image.Value)}}
+	case tag == "latest":
+		return []hit{{key, fmt.Sprintf("image '%v' uses the latest tag", image.Value)}}
+	}
+	return nil
+}
+
+// checkRestart reports services without restart or deploy.restart_policy
+func checkRestart(definition *yaml.Node) []hit {
+	if _, restart := mappingEntry(definition, "restart"); restart != nil {
+		return nil
+	}
+	if _, deploy := mappingEntry(definition, "deploy"); deploy != nil {
+		if _, policy := mappingEntry(deploy, "restart_policy"); policy != nil {
+			return nil
+		}
+	}
+	return []hit{{nil, "no restart policy"}}
+}
+
+// checkHealthcheck reports services without a healthcheck or with a disabled one
+func checkHealthcheck(definition *yaml.Node) []hit {
+	key, healthcheck := mappingEntry(definition, "healthcheck")
+	if healthcheck == nil {
+		return []hit{{nil, "no healthcheck"}}
+	}
+	if _, disable := mappingEntry(healthcheck, "disable"); disable != nil && disable.Value == "true" {
+		return []hit{{key, "healthcheck is disabled"}}
+	}
+	return nil
+}
+
+// checkPrivileged reports privileged: true
+func checkPrivileged(definition *yaml.Node) []hit {
+	if key, privileged := mappingEntry(definition, "privileged"); privileged != nil && privileged.Value == "true" {
+		return []hit{{key, "runs privileged"}}
+	}
+	return nil
+}
+
+// checkHostNetwork reports network_mode: host
+func checkHostNetwork(definition *yaml.Node) []hit {
+	if key, mode := mappingEntry(definition, "network_mode"); mode != nil && mode.Value == "host" {
+		return []hit{{key, "uses the host network"}}
+	}
+	return nil
+}
+
+// checkDockerSocket reports bind mounts of /var/run/docker.sock in the short and the long syntax
+func checkDockerSocket(definition *yaml.Node) []hit {
+	_, volumes := mappingEntry(definition, "volumes")
+	if volumes == nil || volumes.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	var hits []hit
+	for _, volume := range volumes.Content {
+		volume = resolve(volume)
+		source := ""
+		switch volume.Kind {
+		case yaml.ScalarNode:
+			source, _, _ = strings.Cut(volume.Value, ":")
+		case yaml.MappingNode:
+			if _, value := mappingEntry(volume, "source"); value != nil {
+				source = value.Value
+			}
+		}
+		if source == "/var/run/docker.sock" || source == "/run/docker.sock" {
+			hits = append(hits, hit{volume, fmt.Sprintf("mounts the Docker socket %v", source)})
+		}
+	}
+	return hits
+}
+
+// checkLoggingLimits reports services whose logs are written by json-file or local, the default
+// driver, without a max-size option. Other drivers keep the logs outside of the host.
+func checkLoggingLimits(definition *yaml.Node) []hit {
+	key, logging := mappingEntry(definition, "logging")
+	if logging == nil {
+		return []hit{{nil, "no logging limits"}}
+	}
+	if _, driver := mappingEntry(logging, "driver"); driver != nil && driver.Value != "json-file" && driver.Value != "local" {
+		return nil
+	}
+	if _, options := mappingEntry(logging, "options"); options != nil {
+		if _, maxSize := mappingEntry(options, "max-size"); maxSize != nil {
+			return nil
+		}
+	}
+	return []hit{{key, "logging has no max-size option"}}
+}
+
+// mappingEntry returns the key and the value of the entry in the mapping node, nil if the key is missing.
+// The entries merged with << are looked up after the entries of the mapping, which override them.
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	node = resolve(node)
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].ShortTag() != mergeTag && node.Content[i].Value == key {
+			return node.Content[i], resolve(node.Content[i+1])
+		}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].ShortTag() != mergeTag {
+			continue
+		}
+		// The value is a mapping or a sequence of mappings, the first ones take precedence
+		merged := resolve(node.Content[i+1])
+		sources := []*yaml.Node{merged}
+		if merged.Kind == yaml.SequenceNode {
+			sources = merged.Content
+		}
+		for _, source := range sources {
+			if entryKey, value := mappingEntry(source, key); value != nil {
+				return entryKey, value
+			}
+		}
+	}
+	return nil, nil
+}
+
+// resolve returns the node an alias refers to, or the node itself
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
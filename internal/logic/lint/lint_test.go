@@ -0,0 +1,174 @@
+package lint
+
+import (
+	"encoding/json"
+	"github.com/spf13/afero"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serviceFileContent violates every rule once, except for the ignored ones
+const serviceFileContent = `  app:
+    image: registry:5000/app
+    privileged: true
+    network_mode: host
+    volumes:
+      - ./data:/data
+      - /var/run/docker.sock:/var/run/docker.sock
+    logging:
+      driver: json-file
+  worker: # dcm:ignore logging-limits
+    image: worker:latest # dcm:ignore image-latest
+    restart: unless-stopped
+    healthcheck:
+      test: ["CMD", "true"]
+    volumes:
+      # dcm:ignore docker-socket
+      - type: bind
+        source: /var/run/docker.sock
+        target: /var/run/docker.sock
+  proxy: # dcm:ignore restart-missing, healthcheck-missing
+    image: nginx:latest
+    restart: always
+    logging:
+      driver: syslog
+`
+
+func TestLint(t *testing.T) {
+	findings, err := NewLinter().Lint("services/app.yml", []byte(serviceFileContent), false)
+	assert.NoError(t, err)
+
+	var locations []string
+	for _, finding := range findings {
+		locations = append(locations, finding.String())
+	}
+	assert.Equal(t, []string{
+		"services/app.yml:2:5: warning: service app: image 'registry:5000/app' has no tag and uses latest (image-latest)",
+		"services/app.yml:1:3: warning: service app: no restart policy (restart-missing)",
+		"services/app.yml:1:3: note: service app: no healthcheck (healthcheck-missing)",
+		"services/app.yml:3:5: error: service app: runs privileged (privileged)",
+		"services/app.yml:4:5: warning: service app: uses the host network (host-network)",
+		"services/app.yml:7:9: error: service app: mounts the Docker socket /var/run/docker.sock (docker-socket)",
+		"services/app.yml:8:5: warning: service app: logging has no max-size option (logging-limits)",
+		"services/app.yml:21:5: warning: service proxy: image 'nginx:latest' uses the latest tag (image-latest)",
+	}, locations)
+}
+
+func TestLint_Compose(t *testing.T) {
+	content := `services:
+  db:
+    image: postgres:16
+    restart: always
+    healthcheck:
+      disable: true
+    logging:
+      options:
+        max-size: 10m
+`
+	linter := NewLinter()
+	assert.NoError(t, linter.SetSeverity("healthcheck-missing", Error))
+	assert.Error(t, linter.SetSeverity("unknown", Error))
+
+	findings, err := linter.Lint("docker-compose.yml", []byte(content), true)
+	assert.NoError(t, err)
+	assert.Equal(t, []Finding{{
+		Rule:     "healthcheck-missing",
+		Severity: Error,
+		Service:  "db",
+		Source:   "docker-compose.yml",
+		Line:     5,
+		Column:   5,
+		Message:  "service db: healthcheck is disabled",
+	}}, findings)
+
+	assert.NoError(t, linter.SetSeverity("healthcheck-missing", Off))
+	findings, err = linter.Lint("docker-compose.yml", []byte(content), true)
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestLinter_ProjectSARIF(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/project/docker-compose-dcm.yml", []byte("services:\n<dcm: include services\\>\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/redis.yml", []byte("redis:\n  image: redis\n  restart: always\n  healthcheck:\n    test: [\"CMD\", \"redis-cli\", \"ping\"]\n  logging:\n    options:\n      max-size: 10m\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/notes.txt", []byte("not a service"), 0644))
+
+	linter := NewLinter()
+	linter.SetFs(fs)
+	findings, err := linter.Project("/project", "/project/docker-compose-dcm.yml", "/project/services")
+	assert.NoError(t, err)
+	assert.Len(t, findings, 1)
+
+	output, err := linter.SARIF(findings, "1.0.0", "https://example.com")
+	assert.NoError(t, err)
+
+	var log sarifLog
+	assert.NoError(t, json.Unmarshal(output, &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Len(t, log.Runs[0].Tool.Driver.Rules, len(Rules()))
+	result := log.Runs[0].Results[0]
+	assert.Equal(t, "image-latest", result.RuleID)
+	assert.Equal(t, 0, result.RuleIndex)
+	assert.Equal(t, Warning, result.Level)
+	assert.Equal(t, "services/redis.yml", result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, 2, result.Locations[0].PhysicalLocation.Region.StartLine)
+}
+
+func TestLinter_ProjectAnchors(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	template := `x-common: &common
+  restart: unless-stopped
+  logging:
+    driver: json-file
+  healthcheck:
+    disable: true
+
+services:
+<dcm: include services\>
+`
+	assert.NoError(t, afero.WriteFile(fs, "/project/docker-compose-dcm.yml", []byte(template), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/app.yml", []byte("  app:\n    <<: *common\n    image: app:1.0\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/db.yml", []byte("db:\n  <<: *common\n  image: postgres\n"), 0644))
+
+	linter := NewLinter()
+	linter.SetFs(fs)
+	findings, err := linter.Project("/project", "/project/docker-compose-dcm.yml", "/project/services")
+	assert.NoError(t, err)
+
+	var locations []string
+	for _, finding := range findings {
+		locations = append(locations, finding.String())
+	}
+	// The merged restart policy is found, the merged options are reported in the template
+	assert.Equal(t, []string{
+		"docker-compose-dcm.yml:5:3: note: service app: healthcheck is disabled (healthcheck-missing)",
+		"docker-compose-dcm.yml:3:3: warning: service app: logging has no max-size option (logging-limits)",
+		"services/db.yml:3:3: warning: service db: image 'postgres' has no tag and uses latest (image-latest)",
+		"docker-compose-dcm.yml:5:3: note: service db: healthcheck is disabled (healthcheck-missing)",
+		"docker-compose-dcm.yml:3:3: warning: service db: logging has no max-size option (logging-limits)",
+	}, locations)
+
+	// A syntax error is reported in the file it is in
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/db.yml", []byte("db:\n  image: [postgres\n"), 0644))
+	_, err = linter.Project("/project", "/project/docker-compose-dcm.yml", "/project/services")
+	assert.ErrorContains(t, err, "services/db.yml:")
+}
+
+func TestLint_ComposeMergeKeys(t *testing.T) {
+	content := `x-defaults: &defaults
+  restart: always
+  logging:
+    options:
+      max-size: 10m
+services:
+  app:
+    <<: [*defaults]
+    image: app:1.0
+    healthcheck:
+      test: ["CMD", "true"]
+`
+	findings, err := NewLinter().Lint("docker-compose.yml", []byte(content), true)
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+}
@@ -0,0 +1,182 @@
+// Package logic /*
+/*
+Copyright © 2024 Benek <benek2048@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// servicesDirective is the line of the template the service files are included at
+const servicesDirective = "<dcm: include services\\>"
+
+// servicesIndent is the indentation of the service names in the services section
+const servicesIndent = 2
+
+// errorLinePattern matches the line number in the messages of the YAML parser
+var errorLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// origin is the place in the template or a service file a line of the project comes from
+type origin struct {
+	// source is the slash-separated path of the file relative to the project directory
+	source string
+	line   int
+	// shift is the number of spaces added in front of the line to indent it as a service
+	shift int
+}
+
+// Project is the template with the service inclusion directive replaced by the service files in
+// order of their names, the way the builders include them. Service files written without the
+// indentation of the services section are indented. The origin of every line is kept, so the
+// services can be read with the anchors of the template resolved and still point at their files.
+type Project struct {
+	Content []byte
+	origins []origin
+}
+
+// ReadProject reads the template and the service files of the project and assembles them
+func ReadProject(fs afero.Fs, buildDir, templatePath, servicesDir string) (*Project, error) {
+	template, err := afero.ReadFile(fs, templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	names, err := ReadServiceFiles(fs, servicesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+
+	var services []ServiceFile
+	for _, name := range names {
+		content, err := afero.ReadFile(fs, filepath.Join(servicesDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service file %s: %w", name, err)
+		}
+		services = append(services, ServiceFile{Name: RelativePath(buildDir, filepath.Join(servicesDir, name)), Content: content})
+	}
+
+	return AssembleProject(RelativePath(buildDir, templatePath), template, services)
+}
+
+// AssembleProject assembles the template and the service files, their names are used as the sources
+// of the lines
+func AssembleProject(templateName string, template []byte, services []ServiceFile) (*Project, error) {
+	before, after, found := bytes.Cut(template, []byte(servicesDirective))
+	if !found {
+		return nil, fmt.Errorf("%v: no '%v' directive", templateName, servicesDirective)
+	}
+
+	sorted := append([]ServiceFile(nil), services...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	// Every written part starts at an offset of the content and at a line of its file
+	type part struct {
+		offset int
+		origin origin
+	}
+	var content bytes.Buffer
+	var parts []part
+	write := func(text string, o origin) {
+		parts = append(parts, part{content.Len(), o})
+		content.WriteString(text)
+	}
+
+	write(string(before), origin{source: templateName, line: 1})
+	for _, service := range sorted {
+		shift := serviceShift(service.Content)
+		line := 0
+		scanner := bufio.NewScanner(bytes.NewReader(service.Content))
+		for scanner.Scan() {
+			line++
+			text := scanner.Text()
+			if text != "" {
+				text = strings.Repeat(" ", shift) + text
+			}
+			write(text+"\n", origin{source: service.Name, line: line, shift: shift})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read service file %s: %w", service.Name, err)
+		}
+		write("\n", origin{source: service.Name, line: line + 1})
+	}
+	write(string(after), origin{source: templateName, line: bytes.Count(before, []byte("\n")) + 1})
+
+	project := &Project{Content: content.Bytes()}
+	current := 0
+	for offset, lineStart := 0, true; offset <= len(project.Content); offset++ {
+		if lineStart {
+			for current+1 < len(parts) && parts[current+1].offset <= offset {
+				current++
+			}
+			o := parts[current].origin
+			o.line += bytes.Count(project.Content[parts[current].offset:offset], []byte("\n"))
+			project.origins = append(project.origins, o)
+		}
+		lineStart = offset < len(project.Content) && project.Content[offset] == '\n'
+	}
+	return project, nil
+}
+
+// Parse parses the assembled content. The line of a syntax error is the line of the file it is in.
+func (p *Project) Parse() (*yaml.Node, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(p.Content, &node); err != nil {
+		message := errorLinePattern.ReplaceAllStringFunc(err.Error(), func(match string) string {
+			line, _ := strconv.Atoi(strings.TrimPrefix(match, "line "))
+			source, line, _ := p.Position(line, 0)
+			return fmt.Sprintf("%v:%d", source, line)
+		})
+		return nil, fmt.Errorf("failed to parse the services: %v", message)
+	}
+	return &node, nil
+}
+
+// Position returns the file, line and column a position in the assembled content comes from
+func (p *Project) Position(line, column int) (string, int, int) {
+	if line < 1 || line > len(p.origins) {
+		return "", line, column
+	}
+	o := p.origins[line-1]
+	if column > o.shift {
+		column -= o.shift
+	}
+	return o.source, o.line, column
+}
+
+// serviceShift returns the number of spaces to add in front of the lines of a service file to indent
+// its services like the services section, zero for files already indented
+func serviceShift(content []byte) int {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line[0] != ' ' {
+			return servicesIndent
+		}
+		return 0
+	}
+	return 0
+}
@@ -0,0 +1,339 @@
+// Package provenance records which sources a compose file was generated from and detects
+// whether the generated file is stale or was edited by hand afterwards
+package provenance
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// headerFirstLinePrefix starts the first line of the provenance header
+	headerFirstLinePrefix = "# Generated by dcm "
+	// sourcePrefix starts a header line describing a single source file
+	sourcePrefix = "# dcm:source "
+	// servicePrefix starts a header line holding the hash of a single generated service
+	servicePrefix = "# dcm:service "
+	// sectionsPrefix starts the header line holding the hash of the generated sections other than services
+	sectionsPrefix = "# dcm:sections "
+	// outputPrefix starts the header line holding the hash of the generated content
+	outputPrefix = "# dcm:output "
+)
+
+// Source is a file the compose file was generated from
+type Source struct {
+	// Path is the path of the source relative to the build directory, using forward slashes
+	Path string
+	// Hash is the content hash of the source
+	Hash string
+}
+
+// Header is the provenance information written at the top of a generated compose file
+type Header struct {
+	Version string
+	Sources []Source
+	// Services are the hashes of the generated services by name, and Sections the hash of the other
+	// sections, see Parts. They tell which parts of a hand-edited compose file were edited.
+	Services   map[string]string
+	Sections   string
+	OutputHash string
+}
+
+// Hash returns the content hash used in provenance headers
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// NewSource creates the source entry of a file read from the build directory
+func NewSource(buildDir, filePath string, content []byte) Source {
+	return Source{Path: logic.RelativePath(buildDir, filePath), Hash: Hash(content)}
+}
+
+// Stamp prepends the provenance header to the generated content
+func Stamp(version string, sources []Source, body []byte) []byte {
+	header := Header{Version: version, Sources: sources, OutputHash: Hash(body)}
+	// Content that is not valid YAML still gets the header, without the hashes of its parts
+	if services, sections, err := Parts(body); err == nil {
+		header.Services = services
+		header.Sections = sections
+	}
+	var buf bytes.Buffer
+	buf.WriteString(header.Render())
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// Render returns the header as YAML comment lines
+func (h *Header) Render() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s%s. Do not edit by hand: edit the sources and run 'dcm build'.\n", headerFirstLinePrefix, h.Version))
+	for _, source := range h.Sources {
+		sb.WriteString(fmt.Sprintf("%s%s %s\n", sourcePrefix, quoteName(source.Path), source.Hash))
+	}
+	names := make([]string, 0, len(h.Services))
+	for name := range h.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("%s%s %s\n", servicePrefix, quoteName(name), h.Services[name]))
+	}
+	if h.Sections != "" {
+		sb.WriteString(fmt.Sprintf("%s%s\n", sectionsPrefix, h.Sections))
+	}
+	sb.WriteString(fmt.Sprintf("%s%s\n", outputPrefix, h.OutputHash))
+	return sb.String()
+}
+
+// Parse splits the content into its provenance header and the generated body.
+// The returned header is nil if the content has no provenance header.
+func Parse(content []byte) (*Header, []byte, error) {
+	if !bytes.HasPrefix(content, []byte(headerFirstLinePrefix)) {
+		return nil, content, nil
+	}
+
+	header := &Header{}
+	rest := content
+	for lineNumber := 0; ; lineNumber++ {
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			return nil, content, fmt.Errorf("provenance header is not terminated")
+		}
+		line := string(bytes.TrimRight(rest[:end], "\r"))
+		rest = rest[end+1:]
+
+		switch {
+		case lineNumber == 0:
+			version := strings.TrimPrefix(line, headerFirstLinePrefix)
+			if i := strings.Index(version, ". "); i >= 0 {
+				version = version[:i]
+			}
+			header.Version = version
+		case strings.HasPrefix(line, sourcePrefix):
+			path, hash, ok := splitEntry(strings.TrimPrefix(line, sourcePrefix))
+			if !ok {
+				return nil, content, fmt.Errorf("invalid provenance source line: %q", line)
+			}
+			header.Sources = append(header.Sources, Source{Path: path, Hash: hash})
+		case strings.HasPrefix(line, servicePrefix):
+			name, hash, ok := splitEntry(strings.TrimPrefix(line, servicePrefix))
+			if !ok {
+				return nil, content, fmt.Errorf("invalid provenance service line: %q", line)
+			}
+			if header.Services == nil {
+				header.Services = make(map[string]string)
+			}
+			header.Services[name] = hash
+		case strings.HasPrefix(line, sectionsPrefix):
+			header.Sections = strings.TrimSpace(strings.TrimPrefix(line, sectionsPrefix))
+		case strings.HasPrefix(line, outputPrefix):
+			header.OutputHash = strings.TrimSpace(strings.TrimPrefix(line, outputPrefix))
+			return header, rest, nil
+		default:
+			return nil, content, fmt.Errorf("invalid provenance header line: %q", line)
+		}
+	}
+}
+
+// quoteName quotes a path or service name written into the header if it contains white space or
+// starts with a quote, so that it can be told apart from the hash following it
+func quoteName(name string) string {
+	if strings.IndexFunc(name, unicode.IsSpace) >= 0 || strings.HasPrefix(name, `"`) {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// splitEntry splits the rest of a source or service line into the name and the hash at the end of the line
+func splitEntry(entry string) (string, string, bool) {
+	entry = strings.TrimSpace(entry)
+	i := strings.LastIndexFunc(entry, unicode.IsSpace)
+	if i < 0 {
+		return "", "", false
+	}
+	name, hash := strings.TrimSpace(entry[:i]), entry[i+1:]
+	if strings.HasPrefix(name, `"`) {
+		unquoted, err := strconv.Unquote(name)
+		if err != nil {
+			return "", "", false
+		}
+		name = unquoted
+	}
+	return name, hash, name != ""
+}
+
+// Parts returns the hash of every service of a compose file or template and the hash of its other
+// sections, without the service inclusion directive. The hashes ignore comments and formatting.
+func Parts(content []byte) (map[string]string, string, error) {
+	var doc map[string]yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, "", err
+	}
+
+	services := make(map[string]string)
+	sections := make(map[string]interface{})
+	for key, node := range doc {
+		switch {
+		case key == "services":
+			if node.Kind != yaml.MappingNode {
+				continue
+			}
+			for i := 0; i < len(node.Content)-1; i += 2 {
+				hash, err := HashNode(node.Content[i+1])
+				if err != nil {
+					return nil, "", err
+				}
+				services[node.Content[i].Value] = hash
+			}
+		case strings.HasPrefix(key, "<dcm"):
+		default:
+			var value interface{}
+			if err := node.Decode(&value); err != nil {
+				return nil, "", err
+			}
+			sections[key] = value
+		}
+	}
+
+	encoded, err := yaml.Marshal(sections)
+	if err != nil {
+		return nil, "", err
+	}
+	return services, Hash(encoded), nil
+}
+
+// HashNode returns the hash of the data held by the node, ignoring comments and formatting
+func HashNode(node *yaml.Node) (string, error) {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return "", err
+	}
+	encoded, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return Hash(encoded), nil
+}
+
+// Strip removes the provenance header from the content, if there is one
+func Strip(content []byte) []byte {
+	header, body, err := Parse(content)
+	if err != nil || header == nil {
+		return content
+	}
+	return body
+}
+
+// Report is the result of verifying a generated compose file against its sources
+type Report struct {
+	// Header is the provenance header found in the compose file, nil if there is none
+	Header *Header
+	// JSON is true for a compose file in JSON format, which is written without a provenance header
+	JSON bool
+	// HandEdited is true if the compose file was modified after it was generated
+	HandEdited bool
+	// Changed lists the sources modified since the compose file was generated
+	Changed []string
+	// Added lists the service files created since the compose file was generated
+	Added []string
+	// Removed lists the sources deleted since the compose file was generated
+	Removed []string
+}
+
+// Stale reports whether the sources changed since the compose file was generated
+func (r *Report) Stale() bool {
+	return len(r.Changed) > 0 || len(r.Added) > 0 || len(r.Removed) > 0
+}
+
+// UpToDate reports whether the compose file matches its sources and was not edited by hand
+func (r *Report) UpToDate() bool {
+	return r.Header != nil && !r.HandEdited && !r.Stale()
+}
+
+// Verify re-hashes the compose file and its sources and compares them with the provenance header
+func Verify(fs afero.Fs, buildDir, templatePath, servicesDir, composePath string) (*Report, error) {
+	content, err := afero.ReadFile(fs, composePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	header, body, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Header: header}
+	if header == nil {
+		report.JSON = bytes.HasPrefix(bytes.TrimSpace(content), []byte("{"))
+		return report, nil
+	}
+	report.HandEdited = Hash(body) != header.OutputHash
+
+	current, err := CollectSources(fs, buildDir, templatePath, servicesDir)
+	if err != nil {
+		return nil, err
+	}
+	currentByPath := make(map[string]string, len(current))
+	for _, source := range current {
+		currentByPath[source.Path] = source.Hash
+	}
+
+	recorded := make(map[string]bool, len(header.Sources))
+	for _, source := range header.Sources {
+		recorded[source.Path] = true
+		hash, exists := currentByPath[source.Path]
+		switch {
+		case !exists:
+			report.Removed = append(report.Removed, source.Path)
+		case hash != source.Hash:
+			report.Changed = append(report.Changed, source.Path)
+		}
+	}
+	for _, source := range current {
+		if !recorded[source.Path] {
+			report.Added = append(report.Added, source.Path)
+		}
+	}
+
+	return report, nil
+}
+
+// CollectSources hashes the template and all service files the way the builders read them
+func CollectSources(fs afero.Fs, buildDir, templatePath, servicesDir string) ([]Source, error) {
+	var sources []Source
+
+	content, err := afero.ReadFile(fs, templatePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+	if err == nil {
+		sources = append(sources, NewSource(buildDir, templatePath, content))
+	}
+
+	names, err := logic.ReadServiceFiles(fs, servicesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+
+	for _, name := range names {
+		filePath := filepath.Join(servicesDir, name)
+		content, err := afero.ReadFile(fs, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service file %s: %w", name, err)
+		}
+		sources = append(sources, NewSource(buildDir, filePath, content))
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,171 @@
+package provenance
+
+import (
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStampAndParse(t *testing.T) {
+	body := []byte("services:\n  app:\n    image: app\n")
+	sources := []Source{
+		{Path: "docker-compose-dcm.yml", Hash: Hash([]byte("template"))},
+		{Path: "services/app.yml", Hash: Hash([]byte("app"))},
+	}
+
+	stamped := Stamp("1.2.3", sources, body)
+
+	header, parsedBody, err := Parse(stamped)
+	assert.NoError(t, err)
+	assert.NotNil(t, header)
+	assert.Equal(t, "1.2.3", header.Version)
+	assert.Equal(t, sources, header.Sources)
+	assert.Equal(t, Hash(body), header.OutputHash)
+	services, sections, err := Parts(body)
+	assert.NoError(t, err)
+	assert.Equal(t, services, header.Services)
+	assert.Equal(t, sections, header.Sections)
+	assert.Contains(t, string(stamped), "# dcm:service app sha256:")
+	assert.Equal(t, body, parsedBody)
+	assert.Equal(t, body, Strip(stamped))
+}
+
+func TestStampAndParse_SpacesInPaths(t *testing.T) {
+	body := []byte("services:\n  app:\n    image: app\n")
+	sources := []Source{
+		{Path: "docker-compose-dcm.yml", Hash: Hash([]byte("template"))},
+		{Path: "my services/app one.yml", Hash: Hash([]byte("app"))},
+		{Path: `"quoted".yml`, Hash: Hash([]byte("quoted"))},
+	}
+
+	stamped := Stamp("1.2.3", sources, body)
+	assert.Contains(t, string(stamped), `# dcm:source "my services/app one.yml" sha256:`)
+
+	header, _, err := Parse(stamped)
+	assert.NoError(t, err)
+	assert.Equal(t, sources, header.Sources)
+
+	// Headers written without quotes are read with the hash taken from the end of the line
+	header, _, err = Parse([]byte("# Generated by dcm 1.0.\n# dcm:source my services/app.yml sha256:1\n# dcm:output sha256:2\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Source{{Path: "my services/app.yml", Hash: "sha256:1"}}, header.Sources)
+}
+
+func TestParts(t *testing.T) {
+	template := []byte("services:\n<dcm: include services\\>\n\nnetworks:\n  innernet: {} # comment\n")
+	compose := []byte("# edited\nnetworks:\n  innernet: {}\nservices:\n  app:\n    image: app # comment\n    ports: [\"80:80\"]\n")
+
+	templateServices, templateSections, err := Parts(template)
+	assert.NoError(t, err)
+	assert.Empty(t, templateServices)
+	services, sections, err := Parts(compose)
+	assert.NoError(t, err)
+	assert.Equal(t, templateSections, sections, "comments, order and the directive must not matter")
+
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte("ports:\n  - 80:80\nimage: app\n"), &node))
+	hash, err := HashNode(&node)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": hash}, services)
+}
+
+func TestParse_NoHeader(t *testing.T) {
+	content := []byte("# Regular comment\nservices: {}\n")
+
+	header, body, err := Parse(content)
+	assert.NoError(t, err)
+	assert.Nil(t, header)
+	assert.Equal(t, content, body)
+	assert.Equal(t, content, Strip(content))
+}
+
+func TestParse_InvalidHeader(t *testing.T) {
+	content := []byte("# Generated by dcm 1.0. Do not edit.\n# unexpected line\nservices: {}\n")
+
+	_, _, err := Parse(content)
+	assert.Error(t, err)
+	assert.Equal(t, content, Strip(content))
+}
+
+func TestVerify(t *testing.T) {
+	// setup creates a project and a compose file generated from it
+	setup := func(t *testing.T) (afero.Fs, string, string, string, string) {
+		fs := afero.NewMemMapFs()
+		dir := "/project"
+		templatePath := filepath.Join(dir, "docker-compose-dcm.yml")
+		servicesDir := filepath.Join(dir, "services")
+		composePath := filepath.Join(dir, "docker-compose.yml")
+
+		assert.NoError(t, fs.Mkdir(servicesDir, 0755))
+		assert.NoError(t, afero.WriteFile(fs, templatePath, []byte("services:\n<dcm: include services\\>\n"), 0644))
+		assert.NoError(t, afero.WriteFile(fs, filepath.Join(servicesDir, "app.yml"), []byte("  app:\n    image: app\n"), 0644))
+		assert.NoError(t, afero.WriteFile(fs, filepath.Join(servicesDir, "db.yml"), []byte("  db:\n    image: db\n"), 0644))
+
+		sources, err := CollectSources(fs, dir, templatePath, servicesDir)
+		assert.NoError(t, err)
+		stamped := Stamp("1.0.0", sources, []byte("services:\n  app:\n    image: app\n  db:\n    image: db\n"))
+		assert.NoError(t, afero.WriteFile(fs, composePath, stamped, 0644))
+
+		return fs, dir, templatePath, servicesDir, composePath
+	}
+
+	t.Run("up_to_date", func(t *testing.T) {
+		fs, dir, templatePath, servicesDir, composePath := setup(t)
+		report, err := Verify(fs, dir, templatePath, servicesDir, composePath)
+		assert.NoError(t, err)
+		assert.True(t, report.UpToDate())
+	})
+
+	t.Run("stale_sources", func(t *testing.T) {
+		fs, dir, templatePath, servicesDir, composePath := setup(t)
+		assert.NoError(t, afero.WriteFile(fs, filepath.Join(servicesDir, "app.yml"), []byte("  app:\n    image: app:2\n"), 0644))
+		assert.NoError(t, fs.Remove(filepath.Join(servicesDir, "db.yml")))
+		assert.NoError(t, afero.WriteFile(fs, filepath.Join(servicesDir, "cache.yml"), []byte("  cache:\n    image: redis\n"), 0644))
+
+		report, err := Verify(fs, dir, templatePath, servicesDir, composePath)
+		assert.NoError(t, err)
+		assert.False(t, report.UpToDate())
+		assert.True(t, report.Stale())
+		assert.False(t, report.HandEdited)
+		assert.Equal(t, []string{"services/app.yml"}, report.Changed)
+		assert.Equal(t, []string{"services/db.yml"}, report.Removed)
+		assert.Equal(t, []string{"services/cache.yml"}, report.Added)
+	})
+
+	t.Run("hand_edited", func(t *testing.T) {
+		fs, dir, templatePath, servicesDir, composePath := setup(t)
+		content, err := afero.ReadFile(fs, composePath)
+		assert.NoError(t, err)
+		assert.NoError(t, afero.WriteFile(fs, composePath, append(content, []byte("    restart: always\n")...), 0644))
+
+		report, err := Verify(fs, dir, templatePath, servicesDir, composePath)
+		assert.NoError(t, err)
+		assert.True(t, report.HandEdited)
+		assert.False(t, report.Stale())
+	})
+
+	t.Run("no_header", func(t *testing.T) {
+		fs, dir, templatePath, servicesDir, composePath := setup(t)
+		assert.NoError(t, afero.WriteFile(fs, composePath, []byte("services: {}\n"), 0644))
+
+		report, err := Verify(fs, dir, templatePath, servicesDir, composePath)
+		assert.NoError(t, err)
+		assert.Nil(t, report.Header)
+		assert.False(t, report.JSON)
+		assert.False(t, report.UpToDate())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		fs, dir, templatePath, servicesDir, composePath := setup(t)
+		assert.NoError(t, afero.WriteFile(fs, composePath, []byte("{\n  \"services\": {}\n}\n"), 0644))
+
+		report, err := Verify(fs, dir, templatePath, servicesDir, composePath)
+		assert.NoError(t, err)
+		assert.Nil(t, report.Header)
+		assert.True(t, report.JSON)
+		assert.False(t, report.UpToDate())
+	})
+}
@@ -0,0 +1,237 @@
+// Package refactor renames and removes services together with the references to them in the service files.
+// The files are changed in place, so comments and formatting of everything else are preserved.
+package refactor
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Reference kinds, named after the key holding the reference
+const (
+	DependsOn   = "depends_on"
+	Links       = "links"
+	NetworkMode = "network_mode"
+	VolumesFrom = "volumes_from"
+	Extends     = "extends"
+)
+
+// Reference is a place where a service refers to another service by name
+type Reference struct {
+	// Source is the slash-separated path of the service file relative to the project directory
+	Source string
+	// Service is the name of the referring service
+	Service string
+	// Target is the name of the referenced service
+	Target string
+	// Kind is the key holding the reference, e.g. depends_on
+	Kind string
+	Line int
+
+	file *serviceFile
+	// node is the scalar containing the name, offset is the position of the name inside its value
+	node   *yaml.Node
+	offset int
+}
+
+// serviceFile is a parsed service file
+type serviceFile struct {
+	path    string
+	source  string
+	content []byte
+	// keys and values are the service names and definitions in the order of the file
+	keys   []*yaml.Node
+	values []*yaml.Node
+}
+
+// project is the set of service files of a project
+type project struct {
+	files []*serviceFile
+}
+
+// loadProject reads and parses all service files in the services directory, ordered by file name
+func loadProject(fs afero.Fs, buildDir, servicesDir string) (*project, error) {
+	names, err := logic.ReadServiceFiles(fs, servicesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+
+	p := &project{}
+	for _, name := range names {
+		filePath := filepath.Join(servicesDir, name)
+		content, err := afero.ReadFile(fs, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service file %s: %w", name, err)
+		}
+
+		file := &serviceFile{path: filePath, source: logic.RelativePath(buildDir, filePath), content: content}
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil {
+			return nil, fmt.Errorf("failed to parse service file %s: %w", name, err)
+		}
+		if len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode {
+			mapping := node.Content[0]
+			for i := 0; i+1 < len(mapping.Content); i += 2 {
+				file.keys = append(file.keys, mapping.Content[i])
+				file.values = append(file.values, mapping.Content[i+1])
+			}
+		}
+		p.files = append(p.files, file)
+	}
+	return p, nil
+}
+
+// find returns the file and the key node of the named service, nil if no file defines it
+func (p *project) find(name string) (*serviceFile, *yaml.Node) {
+	for _, file := range p.files {
+		for _, key := range file.keys {
+			if key.Value == name {
+				return file, key
+			}
+		}
+	}
+	return nil, nil
+}
+
+// references returns all references to the named service in the order of the files
+func (p *project) references(target string) []Reference {
+	var references []Reference
+	for _, file := range p.files {
+		for i, key := range file.keys {
+			for _, reference := range serviceReferences(file.values[i]) {
+				if reference.Target != target {
+					continue
+				}
+				reference.Source, reference.Service, reference.Line, reference.file = file.source, key.Value, reference.node.Line, file
+				references = append(references, reference)
+			}
+		}
+	}
+	return references
+}
+
+// serviceReferences returns the references of a service definition to other services
+func serviceReferences(definition *yaml.Node) []Reference {
+	if definition.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var references []Reference
+	add := func(kind string, node *yaml.Node, offset int, target string) {
+		references = append(references, Reference{Kind: kind, Target: target, node: node, offset: offset})
+	}
+
+	for i := 0; i+1 < len(definition.Content); i += 2 {
+		value := definition.Content[i+1]
+		switch definition.Content[i].Value {
+		case DependsOn:
+			// The short syntax is a list of names, the long syntax a mapping with the names as keys
+			switch value.Kind {
+			case yaml.SequenceNode:
+				for _, item := range value.Content {
+					add(DependsOn, item, 0, item.Value)
+				}
+			case yaml.MappingNode:
+				for j := 0; j < len(value.Content); j += 2 {
+					add(DependsOn, value.Content[j], 0, value.Content[j].Value)
+				}
+			}
+		case Links:
+			// SERVICE or SERVICE:ALIAS
+			for _, item := range sequence(value) {
+				name, _, _ := strings.Cut(item.Value, ":")
+				add(Links, item, 0, name)
+			}
+		case NetworkMode:
+			if name, found := strings.CutPrefix(value.Value, "service:"); found {
+				add(NetworkMode, value, len("service:"), name)
+			}
+		case VolumesFrom:
+			// SERVICE[:MODE], containers are given as container:NAME[:MODE]
+			for _, item := range sequence(value) {
+				if !strings.HasPrefix(item.Value, "container:") {
+					name, _, _ := strings.Cut(item.Value, ":")
+					add(VolumesFrom, item, 0, name)
+				}
+			}
+		case Extends:
+			// A service of another file is not a reference within the project
+			if value.Kind == yaml.ScalarNode {
+				add(Extends, value, 0, value.Value)
+			} else if service := mappingValue(value, "service"); service != nil && mappingValue(value, "file") == nil {
+				add(Extends, service, 0, service.Value)
+			}
+		}
+	}
+	return references
+}
+
+// replacement replaces the bytes from start up to but excluding end
+type replacement struct {
+	start, end int
+	text       string
+}
+
+// nameReplacement returns the replacement of the name at the given offset inside the value of the scalar
+func nameReplacement(content []byte, node *yaml.Node, offset int, name, newName string) (replacement, error) {
+	start := lineOffset(content, node.Line) + node.Column - 1
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		start++
+	}
+	start += offset
+	end := start + len(name)
+	if start < 0 || end > len(content) || string(content[start:end]) != name {
+		return replacement{}, fmt.Errorf("line %d: cannot locate '%v' in the source", node.Line, name)
+	}
+	return replacement{start: start, end: end, text: newName}, nil
+}
+
+// apply applies the replacements to the content, the replacements must not overlap
+func apply(content []byte, replacements []replacement) []byte {
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].start > replacements[j].start })
+	result := bytes.Clone(content)
+	for _, r := range replacements {
+		result = append(result[:r.start:r.start], append([]byte(r.text), result[r.end:]...)...)
+	}
+	return result
+}
+
+// lineOffset returns the offset of the first byte of the line, lines are counted from 1
+func lineOffset(content []byte, line int) int {
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := bytes.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			return len(content)
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+// sequence returns the items of a sequence node, nil for anything else
+func sequence(node *yaml.Node) []*yaml.Node {
+	if node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
+
+// mappingValue returns the value of the key in the mapping node, nil if the key is missing
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
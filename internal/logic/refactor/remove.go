@@ -0,0 +1,313 @@
+package refactor
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/textdiff"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/inventory"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/edit"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// FileChange is the old and the new content of a changed file, New is nil for a removed file
+type FileChange struct {
+	Path string
+	Old  []byte
+	New  []byte
+}
+
+// RemoveResult describes the changes staged for a removed service
+type RemoveResult struct {
+	// Service is the name of the removed service
+	Service string
+	// Source is the path of the service file that defined the service
+	Source string
+	// References are all references of the other services to the removed service
+	References []Reference
+	// Pruned are the depends_on references removed with prune
+	Pruned []Reference
+	// Networks and Volumes are the declarations removed from the template with prune
+	Networks []string
+	Volumes  []string
+	// Changes are the changed files in the order they are staged
+	Changes []FileChange
+}
+
+// Remaining returns the references that are left dangling after the removal
+func (r *RemoveResult) Remaining() []Reference {
+	var remaining []Reference
+	for _, reference := range r.References {
+		pruned := false
+		for _, p := range r.Pruned {
+			if p.node == reference.node {
+				pruned = true
+			}
+		}
+		if !pruned {
+			remaining = append(remaining, reference)
+		}
+	}
+	return remaining
+}
+
+// Confirm shows the diff of every change on out and asks whether to remove the service, unless force
+// is set. It returns input.ErrCanceled when the removal is declined.
+func (r *RemoveResult) Confirm(prompter input.Prompter, out io.Writer, baseDir string, force bool) error {
+	for _, change := range r.Changes {
+		name, err := filepath.Rel(baseDir, change.Path)
+		if err != nil {
+			name = change.Path
+		}
+		fmt.Fprint(out, textdiff.Unified(name, name, change.Old, change.New))
+	}
+	return input.Require(prompter, fmt.Sprintf("Remove service '%v'", r.Service), force)
+}
+
+// Remover removes services and cleans up the references to them
+type Remover struct {
+	fs           afero.Fs
+	buildDir     string
+	templatePath string
+	servicesDir  string
+}
+
+// NewRemover creates a new instance of Remover
+func NewRemover(buildDir, templatePath, servicesDir string) *Remover {
+	return &Remover{
+		fs:           afero.NewOsFs(),
+		buildDir:     buildDir,
+		templatePath: templatePath,
+		servicesDir:  servicesDir,
+	}
+}
+
+// SetFs selects the file system the files are read from and written to
+func (r *Remover) SetFs(fs afero.Fs) {
+	r.fs = fs
+}
+
+// Stage stages the removal of the service in the given transaction. The service file is removed
+// when it defines no other service. With prune, the depends_on entries referring to the service
+// and the networks and named volumes of the template that lose their last user are removed as well.
+// The changed and removed files are backed up by the transaction when it keeps backups.
+func (r *Remover) Stage(tx *path.Transaction, name string, prune bool) (*RemoveResult, error) {
+	p, err := loadProject(r.fs, r.buildDir, r.servicesDir)
+	if err != nil {
+		return nil, err
+	}
+	file, _ := p.find(name)
+	if file == nil {
+		return nil, fmt.Errorf("service '%v' not found in '%v'", name, r.servicesDir)
+	}
+
+	result := &RemoveResult{Service: name, Source: file.path}
+	for _, reference := range p.references(name) {
+		if reference.Service != name {
+			result.References = append(result.References, reference)
+		}
+	}
+
+	// Edit every affected service file, the file of the service first
+	docs := make(map[*serviceFile]*edit.Document)
+	document := func(f *serviceFile) (*edit.Document, error) {
+		if docs[f] == nil {
+			doc, err := edit.Parse(f.content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse service file %s: %w", f.source, err)
+			}
+			docs[f] = doc
+		}
+		return docs[f], nil
+	}
+
+	doc, err := document(file)
+	if err != nil {
+		return nil, err
+	}
+	removeKey(doc.Root(), name)
+
+	if prune {
+		for _, reference := range result.References {
+			if reference.Kind != DependsOn {
+				continue
+			}
+			doc, err := document(reference.file)
+			if err != nil {
+				return nil, err
+			}
+			if removeDependency(mappingValue(doc.Root(), reference.Service), name) {
+				result.Pruned = append(result.Pruned, reference)
+			}
+		}
+	}
+
+	for _, f := range p.files {
+		doc := docs[f]
+		if doc == nil {
+			continue
+		}
+		if len(doc.Root().Content) == 0 {
+			tx.Remove(f.path)
+			result.Changes = append(result.Changes, FileChange{Path: f.path, Old: f.content})
+			continue
+		}
+		content, err := doc.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode service file %s: %w", f.source, err)
+		}
+		if err := tx.WriteFile(f.path, content, 0644); err != nil {
+			return nil, err
+		}
+		result.Changes = append(result.Changes, FileChange{Path: f.path, Old: f.content, New: content})
+	}
+
+	if prune {
+		if err := r.pruneTemplate(tx, name, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// pruneTemplate removes the networks and named volumes of the removed service that no other service uses
+func (r *Remover) pruneTemplate(tx *path.Transaction, name string, result *RemoveResult) error {
+	services, err := inventory.FromProject(r.fs, r.buildDir, r.templatePath, r.servicesDir)
+	if err != nil {
+		return err
+	}
+	usedNetworks, usedVolumes := make(map[string]int), make(map[string]int)
+	for _, service := range services {
+		for _, network := range service.Networks {
+			usedNetworks[network]++
+		}
+		for _, volume := range service.Volumes {
+			if volumeName, ok := namedVolume(volume); ok {
+				usedVolumes[volumeName]++
+			}
+		}
+	}
+
+	var networks, volumes []string
+	for _, service := range services {
+		if service.Name != name {
+			continue
+		}
+		for _, network := range service.Networks {
+			if usedNetworks[network] == 1 && !strings.HasPrefix(network, "network_mode:") {
+				networks = append(networks, network)
+			}
+		}
+		for _, volume := range service.Volumes {
+			if volumeName, ok := namedVolume(volume); ok && usedVolumes[volumeName] == 1 {
+				volumes = append(volumes, volumeName)
+			}
+		}
+	}
+	if len(networks) == 0 && len(volumes) == 0 {
+		return nil
+	}
+
+	content, err := afero.ReadFile(r.fs, r.templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read template file: %w", err)
+	}
+	doc, err := edit.Parse(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse template file: %w", err)
+	}
+	result.Networks = removeDeclarations(doc.Root(), "networks", networks)
+	result.Volumes = removeDeclarations(doc.Root(), "volumes", volumes)
+	if len(result.Networks) == 0 && len(result.Volumes) == 0 {
+		return nil
+	}
+
+	updated, err := doc.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode template: %w", err)
+	}
+	if err := tx.WriteFile(r.templatePath, updated, 0644); err != nil {
+		return err
+	}
+	result.Changes = append(result.Changes, FileChange{Path: r.templatePath, Old: content, New: updated})
+	return nil
+}
+
+// removeDependency removes the service from the depends_on entry of the definition, the entry
+// itself is removed when it becomes empty. It reports whether anything was removed.
+func removeDependency(definition *yaml.Node, name string) bool {
+	if definition == nil {
+		return false
+	}
+	dependsOn := mappingValue(definition, DependsOn)
+	if dependsOn == nil {
+		return false
+	}
+
+	removed := false
+	switch dependsOn.Kind {
+	case yaml.SequenceNode:
+		items := dependsOn.Content[:0]
+		for _, item := range dependsOn.Content {
+			if item.Value == name {
+				removed = true
+				continue
+			}
+			items = append(items, item)
+		}
+		dependsOn.Content = items
+	case yaml.MappingNode:
+		removed = removeKey(dependsOn, name)
+	}
+
+	if len(dependsOn.Content) == 0 {
+		removeKey(definition, DependsOn)
+	}
+	return removed
+}
+
+// removeDeclarations removes the names from the top-level section of the template and returns the
+// removed ones. The section is removed when it becomes empty.
+func removeDeclarations(root *yaml.Node, section string, names []string) []string {
+	declarations := mappingValue(root, section)
+	if declarations == nil {
+		return nil
+	}
+	var removed []string
+	for _, name := range names {
+		if removeKey(declarations, name) {
+			removed = append(removed, name)
+		}
+	}
+	if declarations.Kind == yaml.MappingNode && len(declarations.Content) == 0 {
+		removeKey(root, section)
+	}
+	return removed
+}
+
+// removeKey removes the key and its value from the mapping node and reports whether it was present
+func removeKey(mapping *yaml.Node, key string) bool {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// namedVolume returns the name of the volume of a source:target mount, false for bind mounts and anonymous volumes
+func namedVolume(mount string) (string, bool) {
+	source, _, found := strings.Cut(mount, ":")
+	if !found || source == "" || strings.ContainsAny(source[:1], "/.~$") {
+		return "", false
+	}
+	return source, true
+}
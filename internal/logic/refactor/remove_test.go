@@ -0,0 +1,149 @@
+package refactor
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/spf13/afero"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const removeTemplate = `services:
+<dcm: include services\>
+
+networks:
+  front:
+  back: # Only used by the database
+    driver: bridge
+
+volumes:
+  db-data:
+`
+
+// backupName returns the name of the first backup of the file taken today
+func backupName(file string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "-" + time.Now().Format("20060102") + ext
+}
+
+// remove stages the removal of the service in a project with the given service files and commits it
+func remove(t *testing.T, files map[string]string, name string, prune bool) (afero.Fs, *RemoveResult) {
+	fs := newProject(t, files)
+	assert.NoError(t, afero.WriteFile(fs, "/project/docker-compose-dcm.yml", []byte(removeTemplate), 0644))
+
+	var result *RemoveResult
+	err := commit(t, fs, func(tx *path.Transaction) error {
+		tx.SetBackup(true)
+		remover := NewRemover("/project", "/project/docker-compose-dcm.yml", "/project/services")
+		remover.SetFs(fs)
+		var err error
+		result, err = remover.Stage(tx, name, prune)
+		return err
+	})
+	assert.NoError(t, err)
+	return fs, result
+}
+
+var removeFiles = map[string]string{
+	"db.yml": "  db:\n    image: postgres\n    networks: [back]\n    volumes:\n      - db-data:/var/lib/postgresql/data\n",
+	"app.yml": `  app:
+    image: app
+    networks: [front, back]
+    depends_on:
+      db: # The database
+        condition: service_healthy
+      cache:
+        condition: service_started
+  worker:
+    image: worker
+    depends_on: [db]
+    volumes_from: [db]
+`,
+}
+
+func TestRemover_Stage(t *testing.T) {
+	fs, result := remove(t, removeFiles, "db", false)
+
+	exists, _ := afero.Exists(fs, "/project/services/db.yml")
+	assert.False(t, exists)
+	assert.Equal(t, removeFiles["db.yml"], readFile(t, fs, backupName("/project/services/db.yml")))
+	assert.Equal(t, removeFiles["app.yml"], readFile(t, fs, "/project/services/app.yml"))
+	assert.Equal(t, removeTemplate, readFile(t, fs, "/project/docker-compose-dcm.yml"))
+
+	var described []string
+	for _, reference := range result.Remaining() {
+		described = append(described, reference.Service+" "+reference.Kind)
+	}
+	assert.Equal(t, []string{"app depends_on", "worker depends_on", "worker volumes_from"}, described)
+	assert.Equal(t, []FileChange{{Path: "/project/services/db.yml", Old: []byte(removeFiles["db.yml"])}}, result.Changes)
+}
+
+func TestRemover_Prune(t *testing.T) {
+	fs, result := remove(t, removeFiles, "db", true)
+
+	assert.Len(t, result.Pruned, 2)
+	assert.Len(t, result.Remaining(), 1)
+	assert.Empty(t, result.Networks)
+	assert.Equal(t, []string{"db-data"}, result.Volumes)
+	assert.Len(t, result.Changes, 3)
+
+	assert.Equal(t, `  app:
+    image: app
+    networks: [front, back]
+    depends_on:
+      cache:
+        condition: service_started
+  worker:
+    image: worker
+    volumes_from: [db]
+`, readFile(t, fs, "/project/services/app.yml"))
+	assert.Equal(t, removeFiles["app.yml"], readFile(t, fs, backupName("/project/services/app.yml")))
+	assert.Equal(t, removeFiles["db.yml"], readFile(t, fs, backupName("/project/services/db.yml")))
+	assert.Equal(t, removeTemplate, readFile(t, fs, backupName("/project/docker-compose-dcm.yml")))
+
+	// The network is still used by the app service
+	assert.Equal(t, `services:
+<dcm: include services\>
+
+networks:
+  front:
+  back: # Only used by the database
+    driver: bridge
+`, readFile(t, fs, "/project/docker-compose-dcm.yml"))
+}
+
+func TestRemoveResult_Confirm(t *testing.T) {
+	result := &RemoveResult{
+		Service: "db",
+		Changes: []FileChange{{Path: "/project/services/db.yml", Old: []byte("  db:\n    image: postgres\n")}},
+	}
+
+	tests := []struct {
+		name     string
+		prompter input.Prompter
+		force    bool
+		expected error
+	}{
+		{name: "yes", prompter: input.NewConsole(strings.NewReader("y\n"), io.Discard)},
+		{name: "no", prompter: input.NewConsole(strings.NewReader("n\n"), io.Discard), expected: input.ErrCanceled},
+		{name: "no_flag", prompter: input.NewFixed(false, io.Discard), expected: input.ErrCanceled},
+		{name: "non_interactive", prompter: input.NewNonInteractive(), expected: input.ErrNonInteractive},
+		{name: "force", prompter: input.NewNonInteractive(), force: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			err := result.Confirm(tt.prompter, &out, "/project", tt.force)
+			assert.ErrorIs(t, err, tt.expected)
+			// The diff is shown before the question, also when nothing is asked
+			assert.Contains(t, out.String(), "--- services/db.yml")
+			assert.Contains(t, out.String(), "-    image: postgres")
+		})
+	}
+}
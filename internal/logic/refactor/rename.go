@@ -0,0 +1,109 @@
+package refactor
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/spf13/afero"
+	"path/filepath"
+)
+
+// RenameResult describes the changes staged for a renamed service
+type RenameResult struct {
+	// Source is the path of the service file defining the service, Target its path after the rename.
+	// They are equal when the file is not named after the service and keeps its name.
+	Source string
+	Target string
+	// References are the rewritten references of the other services
+	References []Reference
+}
+
+// Renamer renames services and rewrites the references to them
+type Renamer struct {
+	fs          afero.Fs
+	buildDir    string
+	servicesDir string
+}
+
+// NewRenamer creates a new instance of Renamer
+func NewRenamer(buildDir, servicesDir string) *Renamer {
+	return &Renamer{
+		fs:          afero.NewOsFs(),
+		buildDir:    buildDir,
+		servicesDir: servicesDir,
+	}
+}
+
+// SetFs selects the file system the files are read from and written to
+func (r *Renamer) SetFs(fs afero.Fs) {
+	r.fs = fs
+}
+
+// Stage stages the rename of the service and of all references to it in the given transaction.
+// The service file is renamed as well when it is named after the service.
+func (r *Renamer) Stage(tx *path.Transaction, oldName, newName string) (*RenameResult, error) {
+	if err := logic.ValidateServiceName(newName); err != nil {
+		return nil, err
+	}
+	if oldName == newName {
+		return nil, fmt.Errorf("service '%v' already has this name", oldName)
+	}
+
+	p, err := loadProject(r.fs, r.buildDir, r.servicesDir)
+	if err != nil {
+		return nil, err
+	}
+	file, key := p.find(oldName)
+	if file == nil {
+		return nil, fmt.Errorf("service '%v' not found in '%v'", oldName, r.servicesDir)
+	}
+	if other, otherKey := p.find(newName); other != nil {
+		return nil, fmt.Errorf("service '%v' is already defined in %v:%d", newName, other.source, otherKey.Line)
+	}
+
+	result := &RenameResult{Source: file.path, Target: file.path}
+	if filepath.Base(file.path) == oldName+".yml" {
+		result.Target = filepath.Join(filepath.Dir(file.path), newName+".yml")
+		exists, err := path.IsExist(r.fs, result.Target)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("service file '%v' already exists", result.Target)
+		}
+	}
+
+	// Collect the replacements per file, the key of the service comes first
+	replacements := make(map[*serviceFile][]replacement)
+	keyReplacement, err := nameReplacement(file.content, key, 0, oldName, newName)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", file.source, err)
+	}
+	replacements[file] = append(replacements[file], keyReplacement)
+
+	result.References = p.references(oldName)
+	for _, reference := range result.References {
+		referenceReplacement, err := nameReplacement(reference.file.content, reference.node, reference.offset, oldName, newName)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", reference.Source, err)
+		}
+		replacements[reference.file] = append(replacements[reference.file], referenceReplacement)
+	}
+
+	for _, f := range p.files {
+		if len(replacements[f]) == 0 {
+			continue
+		}
+		target := f.path
+		if f == file {
+			target = result.Target
+		}
+		if err := tx.WriteFile(target, apply(f.content, replacements[f]), 0644); err != nil {
+			return nil, err
+		}
+	}
+	if result.Target != result.Source {
+		tx.Remove(result.Source)
+	}
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package refactor
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/spf13/afero"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newProject creates a project with the given service files in a memory file system
+func newProject(t *testing.T, files map[string]string) afero.Fs {
+	fs := afero.NewMemMapFs()
+	for name, content := range files {
+		assert.NoError(t, afero.WriteFile(fs, "/project/services/"+name, []byte(content), 0644))
+	}
+	return fs
+}
+
+// commit runs the stage function in a transaction and commits it when staging succeeds
+func commit(t *testing.T, fs afero.Fs, stage func(tx *path.Transaction) error) error {
+	tx, err := path.NewTransaction(fs, "/project")
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	if err := stage(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func readFile(t *testing.T, fs afero.Fs, name string) string {
+	content, err := afero.ReadFile(fs, name)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestRenamer_Stage(t *testing.T) {
+	fs := newProject(t, map[string]string{
+		"redis.yml": "  # Cache\n  redis: # Keep this comment\n    image: redis:alpine\n",
+		"app.yml": `  app:
+    image: app
+    depends_on:
+      redis: # Wait for the cache
+        condition: service_healthy
+      db:
+        condition: service_started
+    links:
+      - "redis:cache"
+    volumes_from: [redis:ro, container:redis]
+  sidecar:
+    network_mode: service:redis
+    extends:
+      service: redis
+  other:
+    extends:
+      file: other.yml
+      service: redis
+`,
+		"worker.yml": "  worker:\n    depends_on: ['redis', db] # Short syntax\n",
+	})
+
+	var result *RenameResult
+	err := commit(t, fs, func(tx *path.Transaction) error {
+		renamer := NewRenamer("/project", "/project/services")
+		renamer.SetFs(fs)
+		var err error
+		result, err = renamer.Stage(tx, "redis", "cache")
+		return err
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/project/services/redis.yml", result.Source)
+	assert.Equal(t, "/project/services/cache.yml", result.Target)
+	var described []string
+	for _, reference := range result.References {
+		described = append(described, reference.Source+" "+reference.Service+" "+reference.Kind)
+	}
+	assert.Equal(t, []string{
+		"services/app.yml app depends_on",
+		"services/app.yml app links",
+		"services/app.yml app volumes_from",
+		"services/app.yml sidecar network_mode",
+		"services/app.yml sidecar extends",
+		"services/worker.yml worker depends_on",
+	}, described)
+
+	exists, _ := afero.Exists(fs, "/project/services/redis.yml")
+	assert.False(t, exists)
+	assert.Equal(t, "  # Cache\n  cache: # Keep this comment\n    image: redis:alpine\n", readFile(t, fs, "/project/services/cache.yml"))
+	assert.Equal(t, `  app:
+    image: app
+    depends_on:
+      cache: # Wait for the cache
+        condition: service_healthy
+      db:
+        condition: service_started
+    links:
+      - "cache:cache"
+    volumes_from: [cache:ro, container:redis]
+  sidecar:
+    network_mode: service:cache
+    extends:
+      service: cache
+  other:
+    extends:
+      file: other.yml
+      service: redis
+`, readFile(t, fs, "/project/services/app.yml"))
+	assert.Equal(t, "  worker:\n    depends_on: ['cache', db] # Short syntax\n", readFile(t, fs, "/project/services/worker.yml"))
+}
+
+func TestRenamer_Refused(t *testing.T) {
+	fs := newProject(t, map[string]string{
+		"web.yml":   "web:\n  image: nginx\nproxy:\n  image: traefik\n",
+		"cache.yml": "cache:\n  image: redis\n",
+		"redis.yml": "db:\n  image: postgres\n",
+	})
+	renamer := NewRenamer("/project", "/project/services")
+	renamer.SetFs(fs)
+	rename := func(oldName, newName string) error {
+		return commit(t, fs, func(tx *path.Transaction) error {
+			_, err := renamer.Stage(tx, oldName, newName)
+			return err
+		})
+	}
+
+	assert.EqualError(t, rename("mail", "smtp"), "service 'mail' not found in '/project/services'")
+	assert.EqualError(t, rename("web", "proxy"), "service 'proxy' is already defined in services/web.yml:3")
+	assert.EqualError(t, rename("cache", "redis"), "service file '/project/services/redis.yml' already exists")
+	assert.EqualError(t, rename("web", "web/2"), "invalid service name 'web/2': use letters, digits, '_', '.' and '-' only")
+
+	// A file not named after the service keeps its name
+	assert.NoError(t, rename("proxy", "traefik"))
+	assert.Equal(t, "web:\n  image: nginx\ntraefik:\n  image: traefik\n", readFile(t, fs, "/project/services/web.yml"))
+}
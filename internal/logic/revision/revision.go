@@ -0,0 +1,138 @@
+// Package revision reads the sources of a project from a revision of its git repository
+// without touching the working tree
+package revision
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnknownRevision is returned when the revision does not resolve to a commit, also when the
+// directory is not inside a git repository
+var ErrUnknownRevision = errors.New("unknown revision")
+
+// notRepositoryMessage is the part of the message git fails with outside of a git repository
+const notRepositoryMessage = "not a git repository"
+
+// Snapshot holds the sources of a project as they were at a git revision
+type Snapshot struct {
+	// Revision is the revision as given by the user, e.g. a tag or a branch
+	Revision string
+	// Commit is the full hash of the commit the revision resolves to
+	Commit string
+	// Files are the slash-separated paths of the loaded files relative to the project directory
+	Files []string
+	// Fs contains the loaded files, its root is the project directory
+	Fs afero.Fs
+}
+
+// Load resolves the revision in the git repository containing dir and reads the files below the given
+// paths, relative to dir, into an in-memory file system
+func Load(ctx context.Context, dir, rev string, paths ...string) (*Snapshot, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision '%v'", rev)
+	}
+
+	out, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		var exitErr *exec.ExitError
+		var gitErr *gitError
+		switch {
+		case errors.As(err, &gitErr) && strings.Contains(gitErr.message, notRepositoryMessage):
+			return nil, fmt.Errorf("%w '%v': %v", ErrUnknownRevision, rev, gitErr.message)
+		case errors.As(err, &gitErr):
+			// Other failures, e.g. a repository owned by another user, are not about the revision
+			return nil, err
+		case errors.As(err, &exitErr):
+			return nil, fmt.Errorf("%w '%v' in the git repository of '%v'", ErrUnknownRevision, rev, dir)
+		}
+		return nil, err
+	}
+	snapshot := &Snapshot{
+		Revision: rev,
+		Commit:   strings.TrimSpace(string(out)),
+	}
+
+	// Paths are given relative to dir, so ls-tree lists them relative to dir as well
+	args := append([]string{"ls-tree", "-r", "-z", "--name-only", snapshot.Commit, "--"}, paths...)
+	out, err = git(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			snapshot.Files = append(snapshot.Files, name)
+		}
+	}
+	if len(snapshot.Files) == 0 {
+		return nil, fmt.Errorf("no project files in '%v' at revision '%v'", dir, rev)
+	}
+
+	root := afero.NewMemMapFs()
+	snapshot.Fs = afero.NewBasePathFs(root, string(filepath.Separator))
+	for _, name := range snapshot.Files {
+		content, err := git(ctx, dir, "cat-file", "blob", snapshot.Commit+":./"+name)
+		if err != nil {
+			return nil, err
+		}
+		if err := snapshot.Fs.MkdirAll(filepath.Dir(filepath.FromSlash(name)), 0755); err != nil {
+			return nil, err
+		}
+		if err := afero.WriteFile(snapshot.Fs, filepath.FromSlash(name), content, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshot, nil
+}
+
+// ShortCommit returns the abbreviated hash of the commit
+func (s *Snapshot) ShortCommit() string {
+	if len(s.Commit) > 12 {
+		return s.Commit[:12]
+	}
+	return s.Commit
+}
+
+// git runs a git command in dir and returns its standard output.
+// The message git writes to the standard error becomes the error, it is not translated.
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("git is required to read revisions: %w", err)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, &gitError{command: args[0], message: message, err: err}
+		}
+		return nil, fmt.Errorf("git %v failed: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// gitError is a failed git command, described by the message git wrote to the standard error
+type gitError struct {
+	command string
+	message string
+	err     error
+}
+
+func (e *gitError) Error() string {
+	return fmt.Sprintf("git %v failed: %v", e.command, e.message)
+}
+
+func (e *gitError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,73 @@
+package revision
+
+import (
+	"context"
+	"github.com/spf13/afero"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runGit runs a git command in dir, failing the test on error
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	project := filepath.Join(repo, "project")
+	assert.NoError(t, os.MkdirAll(filepath.Join(project, "services"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "docker-compose-dcm.yml"), []byte("services:\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "services", "app.yml"), []byte("  app:\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "README.md"), []byte("readme\n"), 0644))
+
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "initial")
+	runGit(t, repo, "tag", "v1")
+
+	// Changes of the working tree after the tag are not visible in the snapshot
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "services", "app.yml"), []byte("  changed:\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "services", "new.yml"), []byte("  new:\n"), 0644))
+
+	snapshot, err := Load(context.Background(), project, "v1", "docker-compose-dcm.yml", "services")
+	assert.NoError(t, err)
+	assert.Len(t, snapshot.Commit, 40)
+	assert.Equal(t, []string{"docker-compose-dcm.yml", "services/app.yml"}, snapshot.Files)
+
+	content, err := afero.ReadFile(snapshot.Fs, filepath.Join("services", "app.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "  app:\n", string(content))
+	exists, err := afero.Exists(snapshot.Fs, "README.md")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = Load(context.Background(), project, "v2", "docker-compose-dcm.yml", "services")
+	assert.EqualError(t, err, "unknown revision 'v2' in the git repository of '"+project+"'")
+	assert.ErrorIs(t, err, ErrUnknownRevision)
+
+	// Outside of a git repository no revision resolves
+	_, err = Load(context.Background(), t.TempDir(), "v1", "services")
+	assert.ErrorIs(t, err, ErrUnknownRevision)
+
+	// Other git failures are reported as they are
+	broken := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(broken, ".git"), []byte("garbage\n"), 0644))
+	_, err = Load(context.Background(), broken, "v1", "services")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnknownRevision)
+	assert.Contains(t, err.Error(), "invalid gitfile format")
+
+	_, err = Load(context.Background(), project, "--all", "services")
+	assert.EqualError(t, err, "invalid revision '--all'")
+}
@@ -0,0 +1,115 @@
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/edit"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/helper"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// Extract stages the move of a single service from the compose file into its own service file.
+// The rest of the compose file keeps its original bytes, the compose file is backed up next to itself.
+func (s *Scaffolder) Extract(tx *path.Transaction, composePath, name string) (*Result, error) {
+	content, err := afero.ReadFile(s.fs, composePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	doc, err := edit.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	servicesNode := helper.FindServicesNode(doc.Node())
+	if servicesNode == nil || servicesNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("services section not found in compose file")
+	}
+
+	keyIndent, step, err := s.indentation()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+		key, value := servicesNode.Content[i], servicesNode.Content[i+1]
+		if key.Value != name {
+			continue
+		}
+
+		service, err := sourceOf(doc, key, value, keyIndent, step)
+		if err != nil {
+			return nil, err
+		}
+		servicesNode.Content = append(servicesNode.Content[:i], servicesNode.Content[i+2:]...)
+		updated, err := doc.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode compose file: %w", err)
+		}
+
+		result, err := s.stage(tx, []newService{service}, false)
+		if err != nil {
+			return nil, err
+		}
+		tx.Backup(composePath)
+		if err := tx.WriteFile(composePath, updated, 0644); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("service '%v' not found in compose file", name)
+}
+
+// Inject stages a service file for every service of a standalone fragment. The fragment is either
+// a compose file with a services section or a mapping of service names to their definitions,
+// like a service file. The source is only used to describe the fragment in errors.
+func (s *Scaffolder) Inject(tx *path.Transaction, source string, fragment []byte) (*Result, error) {
+	doc, err := edit.Parse(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	servicesNode := helper.FindServicesNode(doc.Node())
+	if servicesNode == nil {
+		servicesNode = doc.Root()
+	}
+	if servicesNode == nil || servicesNode.Kind != yaml.MappingNode || len(servicesNode.Content) == 0 {
+		return nil, fmt.Errorf("%s: no services found", source)
+	}
+
+	keyIndent, step, err := s.indentation()
+	if err != nil {
+		return nil, err
+	}
+
+	var services []newService
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+		key, value := servicesNode.Content[i], servicesNode.Content[i+1]
+		if value.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s:%d: service %s must be a mapping", source, key.Line, key.Value)
+		}
+		service, err := sourceOf(doc, key, value, keyIndent, step)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return s.stage(tx, services, true)
+}
+
+// sourceOf returns the service with its original bytes indented like the service files of the project.
+// A service without source bytes, e.g. in a flow mapping, is encoded with the project's indentation step.
+func sourceOf(doc *edit.Document, key, value *yaml.Node, keyIndent, step int) (newService, error) {
+	service := newService{name: key.Value, node: value}
+	if source, ok := doc.Source(key); ok {
+		content := bytes.Trim(edit.Reindent(source, key.Column-1, keyIndent), "\n")
+		service.content = append(content, '\n')
+		return service, nil
+	}
+
+	content, err := render(key.Value, value, keyIndent, step)
+	if err != nil {
+		return newService{}, err
+	}
+	service.content = content
+	return service, nil
+}
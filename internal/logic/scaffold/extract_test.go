@@ -0,0 +1,90 @@
+package scaffold
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/spf13/afero"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const compose = `# Hand-written compose file
+services:
+  app:
+    image: app
+  # The cache
+  cache:
+    image: redis:alpine # Pinned below
+    networks: [back]
+
+networks:
+  back:
+`
+
+func TestExtract(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/project/docker-compose.yml", []byte(compose), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/project/services/web.yml", []byte("web:\n  image: nginx\n"), 0644))
+
+	scaffolder := NewScaffolder("/project", "/project/docker-compose-dcm.yml", "/project/services")
+	scaffolder.SetFs(fs)
+	tx, err := path.NewTransaction(fs, "/project")
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	_, err = scaffolder.Extract(tx, "/project/docker-compose.yml", "db")
+	assert.EqualError(t, err, "service 'db' not found in compose file")
+
+	result, err := scaffolder.Extract(tx, "/project/docker-compose.yml", "cache")
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	// Without a template, nothing is declared
+	assert.Equal(t, &Result{Paths: []string{"/project/services/cache.yml"}}, result)
+	assert.Equal(t, "# The cache\ncache:\n  image: redis:alpine # Pinned below\n  networks: [back]\n",
+		readFile(t, fs, "/project/services/cache.yml"))
+	assert.Equal(t, `# Hand-written compose file
+services:
+  app:
+    image: app
+
+networks:
+  back:
+`, readFile(t, fs, "/project/docker-compose.yml"))
+}
+
+func TestInject(t *testing.T) {
+	fragment := `services:
+    mail: # Local mail catcher
+        image: mailhog/mailhog
+        networks: [mail]
+    smtp: {image: postfix, volumes: ["spool:/var/spool"]}
+`
+	fs, result, err := stage(t, map[string]string{"app.yml": "  app:\n    image: app\n"}, Options{Name: "web", Image: "nginx"})
+	assert.NoError(t, err)
+
+	scaffolder := NewScaffolder("/project", "/project/docker-compose-dcm.yml", "/project/services")
+	scaffolder.SetFs(fs)
+	tx, err := path.NewTransaction(fs, "/project")
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	result, err = scaffolder.Inject(tx, "mail.yml", []byte(fragment))
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	assert.Equal(t, &Result{
+		Paths:    []string{"/project/services/mail.yml", "/project/services/smtp.yml"},
+		Networks: []string{"mail"},
+		Volumes:  []string{"spool"},
+	}, result)
+	assert.Equal(t, "  mail: # Local mail catcher\n      image: mailhog/mailhog\n      networks: [mail]\n",
+		readFile(t, fs, "/project/services/mail.yml"))
+	assert.Equal(t, "  smtp: {image: postfix, volumes: [\"spool:/var/spool\"]}\n",
+		readFile(t, fs, "/project/services/smtp.yml"))
+
+	_, err = scaffolder.Inject(tx, "app.yml", []byte("app:\n  image: app\n"))
+	assert.EqualError(t, err, "service file '/project/services/app.yml' already exists")
+	_, err = scaffolder.Inject(tx, "empty.yml", []byte("# nothing\n"))
+	assert.EqualError(t, err, "empty.yml: no services found")
+}
@@ -0,0 +1,434 @@
+// Package scaffold creates new service files from command line options and a built-in library of common services
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/inventory"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/edit"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// library holds the built-in service definitions, {{name}} is replaced by the name of the new service
+//
+//go:embed library/*.yml
+var library embed.FS
+
+// Entry is a service definition of the built-in library
+type Entry struct {
+	Name        string
+	Description string
+}
+
+// Library returns the entries of the built-in library sorted by name
+func Library() []Entry {
+	files, _ := library.ReadDir("library")
+	entries := make([]Entry, 0, len(files))
+	for _, file := range files {
+		content, _ := library.ReadFile("library/" + file.Name())
+		description, _, _ := strings.Cut(string(content), "\n")
+		entries = append(entries, Entry{
+			Name:        strings.TrimSuffix(file.Name(), ".yml"),
+			Description: strings.TrimSpace(strings.TrimPrefix(description, "#")),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// Options describes the service to create
+type Options struct {
+	Name string
+	// From is the name of the library entry the service starts from, empty for none
+	From     string
+	Image    string
+	Ports    []string
+	Networks []string
+	Volumes  []string
+}
+
+// Result describes the files staged for new services
+type Result struct {
+	// Paths are the paths of the new service files
+	Paths []string
+	// Networks are the networks added to the template
+	Networks []string
+	// Volumes are the named volumes added to the template
+	Volumes []string
+}
+
+// newService is a service to write into its own service file
+type newService struct {
+	name    string
+	content []byte
+	node    *yaml.Node
+}
+
+// Scaffolder creates service files in a project
+type Scaffolder struct {
+	fs           afero.Fs
+	buildDir     string
+	templatePath string
+	servicesDir  string
+	indent       int
+}
+
+// NewScaffolder creates a new instance of Scaffolder
+func NewScaffolder(buildDir, templatePath, servicesDir string) *Scaffolder {
+	return &Scaffolder{
+		fs:           afero.NewOsFs(),
+		buildDir:     buildDir,
+		templatePath: templatePath,
+		servicesDir:  servicesDir,
+	}
+}
+
+// SetFs selects the file system the files are read from and written to
+func (s *Scaffolder) SetFs(fs afero.Fs) {
+	s.fs = fs
+}
+
+// SetIndent sets the indentation of the service names in the new service files,
+// 0 takes it from the existing service files
+func (s *Scaffolder) SetIndent(indent int) {
+	s.indent = indent
+}
+
+// Stage stages the new service file and the networks and volumes missing in the template in the given transaction
+func (s *Scaffolder) Stage(tx *path.Transaction, opts Options) (*Result, error) {
+	if err := logic.ValidateServiceName(opts.Name); err != nil {
+		return nil, err
+	}
+
+	service, err := serviceNode(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keyIndent, step, err := s.indentation()
+	if err != nil {
+		return nil, err
+	}
+	content, err := render(opts.Name, service, keyIndent, step)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.stage(tx, []newService{{name: opts.Name, content: content, node: service}}, true)
+}
+
+// stage stages a service file per service and declares the networks and named volumes of the services
+// missing in the template. Without requireTemplate, a missing template is left as it is.
+func (s *Scaffolder) stage(tx *path.Transaction, services []newService, requireTemplate bool) (*Result, error) {
+	result := &Result{}
+	var networks, volumes []string
+	for _, service := range services {
+		if err := logic.ValidateServiceName(service.name); err != nil {
+			return nil, err
+		}
+		filePath := filepath.Join(s.servicesDir, service.name+".yml")
+		if slices.Contains(result.Paths, filePath) {
+			return nil, fmt.Errorf("service '%v' is defined twice", service.name)
+		}
+		if err := s.checkClash(service.name, filePath); err != nil {
+			return nil, err
+		}
+		result.Paths = append(result.Paths, filePath)
+		networks = append(networks, networksOf(service.node)...)
+		volumes = append(volumes, namedVolumesOf(service.node)...)
+	}
+
+	template, err := afero.ReadFile(s.fs, s.templatePath)
+	if err != nil && (requireTemplate || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+	if err == nil {
+		doc, err := edit.Parse(template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template file: %w", err)
+		}
+		root := doc.Root()
+		if root == nil || root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("invalid template structure")
+		}
+		result.Networks = declare(doc, "networks", networks)
+		result.Volumes = declare(doc, "volumes", volumes)
+
+		if len(result.Networks) > 0 || len(result.Volumes) > 0 {
+			updated, err := doc.Encode()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode template: %w", err)
+			}
+			if err := tx.WriteFile(s.templatePath, updated, 0644); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i, service := range services {
+		if err := tx.WriteFile(result.Paths[i], service.content, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// checkClash returns an error if the service file or a service with the same name already exists
+func (s *Scaffolder) checkClash(name, filePath string) error {
+	exists, err := path.IsExist(s.fs, filePath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("service file '%v' already exists", filePath)
+	}
+
+	services, err := inventory.FromProject(s.fs, s.buildDir, s.templatePath, s.servicesDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, service := range services {
+		if strings.EqualFold(service.Name, name) {
+			return fmt.Errorf("service '%v' is already defined in %v:%d", service.Name, service.Source, service.Line)
+		}
+	}
+	return nil
+}
+
+// indentation returns the indentation of the service names and the indentation step of the existing
+// service files, the first service file by name decides. Without service files 2 and 2 are used.
+// An indentation set with SetIndent replaces the one of the service names.
+func (s *Scaffolder) indentation() (int, int, error) {
+	keyIndent, step, err := s.detectIndentation()
+	if s.indent > 0 {
+		keyIndent = s.indent
+	}
+	return keyIndent, step, err
+}
+
+// detectIndentation returns the indentation of the service names and the indentation step of the first service file
+func (s *Scaffolder) detectIndentation() (int, int, error) {
+	names, err := logic.ReadServiceFiles(s.fs, s.servicesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, fmt.Errorf("failed to read services directory: %w", err)
+	}
+
+	for _, name := range names {
+		content, err := afero.ReadFile(s.fs, filepath.Join(s.servicesDir, name))
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read service file %s: %w", name, err)
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil || len(node.Content) == 0 {
+			continue
+		}
+		mapping := node.Content[0]
+		if mapping.Kind != yaml.MappingNode || len(mapping.Content) < 2 {
+			continue
+		}
+		keyIndent, step := mapping.Content[0].Column-1, 2
+		if value := mapping.Content[1]; value.Kind == yaml.MappingNode && len(value.Content) > 0 && value.Style&yaml.FlowStyle == 0 {
+			if delta := value.Content[0].Column - mapping.Content[0].Column; delta > 0 {
+				step = delta
+			}
+		}
+		return keyIndent, step, nil
+	}
+	return 2, 2, nil
+}
+
+// serviceNode builds the definition of the service from the library entry and the options
+func serviceNode(opts Options) (*yaml.Node, error) {
+	service := &yaml.Node{Kind: yaml.MappingNode}
+	if opts.From != "" {
+		content, err := library.ReadFile("library/" + opts.From + ".yml")
+		if err != nil {
+			var names []string
+			for _, entry := range Library() {
+				names = append(names, entry.Name)
+			}
+			return nil, fmt.Errorf("unknown library entry '%v', expected one of: %v", opts.From, strings.Join(names, ", "))
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(strings.ReplaceAll(string(content), "{{name}}", opts.Name)), &node); err != nil {
+			return nil, fmt.Errorf("failed to parse library entry %v: %w", opts.From, err)
+		}
+		service = node.Content[0]
+		// The leading comment describes the library entry
+		service.HeadComment, service.Content[0].HeadComment = "", ""
+	}
+
+	if opts.Image != "" {
+		setValue(service, "image", &yaml.Node{Kind: yaml.ScalarNode, Value: opts.Image})
+	}
+	for _, port := range opts.Ports {
+		appendItem(service, "ports", &yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle, Value: port})
+	}
+	for _, volume := range opts.Volumes {
+		appendItem(service, "volumes", &yaml.Node{Kind: yaml.ScalarNode, Value: volume})
+	}
+	for _, network := range opts.Networks {
+		networks := value(service, "networks")
+		if networks != nil && networks.Kind == yaml.MappingNode {
+			if value(networks, network) == nil {
+				networks.Content = append(networks.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: network}, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"})
+			}
+			continue
+		}
+		appendItem(service, "networks", &yaml.Node{Kind: yaml.ScalarNode, Value: network})
+	}
+
+	if value(service, "image") == nil && value(service, "build") == nil {
+		return nil, fmt.Errorf("the service needs an image: use --image or --from")
+	}
+	format.SortMapping(service, format.ServiceKeyOrder)
+	return service, nil
+}
+
+// render encodes the service as a service file with the given indentation
+func render(name string, service *yaml.Node, keyIndent, step int) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(step)
+	document := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: name}, service}}
+	if err := encoder.Encode(document); err != nil {
+		return nil, fmt.Errorf("failed to encode service %s: %w", name, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return edit.Reindent(buf.Bytes(), 0, keyIndent), nil
+}
+
+// declare adds the names missing in the top-level section of the template and returns them
+func declare(doc *edit.Document, section string, names []string) []string {
+	root := doc.Root()
+	declared := value(root, section)
+
+	var missing []string
+	for _, name := range names {
+		if name == "default" || (declared != nil && value(declared, name) != nil) || slices.Contains(missing, name) {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	entries := make([]*yaml.Node, 0, 2*len(missing))
+	for _, name := range missing {
+		entries = append(entries, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"})
+	}
+
+	switch {
+	case declared == nil:
+		// A new section is separated from the previous one by a blank line
+		var sb strings.Builder
+		sb.WriteString("\n" + section + ":\n")
+		for _, name := range missing {
+			sb.WriteString("  " + name + ":\n")
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: section}
+		root.Content = append(root.Content, key, &yaml.Node{Kind: yaml.MappingNode, Content: entries})
+		doc.SetSource(key, []byte(sb.String()))
+	case declared.Kind == yaml.MappingNode:
+		declared.Content = append(declared.Content, entries...)
+	default:
+		// An empty section is replaced by the declarations
+		*declared = yaml.Node{Kind: yaml.MappingNode, Content: entries}
+	}
+	return missing
+}
+
+// networksOf returns the networks the service is attached to
+func networksOf(service *yaml.Node) []string {
+	networks := value(service, "networks")
+	if networks == nil {
+		return nil
+	}
+	var names []string
+	switch networks.Kind {
+	case yaml.SequenceNode:
+		for _, item := range networks.Content {
+			names = append(names, item.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(networks.Content); i += 2 {
+			names = append(names, networks.Content[i].Value)
+		}
+	}
+	return names
+}
+
+// namedVolumesOf returns the named volumes mounted by the service, bind mounts are skipped
+func namedVolumesOf(service *yaml.Node) []string {
+	volumes := value(service, "volumes")
+	if volumes == nil || volumes.Kind != yaml.SequenceNode {
+		return nil
+	}
+	var names []string
+	for _, item := range volumes.Content {
+		source := ""
+		if item.Kind == yaml.MappingNode {
+			if volumeType := value(item, "type"); volumeType == nil || volumeType.Value == "volume" {
+				if sourceNode := value(item, "source"); sourceNode != nil {
+					source = sourceNode.Value
+				}
+			}
+		} else if before, _, found := strings.Cut(item.Value, ":"); found {
+			source = before
+		}
+		if source != "" && !strings.ContainsAny(source[:1], "/.~$") {
+			names = append(names, source)
+		}
+	}
+	return names
+}
+
+// value returns the value of the key in the mapping node, nil if the key is missing
+func value(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setValue sets the value of the key in the mapping node, adding the key when it is missing
+func setValue(mapping *yaml.Node, key string, node *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = node
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, node)
+}
+
+// appendItem appends the item to the sequence under the key, creating the sequence when it is missing
+func appendItem(mapping *yaml.Node, key string, item *yaml.Node) {
+	sequence := value(mapping, key)
+	if sequence == nil || sequence.Kind != yaml.SequenceNode {
+		sequence = &yaml.Node{Kind: yaml.SequenceNode}
+		setValue(mapping, key, sequence)
+	}
+	sequence.Content = append(sequence.Content, item)
+}
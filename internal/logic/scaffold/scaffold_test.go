@@ -0,0 +1,111 @@
+package scaffold
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/spf13/afero"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const template = `services:
+<dcm: include services\>
+
+networks:
+  front: # Public network
+    driver: bridge
+`
+
+// stage creates a new service in a project with the given service files and commits it
+func stage(t *testing.T, files map[string]string, opts Options) (afero.Fs, *Result, error) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/project/docker-compose-dcm.yml", []byte(template), 0644))
+	for name, content := range files {
+		assert.NoError(t, afero.WriteFile(fs, "/project/services/"+name, []byte(content), 0644))
+	}
+
+	tx, err := path.NewTransaction(fs, "/project")
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	scaffolder := NewScaffolder("/project", "/project/docker-compose-dcm.yml", "/project/services")
+	scaffolder.SetFs(fs)
+	result, err := scaffolder.Stage(tx, opts)
+	if err == nil {
+		assert.NoError(t, tx.Commit())
+	}
+	return fs, result, err
+}
+
+func readFile(t *testing.T, fs afero.Fs, name string) string {
+	content, err := afero.ReadFile(fs, name)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestStage_FromLibrary(t *testing.T) {
+	fs, result, err := stage(t, map[string]string{
+		"app.yml": "app:\n    image: app\n",
+	}, Options{Name: "cache", From: "redis", Ports: []string{"16379:6379"}, Networks: []string{"front", "back"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &Result{Paths: []string{"/project/services/cache.yml"}, Networks: []string{"back"}, Volumes: []string{"cache-data"}}, result)
+
+	assert.Equal(t, `cache:
+    image: redis:alpine
+    ports:
+        - "6379:6379"
+        - "16379:6379"
+    volumes:
+        - cache-data:/data
+    networks:
+        - front
+        - back
+    healthcheck:
+        test: ["CMD", "redis-cli", "ping"]
+        interval: 10s
+        timeout: 5s
+        retries: 5
+    restart: unless-stopped
+`, readFile(t, fs, "/project/services/cache.yml"))
+
+	assert.Equal(t, `services:
+<dcm: include services\>
+
+networks:
+  front: # Public network
+    driver: bridge
+  back:
+
+volumes:
+  cache-data:
+`, readFile(t, fs, "/project/docker-compose-dcm.yml"))
+}
+
+func TestStage_Options(t *testing.T) {
+	fs, result, err := stage(t, nil, Options{Name: "web", Image: "nginx", Ports: []string{"8080:80"}, Volumes: []string{"./html:/usr/share/nginx/html"}})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Networks)
+	assert.Empty(t, result.Volumes)
+	assert.Equal(t, "  web:\n    image: nginx\n    ports:\n      - \"8080:80\"\n    volumes:\n      - ./html:/usr/share/nginx/html\n",
+		readFile(t, fs, "/project/services/web.yml"))
+	assert.Equal(t, template, readFile(t, fs, "/project/docker-compose-dcm.yml"))
+}
+
+func TestStage_Refused(t *testing.T) {
+	files := map[string]string{"web.yml": "  web:\n    image: nginx\n  Proxy:\n    image: traefik\n"}
+
+	_, _, err := stage(t, files, Options{Name: "proxy", Image: "traefik"})
+	assert.EqualError(t, err, "service 'Proxy' is already defined in services/web.yml:3")
+
+	_, _, err = stage(t, files, Options{Name: "web", Image: "nginx"})
+	assert.EqualError(t, err, "service file '/project/services/web.yml' already exists")
+
+	_, _, err = stage(t, nil, Options{Name: "../app", Image: "app"})
+	assert.EqualError(t, err, "invalid service name '../app': use letters, digits, '_', '.' and '-' only")
+
+	_, _, err = stage(t, nil, Options{Name: "app"})
+	assert.EqualError(t, err, "the service needs an image: use --image or --from")
+
+	_, _, err = stage(t, nil, Options{Name: "app", From: "oracle"})
+	assert.EqualError(t, err, "unknown library entry 'oracle', expected one of: mongo, mysql, nginx, postgres, redis")
+}
@@ -2,11 +2,14 @@ package text
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
-	"github.com/spf13/cobra"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/spf13/afero"
 	"os"
 	"path/filepath"
 	"sort"
@@ -15,111 +18,176 @@ import (
 
 // Builder handles the process of combining separate service files into a complete docker-compose.yml
 type Builder struct {
+	fs             afero.Fs
 	buildDir       string
 	templatePath   string
 	servicesDir    string
 	outputPath     string
 	forceOverwrite bool
+	format         format.Format
+	provenance     bool
+	backup         bool
+	prompter       input.Prompter
+	sources        []provenance.Source
 }
 
 // NewBuilder creates a new instance of BuilderYaml with the specified paths and options
 func NewBuilder(buildDir, templatePath, servicesDir, outputPath string, forceOverwrite bool) *Builder {
 	return &Builder{
+		fs:             afero.NewOsFs(),
 		buildDir:       buildDir,
 		templatePath:   templatePath,
 		servicesDir:    servicesDir,
 		outputPath:     outputPath,
 		forceOverwrite: forceOverwrite,
+		format:         format.YAML,
+		provenance:     true,
+		backup:         true,
+		prompter:       input.NewConsole(os.Stdin, os.Stdout),
 	}
 }
 
+// SetFs selects the file system the files are read from and written to
+func (b *Builder) SetFs(fs afero.Fs) {
+	b.fs = fs
+}
+
+// SetFormat selects the output format of the generated compose file
+func (b *Builder) SetFormat(f format.Format) {
+	b.format = f
+}
+
+// SetProvenance enables or disables the provenance header of the generated compose file.
+// The header is never written in JSON format, which has no comments.
+func (b *Builder) SetProvenance(enabled bool) {
+	b.provenance = enabled
+}
+
+// SetBackup enables or disables the backup of an existing compose file before it is overwritten
+func (b *Builder) SetBackup(enabled bool) {
+	b.backup = enabled
+}
+
+// SetPrompter selects how the overwrite of an existing compose file is confirmed
+func (b *Builder) SetPrompter(p input.Prompter) {
+	b.prompter = p
+}
+
+// stamp prepends the provenance header listing the sources read by the builder
+func (b *Builder) stamp(content []byte) []byte {
+	if !b.provenance || b.format == format.JSON {
+		return content
+	}
+	return provenance.Stamp(logic.VersionConst, b.sources, content)
+}
+
 // Build processes the template and service files to create a complete docker-compose.yml
 func (b *Builder) Build() error {
 	// Check if the directory exists
-	exists, err := path.IsExist(b.buildDir)
+	exists, err := path.IsExist(b.fs, b.buildDir)
 	if err != nil {
-		cobra.CheckErr(err)
+		return err
 	}
 	if !exists {
 		return fmt.Errorf("Build directory '%v' not exists\n", b.outputPath)
 	}
 
-	exists, err = path.IsExist(b.templatePath)
+	exists, err = path.IsExist(b.fs, b.templatePath)
 	if err != nil {
-		cobra.CheckErr(err)
+		return err
 	}
 	if !exists {
 		return fmt.Errorf("Template file '%v 'not found\n", b.templatePath)
 	}
 
 	// Check if the services directory exists
-	exists, err = path.IsExist(b.servicesDir)
+	exists, err = path.IsExist(b.fs, b.servicesDir)
 	if err != nil {
-		cobra.CheckErr(err)
+		return err
 	}
 	if !exists {
-		return fmt.Errorf("Services directory '%v' not exists\n", logic.ServicesDirectoryConst)
+		return fmt.Errorf("Services directory '%v' not exists\n", filepath.Base(b.servicesDir))
 	}
 
 	// Check if the compose file exists
-	composeFileExists, err := path.IsExist(b.outputPath)
-	if composeFileExists && !b.forceOverwrite {
-		fmt.Printf("Compose file '%v' already exists. Overwrite[y/N]?", logic.ComposeFileNameConst)
-		answer := input.AskForYesOrNot("y", "N")
-		if !answer {
-			return fmt.Errorf("operation canceled")
+	composeFileExists, err := path.IsExist(b.fs, b.outputPath)
+	if composeFileExists {
+		if err := input.Require(b.prompter, fmt.Sprintf("Compose file '%v' already exists. Overwrite", filepath.Base(b.outputPath)), b.forceOverwrite); err != nil {
+			return err
 		}
 	}
 
 	// Read the template file
-	templateData, err := os.ReadFile(b.templatePath)
+	templateData, err := afero.ReadFile(b.fs, b.templatePath)
 	if err != nil {
 		return fmt.Errorf("Error reading file: %v\n", err)
 	}
-	templateContent := string(templateData)
-	//fmt.Println(templateContent)
+	b.sources = []provenance.Source{provenance.NewSource(b.buildDir, b.templatePath, templateData)}
 
-	services, err := os.ReadDir(b.servicesDir)
+	services, err := logic.ReadServiceFiles(b.fs, b.servicesDir)
 	if err != nil {
 		return fmt.Errorf("Error reading services directory: %v\n", err)
 	}
-	var serviceFiles []string
-	for _, entry := range services {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".yml" {
-			serviceFiles = append(serviceFiles, filepath.Join(b.servicesDir, entry.Name()))
+	var serviceFiles []logic.ServiceFile
+	for _, name := range services {
+		file := filepath.Join(b.servicesDir, name)
+		data, err := afero.ReadFile(b.fs, file)
+		if err != nil {
+			return fmt.Errorf("Error reading service file '%v': %v\n", file, err)
 		}
+		b.sources = append(b.sources, provenance.NewSource(b.buildDir, file, data))
+		serviceFiles = append(serviceFiles, logic.ServiceFile{Name: name, Content: data})
+	}
+
+	finalContent, err := Assemble(templateData, serviceFiles)
+	if err != nil {
+		return err
+	}
+	output, err := format.Convert(finalContent, b.format)
+	if err != nil {
+		return fmt.Errorf("failed to convert output to %v: %w", b.format, err)
+	}
+	output = b.stamp(output)
+
+	// Stage the output and commit it together with the backup of the existing file
+	tx, err := path.NewTransaction(b.fs, filepath.Dir(b.outputPath))
+	if err != nil {
+		return fmt.Errorf("Error writing compose file: %v\n", err)
+	}
+	defer tx.Rollback()
+
+	if composeFileExists && b.backup {
+		// Create backup of existing file before overwriting
+		tx.Backup(b.outputPath)
+	}
+	if err := tx.WriteFile(b.outputPath, output, 0644); err != nil {
+		return fmt.Errorf("Error writing compose file: %v\n", err)
 	}
-	sort.Strings(serviceFiles)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Error writing compose file: %v\n", err)
+	}
+
+	return nil
+}
+
+// Assemble replaces the service inclusion directive of the template with the content of the service files,
+// which are included in order of their names
+func Assemble(template []byte, services []logic.ServiceFile) ([]byte, error) {
+	sorted := append([]logic.ServiceFile(nil), services...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
 
 	var servicesContent strings.Builder
-	for _, file := range serviceFiles {
-		data, err := os.ReadFile(file)
-		if err != nil {
-			panic(err)
-		}
-		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for _, service := range sorted {
+		scanner := bufio.NewScanner(bytes.NewReader(service.Content))
 		for scanner.Scan() {
 			servicesContent.WriteString(scanner.Text())
 			servicesContent.WriteString("\n")
 		}
 		servicesContent.WriteString("\n")
 		if err := scanner.Err(); err != nil {
-			panic(err)
-		}
-	}
-
-	if composeFileExists {
-		// Create backup of existing file before overwriting
-		if err := path.BackupExistingFile(b.outputPath); err != nil {
-			return fmt.Errorf("Error creating backup: %v\n", err)
+			return nil, fmt.Errorf("Error reading service file '%v': %v\n", service.Name, err)
 		}
 	}
 
-	finalContent := strings.Replace(templateContent, "<dcm: include services\\>", servicesContent.String(), 1)
-	if err := os.WriteFile(b.outputPath, []byte(finalContent), 0644); err != nil {
-		panic(err)
-	}
-	fmt.Printf("Compose file '%v' created\n", b.outputPath)
-
-	return nil
+	return []byte(strings.Replace(string(template), "<dcm: include services\\>", servicesContent.String(), 1)), nil
 }
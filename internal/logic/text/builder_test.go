@@ -1,18 +1,21 @@
 package text
 
 import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
-	"os"
+	"io"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestBuilderSimple_Build(t *testing.T) {
 	// Create a temporary directory for the test
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	// Create test files structure with real-world content and comments
 	testFiles := map[string]string{
@@ -92,6 +95,10 @@ networks:
 			setupFiles:     true,
 			forceOverwrite: true,
 			validateFunc: func(t *testing.T, outputContent string) {
+				// Check for the provenance header
+				assert.True(t, strings.HasPrefix(outputContent, "# Generated by dcm "))
+				assert.Contains(t, outputContent, "# dcm:source "+logic.ServicesDirectoryConst+"/app.yml sha256:")
+
 				// Check for comment preservation
 				assert.Contains(t, outputContent, "# Docker Compose configuration for the Go-Redis application")
 				assert.Contains(t, outputContent, "# Volume configuration 1")
@@ -134,7 +141,7 @@ networks:
 			forceOverwrite: false,
 			setupFunc: func(dir string) {
 				// Create existing output file
-				err := os.WriteFile(
+				err := afero.WriteFile(fs,
 					filepath.Join(dir, logic.ComposeFileNameConst),
 					[]byte("existing content"),
 					0644,
@@ -159,7 +166,7 @@ networks:
 			forceOverwrite: true,
 			setupFunc: func(dir string) {
 				// Create only the template file
-				err := os.WriteFile(
+				err := afero.WriteFile(fs,
 					filepath.Join(dir, logic.TemplateFileNameDefaultConst),
 					[]byte(testFiles[logic.TemplateFileNameDefaultConst]),
 					0644,
@@ -173,7 +180,7 @@ networks:
 			setupFiles:     true,
 			forceOverwrite: true,
 			setupFunc: func(dir string) {
-				err := os.WriteFile(
+				err := afero.WriteFile(fs,
 					filepath.Join(dir, logic.ComposeFileNameConst),
 					[]byte("old content"),
 					0644,
@@ -192,20 +199,20 @@ networks:
 		t.Run(tt.name, func(t *testing.T) {
 			// Create a subdirectory for this test case
 			testDir := filepath.Join(tempDir, tt.name)
-			err := os.MkdirAll(testDir, 0755)
+			err := fs.MkdirAll(testDir, 0755)
 			assert.NoError(t, err)
 
 			if tt.setupFiles {
 				// Create necessary directories and files
-				err = os.Mkdir(filepath.Join(testDir, logic.ServicesDirectoryConst), 0755)
+				err = fs.Mkdir(filepath.Join(testDir, logic.ServicesDirectoryConst), 0755)
 				assert.NoError(t, err)
 
 				// Create all test files
 				for filename, content := range testFiles {
 					filePath := filepath.Join(testDir, filename)
-					err := os.MkdirAll(filepath.Dir(filePath), 0755)
+					err := fs.MkdirAll(filepath.Dir(filePath), 0755)
 					assert.NoError(t, err)
-					err = os.WriteFile(filePath, []byte(content), 0644)
+					err = afero.WriteFile(fs, filePath, []byte(content), 0644)
 					assert.NoError(t, err)
 				}
 			}
@@ -223,6 +230,7 @@ networks:
 				filepath.Join(testDir, logic.ComposeFileNameConst),
 				tt.forceOverwrite,
 			)
+			builder.SetFs(fs)
 
 			// Execute build
 			err = builder.Build()
@@ -237,7 +245,7 @@ networks:
 
 			// If validation function is provided, read output and validate
 			if tt.validateFunc != nil {
-				content, err := os.ReadFile(filepath.Join(testDir, logic.ComposeFileNameConst))
+				content, err := afero.ReadFile(fs, filepath.Join(testDir, logic.ComposeFileNameConst))
 				assert.NoError(t, err)
 				tt.validateFunc(t, string(content))
 			}
@@ -246,7 +254,8 @@ networks:
 }
 
 func TestBuilderSimple_Build_PreservesCommentedValues(t *testing.T) {
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	// Source docker-compose file content with commented values
 	testFiles := map[string]string{
@@ -273,13 +282,13 @@ func TestBuilderSimple_Build_PreservesCommentedValues(t *testing.T) {
 	}
 
 	// Setup test environment
-	err := os.Mkdir(filepath.Join(tempDir, logic.ServicesDirectoryConst), 0755)
+	err := fs.Mkdir(filepath.Join(tempDir, logic.ServicesDirectoryConst), 0755)
 	if err != nil {
 		t.Fatalf("Failed to create services directory: %v", err)
 	}
 
 	for filename, content := range testFiles {
-		err := os.WriteFile(filepath.Join(tempDir, filename), []byte(content), 0644)
+		err := afero.WriteFile(fs, filepath.Join(tempDir, filename), []byte(content), 0644)
 		if err != nil {
 			t.Fatalf("Failed to create test file %s: %v", filename, err)
 		}
@@ -293,12 +302,13 @@ func TestBuilderSimple_Build_PreservesCommentedValues(t *testing.T) {
 		filepath.Join(tempDir, logic.ComposeFileNameConst),
 		true,
 	)
+	builder.SetFs(fs)
 
 	err = builder.Build()
 	assert.NoError(t, err)
 
 	// Read and verify output
-	content, err := os.ReadFile(filepath.Join(tempDir, logic.ComposeFileNameConst))
+	content, err := afero.ReadFile(fs, filepath.Join(tempDir, logic.ComposeFileNameConst))
 	assert.NoError(t, err)
 
 	outputContent := string(content)
@@ -315,3 +325,50 @@ func TestBuilderSimple_Build_PreservesCommentedValues(t *testing.T) {
 	assert.Contains(t, outputContent, "max-size: \"10m\" # Current size")
 	assert.Contains(t, outputContent, "#max-size: \"20m\" # Future size")
 }
+
+func TestBuilderSimple_Build_ExistingOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		prompter      input.Prompter
+		expectedError string
+	}{
+		{name: "accept_overwrite", prompter: input.NewConsole(strings.NewReader("y\n"), io.Discard)},
+		{name: "reject_overwrite", prompter: input.NewConsole(strings.NewReader("\n"), io.Discard), expectedError: "operation canceled"},
+		{name: "yes_flag", prompter: input.NewFixed(true, io.Discard)},
+		{name: "no_flag", prompter: input.NewFixed(false, io.Discard), expectedError: "operation canceled"},
+		{name: "non_interactive", prompter: input.NewNonInteractive(), expectedError: "running non-interactively"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			tempDir := "/project"
+			composeFilePath := filepath.Join(tempDir, logic.ComposeFileNameConst)
+			assert.NoError(t, fs.Mkdir(filepath.Join(tempDir, logic.ServicesDirectoryConst), 0755))
+			assert.NoError(t, afero.WriteFile(fs, filepath.Join(tempDir, logic.TemplateFileNameDefaultConst), []byte("services:\n<dcm: include services\\>\n"), 0644))
+			assert.NoError(t, afero.WriteFile(fs, filepath.Join(tempDir, logic.ServicesDirectoryConst, "app.yml"), []byte("  app:\n    image: app:1.0\n"), 0644))
+			assert.NoError(t, afero.WriteFile(fs, composeFilePath, []byte("existing content"), 0644))
+
+			builder := NewBuilder(
+				tempDir,
+				filepath.Join(tempDir, logic.TemplateFileNameDefaultConst),
+				filepath.Join(tempDir, logic.ServicesDirectoryConst),
+				composeFilePath,
+				false,
+			)
+			builder.SetFs(fs)
+			builder.SetPrompter(tt.prompter)
+			err := builder.Build()
+
+			content, readErr := afero.ReadFile(fs, composeFilePath)
+			assert.NoError(t, readErr)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				assert.Equal(t, "existing content", string(content))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, string(content), "image: app:1.0")
+		})
+	}
+}
@@ -2,8 +2,12 @@ package text
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
-	"os"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/spf13/afero"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -11,6 +15,7 @@ import (
 
 // ServiceDecomposer handles the decomposition of docker-compose services into separate files
 type ServiceDecomposer struct {
+	fs           afero.Fs
 	fileSrc      string
 	fileTemplate string
 	servicesDir  string
@@ -19,49 +24,75 @@ type ServiceDecomposer struct {
 // NewServiceDecomposer creates a new instance of ServiceDecomposer
 func NewServiceDecomposer(fileSrc, fileTemplate, servicesDir string) *ServiceDecomposer {
 	return &ServiceDecomposer{
+		fs:           afero.NewOsFs(),
 		fileSrc:      fileSrc,
 		fileTemplate: fileTemplate,
 		servicesDir:  servicesDir,
 	}
 }
 
-// Decompose performs the main decomposition logic
+// SetFs selects the file system the files are read from and written to
+func (d *ServiceDecomposer) SetFs(fs afero.Fs) {
+	d.fs = fs
+}
+
+// Decompose performs the main decomposition logic, writing all files atomically
 func (d *ServiceDecomposer) Decompose() error {
-	// Create services directory if it doesn't exist
-	if err := os.MkdirAll(d.servicesDir, 0755); err != nil {
-		return fmt.Errorf("failed to create services directory: %w", err)
+	tx, err := path.NewTransaction(d.fs, filepath.Dir(d.fileTemplate))
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	// Compile regular expressions
-	servicesRe := regexp.MustCompile(`^services:\s*$`)
-	serviceDefRe := regexp.MustCompile(`^(\s{2})([^: ]+):\s*.*$`) // Matches any service definition with exactly 2 spaces
-	topLevelRe := regexp.MustCompile(`^[^: ]+:\s*$`)              // Matches top-level sections
+	if err := d.Stage(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	// Open source file
-	file, err := os.Open(d.fileSrc)
+// Stage performs the decomposition, staging the template and service files in the given
+// transaction. Nothing is written to the project until the transaction is committed.
+func (d *ServiceDecomposer) Stage(tx *path.Transaction) error {
+	content, err := afero.ReadFile(d.fs, d.fileSrc)
 	if err != nil {
 		return fmt.Errorf("failed to open source file: %w", err)
 	}
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-			fmt.Printf("Failed to close source file: %v\n", err)
-		}
-	}(file)
 
-	// Create template file
-	fileTemplate, err := os.Create(d.fileTemplate)
+	decomposition, err := Split(content)
 	if err != nil {
-		return fmt.Errorf("failed to create template file: %w", err)
+		return err
 	}
-	defer func(fileTemplate *os.File) {
-		err := fileTemplate.Close()
-		if err != nil {
-			fmt.Printf("Failed to close template file: %v\n", err)
+
+	for _, service := range decomposition.Services {
+		if err := tx.WriteFile(filepath.Join(d.servicesDir, service.Name), service.Content, 0644); err != nil {
+			return fmt.Errorf("failed to write service file: %w", err)
 		}
-	}(fileTemplate)
+	}
+
+	// Write template file
+	if err := tx.WriteFile(d.fileTemplate, decomposition.Template, 0644); err != nil {
+		return fmt.Errorf("failed to write template file: %w", err)
+	}
 
-	scanner := bufio.NewScanner(file)
+	return nil
+}
+
+// Split splits the content of a compose file into the template and one service file per service
+func Split(content []byte) (*logic.Decomposition, error) {
+	// Compile regular expressions
+	servicesRe := regexp.MustCompile(`^services:\s*$`)
+	serviceDefRe := regexp.MustCompile(`^(\s{2})([^: ]+):\s*.*$`) // Matches any service definition with exactly 2 spaces
+	topLevelRe := regexp.MustCompile(`^[^: ]+:\s*$`)              // Matches top-level sections
+
+	// The provenance header of a generated file is not part of the template
+	content = provenance.Strip(content)
+
+	decomposition := &logic.Decomposition{}
+	saveService := func(name string, content string) {
+		decomposition.Services = append(decomposition.Services, logic.ServiceFile{Name: name + ".yml", Content: []byte(content)})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
 	var templateBuilder strings.Builder
 	var serviceBuilder strings.Builder
 	var currentServiceName string
@@ -101,14 +132,7 @@ func (d *ServiceDecomposer) Decompose() error {
 		if topLevelRe.MatchString(line) && !strings.HasPrefix(line, " ") {
 			if currentServiceName != "" {
 				// Save current service
-				err := os.WriteFile(
-					filepath.Join(d.servicesDir, currentServiceName+".yml"),
-					[]byte(serviceBuilder.String()),
-					0644,
-				)
-				if err != nil {
-					return fmt.Errorf("failed to write service file: %w", err)
-				}
+				saveService(currentServiceName, serviceBuilder.String())
 				serviceBuilder.Reset()
 				currentServiceName = ""
 			}
@@ -128,14 +152,7 @@ func (d *ServiceDecomposer) Decompose() error {
 			if matches := serviceDefRe.FindStringSubmatch(line); matches != nil {
 				// Save previous service if exists
 				if currentServiceName != "" {
-					err := os.WriteFile(
-						filepath.Join(d.servicesDir, currentServiceName+".yml"),
-						[]byte(serviceBuilder.String()),
-						0644,
-					)
-					if err != nil {
-						return fmt.Errorf("failed to write service file: %w", err)
-					}
+					saveService(currentServiceName, serviceBuilder.String())
 					serviceBuilder.Reset()
 				}
 
@@ -160,20 +177,13 @@ func (d *ServiceDecomposer) Decompose() error {
 
 	// Save last service if exists
 	if currentServiceName != "" {
-		err := os.WriteFile(
-			filepath.Join(d.servicesDir, currentServiceName+".yml"),
-			[]byte(serviceBuilder.String()),
-			0644,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to write service file: %w", err)
-		}
+		saveService(currentServiceName, serviceBuilder.String())
 	}
 
-	// Write template file
-	if _, err := fileTemplate.WriteString(templateBuilder.String()); err != nil {
-		return fmt.Errorf("failed to write template file: %w", err)
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
 	}
 
-	return nil
+	decomposition.Template = []byte(templateBuilder.String())
+	return decomposition, nil
 }
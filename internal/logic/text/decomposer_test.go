@@ -2,15 +2,16 @@ package text
 
 import (
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
-	"os"
+	"github.com/spf13/afero"
 	"path/filepath"
 	"strings"
 	"testing"
 )
 
 func TestServiceDecomposer(t *testing.T) {
-	// Create temporary directory for test files
-	tmpDir := t.TempDir()
+	// Create an in-memory project
+	fs := afero.NewMemMapFs()
+	tmpDir := "/project"
 
 	// Source docker-compose file content with comments
 	sourceContent := `# Main docker-compose configuration
@@ -146,13 +147,14 @@ networks:
 	servicesDir := filepath.Join(tmpDir, logic.ServicesDirectoryConst)
 
 	// Write source file
-	err := os.WriteFile(fileSrc, []byte(sourceContent), 0644)
+	err := afero.WriteFile(fs, fileSrc, []byte(sourceContent), 0644)
 	if err != nil {
 		t.Fatalf("Failed to write source file: %v", err)
 	}
 
 	// Create and run decomposer
 	decomposer := NewServiceDecomposer(fileSrc, fileTemplate, servicesDir)
+	decomposer.SetFs(fs)
 	err = decomposer.Decompose()
 	if err != nil {
 		t.Fatalf("Decompose failed: %v", err)
@@ -167,7 +169,7 @@ networks:
 	}
 
 	// Test template file
-	templateContent, err := os.ReadFile(fileTemplate)
+	templateContent, err := afero.ReadFile(fs, fileTemplate)
 	if err != nil {
 		t.Fatalf("Failed to read template file: %v", err)
 	}
@@ -176,7 +178,7 @@ networks:
 	}
 
 	// Test app service file
-	appContent, err := os.ReadFile(filepath.Join(servicesDir, "app.yml"))
+	appContent, err := afero.ReadFile(fs, filepath.Join(servicesDir, "app.yml"))
 	if err != nil {
 		t.Fatalf("Failed to read app service file: %v", err)
 	}
@@ -185,7 +187,7 @@ networks:
 	}
 
 	// Test redis service file
-	redisContent, err := os.ReadFile(filepath.Join(servicesDir, "redis.yml"))
+	redisContent, err := afero.ReadFile(fs, filepath.Join(servicesDir, "redis.yml"))
 	if err != nil {
 		t.Fatalf("Failed to read redis service file: %v", err)
 	}
@@ -195,13 +197,13 @@ networks:
 
 	// Additional checks
 	t.Run("Check services directory created", func(t *testing.T) {
-		if _, err := os.Stat(servicesDir); os.IsNotExist(err) {
+		if _, err := fs.Stat(servicesDir); err != nil {
 			t.Error("Services directory was not created")
 		}
 	})
 
 	t.Run("Check number of service files", func(t *testing.T) {
-		files, err := os.ReadDir(servicesDir)
+		files, err := afero.ReadDir(fs, servicesDir)
 		if err != nil {
 			t.Fatalf("Failed to read services directory: %v", err)
 		}
@@ -212,7 +214,8 @@ networks:
 }
 
 func TestServiceDecomposerPreservesCommentedValues(t *testing.T) {
-	tmpDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tmpDir := "/project"
 
 	// Source docker-compose file content with commented values
 	sourceContent := `services:
@@ -258,20 +261,21 @@ func TestServiceDecomposerPreservesCommentedValues(t *testing.T) {
 	servicesDir := filepath.Join(tmpDir, logic.ServicesDirectoryConst)
 
 	// Write source file
-	err := os.WriteFile(fileSrc, []byte(sourceContent), 0644)
+	err := afero.WriteFile(fs, fileSrc, []byte(sourceContent), 0644)
 	if err != nil {
 		t.Fatalf("Failed to write source file: %v", err)
 	}
 
 	// Create and run decomposer
 	decomposer := NewServiceDecomposer(fileSrc, fileTemplate, servicesDir)
+	decomposer.SetFs(fs)
 	err = decomposer.Decompose()
 	if err != nil {
 		t.Fatalf("Decompose failed: %v", err)
 	}
 
 	// Read generated app service file
-	appContent, err := os.ReadFile(filepath.Join(servicesDir, "app.yml"))
+	appContent, err := afero.ReadFile(fs, filepath.Join(servicesDir, "app.yml"))
 	if err != nil {
 		t.Fatalf("Failed to read app service file: %v", err)
 	}
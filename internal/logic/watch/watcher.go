@@ -0,0 +1,261 @@
+// Package watch rebuilds a project whenever its template or service files change
+package watch
+
+import (
+	"context"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Watcher watches the template file and the services directory and calls a rebuild function
+// after each burst of relevant changes
+type Watcher struct {
+	templatePath string
+	servicesDir  string
+	debounce     time.Duration
+}
+
+// NewWatcher creates a new instance of Watcher. Events arriving within the debounce interval
+// of each other are collected into a single rebuild.
+func NewWatcher(templatePath, servicesDir string, debounce time.Duration) *Watcher {
+	return &Watcher{
+		templatePath: filepath.Clean(templatePath),
+		servicesDir:  filepath.Clean(servicesDir),
+		debounce:     debounce,
+	}
+}
+
+// Run watches for changes until the context is canceled. The rebuild function receives the
+// sorted list of files that changed since the previous call.
+func (w *Watcher) Run(ctx context.Context, rebuild func(changed []string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// Directories are watched instead of files, editors often replace files on save
+	templateDir := filepath.Dir(w.templatePath)
+	if err := watcher.Add(templateDir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", templateDir, err)
+	}
+	if err := watcher.Add(w.servicesDir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", w.servicesDir, err)
+	}
+
+	changed := make(map[string]bool)
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			name := filepath.Clean(event.Name)
+			if name == w.servicesDir && event.Has(fsnotify.Create) {
+				// The services directory was recreated, e.g. by a decomposition
+				_ = watcher.Add(w.servicesDir)
+				changed[name] = true
+				timer.Reset(w.debounce)
+				continue
+			}
+			if !w.IsRelevant(name) {
+				continue
+			}
+			changed[name] = true
+			timer.Reset(w.debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("file watcher failed: %w", err)
+
+		case <-timer.C:
+			files := make([]string, 0, len(changed))
+			for name := range changed {
+				files = append(files, name)
+			}
+			sort.Strings(files)
+			changed = make(map[string]bool)
+			rebuild(files)
+		}
+	}
+}
+
+// IsRelevant reports whether a change of the given file requires a rebuild.
+// Only the template and service files count, editor temporary files and backups created by dcm don't.
+func (w *Watcher) IsRelevant(name string) bool {
+	name = filepath.Clean(name)
+	if name == w.templatePath {
+		return true
+	}
+	if filepath.Dir(name) != w.servicesDir || !logic.IsServiceFile(filepath.Base(name)) {
+		return false
+	}
+	if source, ok := logic.BackupSource(filepath.Base(name)); ok {
+		// A backup is written along with the file it was made of, the change of that file counts
+		_, err := os.Stat(filepath.Join(w.servicesDir, source))
+		return err != nil
+	}
+	return true
+}
+
+// Validate parses the template and all service files and returns the problems found, one per file
+func Validate(fs afero.Fs, templatePath, servicesDir string) []error {
+	return NewCache(fs, templatePath, servicesDir).Update(nil)
+}
+
+// Cache keeps the template and the service files in memory between rebuilds. Only the files that
+// changed since the previous rebuild are read and parsed again.
+type Cache struct {
+	fs           afero.Fs
+	memory       afero.Fs
+	templatePath string
+	servicesDir  string
+	// errs holds the problem found in each cached file
+	errs map[string]error
+}
+
+// NewCache creates a new, empty instance of Cache
+func NewCache(fs afero.Fs, templatePath, servicesDir string) *Cache {
+	return &Cache{
+		fs:           fs,
+		templatePath: filepath.Clean(templatePath),
+		servicesDir:  filepath.Clean(servicesDir),
+	}
+}
+
+// Update reads the changed files into the cache and returns the problems found in the cached files,
+// one per file. All files are read on the first call and when the services directory itself changed.
+func (c *Cache) Update(changed []string) []error {
+	reload := c.memory == nil
+	for _, name := range changed {
+		if filepath.Clean(name) == c.servicesDir {
+			reload = true
+		}
+	}
+
+	if reload {
+		c.memory = afero.NewMemMapFs()
+		c.errs = make(map[string]error)
+		c.read(c.templatePath)
+	}
+	delete(c.errs, c.servicesDir)
+	files, err := logic.ReadServiceFiles(c.fs, c.servicesDir)
+	if err != nil {
+		c.errs[c.servicesDir] = fmt.Errorf("%s: %w", c.servicesDir, err)
+	}
+	_ = c.memory.MkdirAll(c.servicesDir, 0755)
+
+	// Whether a file is a service file or a backup depends on the other files, so the cached service
+	// files are the ones of the directory: the changed ones and the new ones are read, the others dropped
+	services := make(map[string]bool, len(files))
+	for _, name := range files {
+		services[filepath.Join(c.servicesDir, name)] = true
+	}
+	for _, name := range changed {
+		name = filepath.Clean(name)
+		if name == c.templatePath || services[name] {
+			c.read(name)
+		}
+	}
+	cached, _ := afero.ReadDir(c.memory, c.servicesDir)
+	for _, entry := range cached {
+		if filePath := filepath.Join(c.servicesDir, entry.Name()); !services[filePath] {
+			c.drop(filePath)
+		}
+	}
+	for filePath := range services {
+		if exists, _ := afero.Exists(c.memory, filePath); !exists {
+			c.read(filePath)
+		}
+	}
+
+	names := make([]string, 0, len(c.errs))
+	for name := range c.errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]error, 0, len(names))
+	for _, name := range names {
+		errs = append(errs, c.errs[name])
+	}
+	return errs
+}
+
+// read reads a file into the cache and parses it, a removed service file is dropped from the cache
+func (c *Cache) read(filePath string) {
+	c.drop(filePath)
+
+	content, err := afero.ReadFile(c.fs, filePath)
+	if err != nil {
+		if !os.IsNotExist(err) || filePath == c.templatePath {
+			c.errs[filePath] = fmt.Errorf("%s: %w", filePath, err)
+		}
+		return
+	}
+	_ = c.memory.MkdirAll(filepath.Dir(filePath), 0755)
+	_ = afero.WriteFile(c.memory, filePath, content, 0644)
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		c.errs[filePath] = fmt.Errorf("%s: %w", filePath, err)
+	}
+}
+
+// drop removes a file and its problem from the cache
+func (c *Cache) drop(filePath string) {
+	delete(c.errs, filePath)
+	_ = c.memory.Remove(filePath)
+}
+
+// Fs returns a file system reading the template and the service files from the cache. Everything else,
+// including all writes, goes to the underlying file system.
+func (c *Cache) Fs() afero.Fs {
+	return &cachedFs{Fs: c.fs, cache: c}
+}
+
+// cachedFs is the file system returned by Cache.Fs
+type cachedFs struct {
+	afero.Fs
+	cache *Cache
+}
+
+// cached reports whether the file is read from the cache
+func (f *cachedFs) cached(name string) bool {
+	name = filepath.Clean(name)
+	return f.cache.memory != nil &&
+		(name == f.cache.templatePath || name == f.cache.servicesDir || filepath.Dir(name) == f.cache.servicesDir)
+}
+
+func (f *cachedFs) Open(name string) (afero.File, error) {
+	if f.cached(name) {
+		return f.cache.memory.Open(name)
+	}
+	return f.Fs.Open(name)
+}
+
+func (f *cachedFs) Stat(name string) (os.FileInfo, error) {
+	if f.cached(name) {
+		return f.cache.memory.Stat(name)
+	}
+	return f.Fs.Stat(name)
+}
+
+func (f *cachedFs) Name() string {
+	return "cachedFs"
+}
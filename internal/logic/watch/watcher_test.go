@@ -0,0 +1,146 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher_IsRelevant(t *testing.T) {
+	project := t.TempDir()
+	servicesDir := filepath.Join(project, "services")
+	assert.NoError(t, os.MkdirAll(servicesDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(servicesDir, "app.yml"), []byte("  app:\n"), 0644))
+	watcher := NewWatcher(filepath.Join(project, "docker-compose-dcm.yml"), servicesDir, time.Millisecond)
+
+	assert.True(t, watcher.IsRelevant(filepath.Join(project, "docker-compose-dcm.yml")))
+	assert.True(t, watcher.IsRelevant(filepath.Join(servicesDir, "app.yml")))
+	assert.False(t, watcher.IsRelevant(filepath.Join(project, "docker-compose.yml")))
+	assert.False(t, watcher.IsRelevant(filepath.Join(project, "docker-compose-dcm-20241231.yml")))
+	assert.False(t, watcher.IsRelevant(filepath.Join(project, ".dcm.lock")))
+	assert.False(t, watcher.IsRelevant(filepath.Join(servicesDir, "nested", "app.yml")))
+	assert.False(t, watcher.IsRelevant(filepath.Join(servicesDir, "app-20241231.yml")))
+	// Without app.yml next to it, a dated name is a service file
+	assert.True(t, watcher.IsRelevant(filepath.Join(servicesDir, "db-20240101.yml")))
+}
+
+func TestValidate(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	templatePath := filepath.Join("/project", "docker-compose-dcm.yml")
+	servicesDir := filepath.Join("/project", "services")
+	assert.NoError(t, fs.MkdirAll(servicesDir, 0755))
+	assert.NoError(t, afero.WriteFile(fs, templatePath, []byte("services:\n<dcm: include services\\>\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, filepath.Join(servicesDir, "app.yml"), []byte("  app:\n    image: app\n"), 0644))
+
+	assert.Empty(t, Validate(fs, templatePath, servicesDir))
+
+	assert.NoError(t, afero.WriteFile(fs, filepath.Join(servicesDir, "broken.yml"), []byte("  broken:\n    ports: [\n"), 0644))
+	errs := Validate(fs, templatePath, servicesDir)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "broken.yml")
+}
+
+func TestCache(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	templatePath := filepath.Join("/project", "docker-compose-dcm.yml")
+	servicesDir := filepath.Join("/project", "services")
+	appPath := filepath.Join(servicesDir, "app.yml")
+	dbPath := filepath.Join(servicesDir, "db.yml")
+	assert.NoError(t, fs.MkdirAll(servicesDir, 0755))
+	assert.NoError(t, afero.WriteFile(fs, templatePath, []byte("services:\n<dcm: include services\\>\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, appPath, []byte("  app:\n    image: app\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, filepath.Join(servicesDir, "app-20241231.yml"), []byte("  app:\n"), 0644))
+
+	cache := NewCache(fs, templatePath, servicesDir)
+	assert.Empty(t, cache.Update(nil))
+	entries, err := afero.ReadDir(cache.Fs(), servicesDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Files that did not change are not read again
+	assert.NoError(t, afero.WriteFile(fs, appPath, []byte("  app:\n    image: app:2\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, dbPath, []byte("  db:\n    ports: [\n"), 0644))
+	errs := cache.Update([]string{dbPath})
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "db.yml")
+	content, err := afero.ReadFile(cache.Fs(), appPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "  app:\n    image: app\n", string(content))
+
+	// A dated service file without the file it would be a backup of is cached, the backup is not
+	reportPath := filepath.Join(servicesDir, "report-20240101.yml")
+	assert.NoError(t, afero.WriteFile(fs, reportPath, []byte("  report:\n"), 0644))
+	assert.Len(t, cache.Update([]string{reportPath, filepath.Join(servicesDir, "app-20241231.yml")}), 1)
+	exists, err := afero.Exists(cache.Fs(), reportPath)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = afero.Exists(cache.Fs(), filepath.Join(servicesDir, "app-20241231.yml"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, fs.Remove(reportPath))
+
+	// Removed service files are dropped
+	assert.NoError(t, fs.Remove(dbPath))
+	assert.Empty(t, cache.Update([]string{appPath, dbPath}))
+	content, err = afero.ReadFile(cache.Fs(), appPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "  app:\n    image: app:2\n", string(content))
+	_, err = cache.Fs().Stat(dbPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// Everything else, including writes, goes to the file system
+	assert.NoError(t, afero.WriteFile(cache.Fs(), "/project/docker-compose.yml", []byte("services:\n"), 0644))
+	exists, err = afero.Exists(fs, "/project/docker-compose.yml")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestWatcher_Run(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "docker-compose-dcm.yml")
+	servicesDir := filepath.Join(dir, "services")
+	assert.NoError(t, os.Mkdir(servicesDir, 0755))
+	assert.NoError(t, os.WriteFile(templatePath, []byte("services:\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := make(chan []string, 10)
+	done := make(chan error, 1)
+	watcher := NewWatcher(templatePath, servicesDir, 200*time.Millisecond)
+	go func() {
+		done <- watcher.Run(ctx, func(changed []string) {
+			calls <- changed
+		})
+	}()
+
+	// Give the watcher time to register the directories
+	time.Sleep(100 * time.Millisecond)
+
+	// A burst of changes results in a single rebuild, ignored files are not reported
+	assert.NoError(t, os.WriteFile(filepath.Join(servicesDir, "app.yml"), []byte("  app:\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(servicesDir, ".app.yml.swp"), []byte(""), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(""), 0644))
+	assert.NoError(t, os.WriteFile(templatePath, []byte("services:\n\n"), 0644))
+
+	select {
+	case changed := <-calls:
+		assert.Equal(t, []string{templatePath, filepath.Join(servicesDir, "app.yml")}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a rebuild")
+	}
+
+	select {
+	case changed := <-calls:
+		t.Fatalf("Expected a single rebuild, got another one for %v", changed)
+	case <-time.After(400 * time.Millisecond):
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
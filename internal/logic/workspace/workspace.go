@@ -0,0 +1,109 @@
+// Package workspace finds all dcm projects below a root directory and runs an operation on each of them
+package workspace
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// skippedDirectories are never searched for projects
+var skippedDirectories = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// Result is the outcome of an operation on a single project
+type Result struct {
+	// Dir is the project directory
+	Dir      string
+	Err      error
+	Duration time.Duration
+}
+
+// Discover returns every directory below root that contains one of the given marker files,
+// e.g. the template file or the project config file. Hidden directories are skipped.
+func Discover(fs afero.Fs, root string, markers ...string) ([]string, error) {
+	var projects []string
+
+	err := afero.Walk(fs, root, func(path string, entry os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != root && (strings.HasPrefix(entry.Name(), ".") || skippedDirectories[entry.Name()]) {
+			return filepath.SkipDir
+		}
+
+		for _, marker := range markers {
+			info, err := fs.Stat(filepath.Join(path, marker))
+			if err == nil && !info.IsDir() {
+				projects = append(projects, path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for projects: %w", err)
+	}
+
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// Run calls the operation for every project with at most 'jobs' operations running at the same time.
+// The results are returned in the order of the projects.
+func Run(projects []string, jobs int, operation func(dir string) error) []Result {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]Result, len(projects))
+	semaphore := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+
+	for i, dir := range projects {
+		wg.Add(1)
+		go func(i int, dir string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			start := time.Now()
+			err := runSafely(dir, operation)
+			results[i] = Result{Dir: dir, Err: err, Duration: time.Since(start)}
+		}(i, dir)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// runSafely calls the operation turning a panic into an error, so one project cannot abort the others
+func runSafely(dir string, operation func(dir string) error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return operation(dir)
+}
+
+// Failed returns the number of failed operations
+func Failed(results []Result) int {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,70 @@
+package workspace
+
+import (
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	root := "/workspace"
+	files := []string{
+		"a/docker-compose-dcm.yml",
+		"b/c/dcm.yaml",
+		"b/docker-compose.yml",
+		".hidden/docker-compose-dcm.yml",
+		"node_modules/pkg/docker-compose-dcm.yml",
+		"vendor/docker-compose-dcm.yml",
+	}
+	for _, file := range files {
+		filePath := filepath.Join(root, file)
+		assert.NoError(t, fs.MkdirAll(filepath.Dir(filePath), 0755))
+		assert.NoError(t, afero.WriteFile(fs, filePath, []byte("services:\n"), 0644))
+	}
+	// A directory named like a marker doesn't count
+	assert.NoError(t, fs.MkdirAll(filepath.Join(root, "d", "dcm.yaml"), 0755))
+
+	projects, err := Discover(fs, root, "docker-compose-dcm.yml", "dcm.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "a"), filepath.Join(root, "b", "c")}, projects)
+}
+
+func TestRun(t *testing.T) {
+	projects := []string{"p1", "p2", "p3", "p4", "p5", "p6"}
+
+	var running, maxRunning int32
+	results := Run(projects, 2, func(dir string) error {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			observed := atomic.LoadInt32(&maxRunning)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		switch dir {
+		case "p2":
+			return errors.New("failed")
+		case "p4":
+			panic("broken")
+		}
+		return nil
+	})
+
+	assert.LessOrEqual(t, maxRunning, int32(2))
+	assert.Len(t, results, len(projects))
+	for i, result := range results {
+		assert.Equal(t, projects[i], result.Dir)
+	}
+	assert.EqualError(t, results[1].Err, "failed")
+	assert.EqualError(t, results[3].Err, "panic: broken")
+	assert.Equal(t, 2, Failed(results))
+}
@@ -5,47 +5,95 @@ import (
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/edit"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/helper"
+	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 // Builder handles the process of combining separate service files into a complete docker-compose.yml
 type Builder struct {
+	fs             afero.Fs
 	buildDir       string
 	templatePath   string
 	servicesDir    string
 	outputPath     string
 	forceOverwrite bool
+	format         format.Format
+	provenance     bool
+	backup         bool
+	prompter       input.Prompter
+	sources        []provenance.Source
 }
 
 // NewBuilder creates a new instance of Builder with the specified paths and options
 func NewBuilder(buildDir, templatePath, servicesDir, outputPath string, forceOverwrite bool) *Builder {
 	return &Builder{
+		fs:             afero.NewOsFs(),
 		buildDir:       buildDir,
 		templatePath:   templatePath,
 		servicesDir:    servicesDir,
 		outputPath:     outputPath,
 		forceOverwrite: forceOverwrite,
+		format:         format.YAML,
+		provenance:     true,
+		backup:         true,
+		prompter:       input.NewConsole(os.Stdin, os.Stdout),
 	}
 }
 
+// SetFs selects the file system the files are read from and written to
+func (b *Builder) SetFs(fs afero.Fs) {
+	b.fs = fs
+}
+
+// SetFormat selects the output format of the generated compose file
+func (b *Builder) SetFormat(f format.Format) {
+	b.format = f
+}
+
+// SetProvenance enables or disables the provenance header of the generated compose file.
+// The header is never written in JSON format, which has no comments.
+func (b *Builder) SetProvenance(enabled bool) {
+	b.provenance = enabled
+}
+
+// SetBackup enables or disables the backup of an existing compose file before it is overwritten
+func (b *Builder) SetBackup(enabled bool) {
+	b.backup = enabled
+}
+
+// SetPrompter selects how the overwrite of an existing compose file is confirmed
+func (b *Builder) SetPrompter(p input.Prompter) {
+	b.prompter = p
+}
+
+// stamp prepends the provenance header listing the sources read by the builder
+func (b *Builder) stamp(content []byte) []byte {
+	if !b.provenance || b.format == format.JSON {
+		return content
+	}
+	return provenance.Stamp(logic.VersionConst, b.sources, content)
+}
+
 // Build processes the template and service files to create a complete docker-compose.yml
 func (b *Builder) Build() error {
 	// Check if the compose file exists
-	composeFileExists, err := path.IsExist(b.outputPath)
-	if composeFileExists && !b.forceOverwrite {
-		fmt.Printf("Compose file '%v' already exists. Overwrite[y/N]?", logic.ComposeFileNameConst)
-		answer := input.AskForYesOrNot("y", "N")
-		if !answer {
-			return fmt.Errorf("operation canceled")
+	composeFileExists, err := path.IsExist(b.fs, b.outputPath)
+	if composeFileExists {
+		if err := input.Require(b.prompter, fmt.Sprintf("Compose file '%v' already exists. Overwrite", filepath.Base(b.outputPath)), b.forceOverwrite); err != nil {
+			return err
 		}
 	}
 
-	// Read and parse the template file
-	templateNode, err := b.readTemplate()
+	// Read the template file
+	template, err := b.readTemplate()
 	if err != nil {
 		return fmt.Errorf("failed to read template: %w", err)
 	}
@@ -57,20 +105,19 @@ func (b *Builder) Build() error {
 	}
 
 	// Merge services into the template
-	err = b.mergeServices(templateNode, services)
+	merged, err := Assemble(template, services)
 	if err != nil {
-		return fmt.Errorf("failed to merge services: %w", err)
+		return err
 	}
 
-	if composeFileExists {
-		// Create backup of existing file before overwriting
-		if err := path.BackupExistingFile(b.outputPath); err != nil {
-			return fmt.Errorf("Error creating backup: %v\n", err)
-		}
+	// Render the output in the requested format
+	output, err := b.renderOutput(merged)
+	if err != nil {
+		return fmt.Errorf("failed to render output: %w", err)
 	}
 
-	// Write the final docker-compose.yml
-	err = b.writeOutput(templateNode)
+	// Write the final docker-compose.yml together with the backup of the existing file
+	err = b.writeOutput(output, composeFileExists && b.backup)
 	if err != nil {
 		return fmt.Errorf("failed to write output: %w", err)
 	}
@@ -78,57 +125,92 @@ func (b *Builder) Build() error {
 	return nil
 }
 
-// readTemplate reads and parses the template docker-compose file preserving comments
-func (b *Builder) readTemplate() (*yaml.Node, error) {
-	content, err := os.ReadFile(b.templatePath)
+// readTemplate reads the template docker-compose file
+func (b *Builder) readTemplate() ([]byte, error) {
+	content, err := afero.ReadFile(b.fs, b.templatePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read template file: %w", err)
 	}
+	b.sources = []provenance.Source{provenance.NewSource(b.buildDir, b.templatePath, content)}
 
-	var node yaml.Node
-	err = yaml.Unmarshal(content, &node)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse template YAML: %w", err)
-	}
-
-	return &node, nil
+	return content, nil
 }
 
-// readServices reads all service definition files from the services directory preserving comments
-func (b *Builder) readServices() ([]*yaml.Node, error) {
-	var services []*yaml.Node
+// readServices reads all service definition files from the services directory
+func (b *Builder) readServices() ([]logic.ServiceFile, error) {
+	var services []logic.ServiceFile
 
-	files, err := os.ReadDir(b.servicesDir)
+	names, err := logic.ReadServiceFiles(b.fs, b.servicesDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read services directory: %w", err)
 	}
 
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".yml") {
-			continue
-		}
-
-		content, err := os.ReadFile(filepath.Join(b.servicesDir, file.Name()))
+	for _, name := range names {
+		filePath := filepath.Join(b.servicesDir, name)
+		content, err := afero.ReadFile(b.fs, filePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read service file %s: %w", file.Name(), err)
+			return nil, fmt.Errorf("failed to read service file %s: %w", name, err)
 		}
+		b.sources = append(b.sources, provenance.NewSource(b.buildDir, filePath, content))
+		services = append(services, logic.ServiceFile{Name: name, Content: content})
+	}
+
+	return services, nil
+}
 
-		var node yaml.Node
-		err = yaml.Unmarshal(content, &node)
+// Assemble merges the service files into the template keeping their original formatting
+// and returns the content of the compose file
+func Assemble(template []byte, services []logic.ServiceFile) ([]byte, error) {
+	templateDoc, err := edit.Parse(template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: failed to parse template YAML: %w", err)
+	}
+
+	sorted := append([]logic.ServiceFile(nil), services...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var serviceDocs []*edit.Document
+	for _, service := range sorted {
+		doc, err := edit.Parse(service.Content)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse service YAML %s: %w", file.Name(), err)
+			return nil, fmt.Errorf("failed to read services: failed to parse service YAML %s: %w", service.Name, err)
+		}
+		if doc.Root() != nil {
+			serviceDocs = append(serviceDocs, doc)
 		}
+	}
+
+	if err := mergeServices(templateDoc, serviceDocs); err != nil {
+		return nil, fmt.Errorf("failed to merge services: %w", err)
+	}
+
+	encoded, err := templateDoc.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render output: failed to encode YAML: %w", err)
+	}
 
-		if len(node.Content) > 0 {
-			services = append(services, node.Content[0])
+	// Remove lines containing placeholder
+	lines := strings.Split(string(encoded), "\n")
+	var filteredLines []string
+	for _, line := range lines {
+		if !strings.Contains(line, "<dcm: include services") {
+			filteredLines = append(filteredLines, line)
 		}
 	}
+	output := strings.Join(filteredLines, "\n")
 
-	return services, nil
+	// Make sure the file ends with a single blank line
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+
+	return []byte(output), nil
 }
 
-// mergeServices combines service definitions with the template preserving comments
-func (b *Builder) mergeServices(templateNode *yaml.Node, services []*yaml.Node) error {
+// mergeServices replaces the services section of the template with the service definitions.
+// The service files are copied as they are, only their indentation is adjusted to the services section.
+func mergeServices(templateDoc *edit.Document, services []*edit.Document) error {
+	templateNode := templateDoc.Node()
 	if templateNode.Kind != yaml.DocumentNode || len(templateNode.Content) == 0 {
 		return fmt.Errorf("invalid template structure")
 	}
@@ -140,24 +222,26 @@ func (b *Builder) mergeServices(templateNode *yaml.Node, services []*yaml.Node)
 	}
 
 	// Create new mapping node for services
-	newServicesNode := &yaml.Node{
-		Kind:        yaml.MappingNode,
-		Style:       servicesNode.Style,
-		HeadComment: servicesNode.HeadComment,
-		LineComment: servicesNode.LineComment,
-		FootComment: "\n", // Add a blank line after the services section
-	}
+	newServicesNode := &yaml.Node{Kind: yaml.MappingNode}
 
 	// Add all service definitions
-	for _, serviceNode := range services {
-		for i := 0; i < len(serviceNode.Content); i += 2 {
+	for _, serviceDoc := range services {
+		serviceNode := serviceDoc.Root()
+		for i := 0; i+1 < len(serviceNode.Content); i += 2 {
 			key := serviceNode.Content[i]
 			value := serviceNode.Content[i+1]
 
+			content, err := renderService(serviceDoc, key, value)
+			if err != nil {
+				return fmt.Errorf("failed to render service %s: %w", key.Value, err)
+			}
+			content = edit.Reindent(content, 0, 2)
+
 			// Add a blank line before each service (except the first)
-			if len(newServicesNode.Content) > 0 && key.HeadComment == "" {
-				key.HeadComment = "\n"
+			if len(newServicesNode.Content) > 0 {
+				content = append([]byte("\n"), content...)
 			}
+			templateDoc.SetSource(key, content)
 
 			newServicesNode.Content = append(newServicesNode.Content,
 				key,   // key
@@ -166,54 +250,48 @@ func (b *Builder) mergeServices(templateNode *yaml.Node, services []*yaml.Node)
 		}
 	}
 
-	// Replace the services node
-	for i := 0; i < len(rootMap.Content); i += 2 {
-		if rootMap.Content[i].Value == "services" {
-			// Keep comments from the original services node
-			rootMap.Content[i+1] = newServicesNode
-			break
+	// Replace the services node and drop the service inclusion directive
+	content := rootMap.Content[:0]
+	for i := 0; i+1 < len(rootMap.Content); i += 2 {
+		key, value := rootMap.Content[i], rootMap.Content[i+1]
+		if strings.HasPrefix(key.Value, "<dcm") {
+			continue
 		}
-	}
-
-	// Add blank lines between main sections
-	for i := 2; i < len(rootMap.Content); i += 2 {
-		if rootMap.Content[i].HeadComment == "" {
-			rootMap.Content[i].HeadComment = "\n"
+		if key.Value == "services" {
+			value = newServicesNode
 		}
+		content = append(content, key, value)
 	}
+	rootMap.Content = content
 
 	return nil
 }
 
-// writeOutput writes the final docker-compose.yml file preserving comments
-func (b *Builder) writeOutput(node *yaml.Node) error {
-	var buf strings.Builder
-	encoder := yaml.NewEncoder(&buf)
-	encoder.SetIndent(2)
-
-	if err := encoder.Encode(node); err != nil {
-		return fmt.Errorf("failed to encode YAML: %w", err)
+// renderOutput converts the merged content to the selected format and adds the provenance header
+func (b *Builder) renderOutput(merged []byte) ([]byte, error) {
+	content, err := format.Convert(merged, b.format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert output to %v: %w", b.format, err)
 	}
 
-	output := buf.String()
+	return b.stamp(content), nil
+}
 
-	// Remove lines containing placeholder
-	lines := strings.Split(output, "\n")
-	var filteredLines []string
-	for _, line := range lines {
-		if !strings.Contains(line, "<dcm: include services") {
-			filteredLines = append(filteredLines, line)
-		}
+// writeOutput atomically writes the final docker-compose.yml file, backing up the existing one if requested
+func (b *Builder) writeOutput(content []byte, backup bool) error {
+	tx, err := path.NewTransaction(b.fs, filepath.Dir(b.outputPath))
+	if err != nil {
+		return err
 	}
-	output = strings.Join(filteredLines, "\n")
+	defer tx.Rollback()
 
-	// Make sure the file ends with a single blank line
-	if !strings.HasSuffix(output, "\n") {
-		output += "\n"
+	if backup {
+		tx.Backup(b.outputPath)
 	}
-
-	err := os.WriteFile(b.outputPath, []byte(output), 0644)
-	if err != nil {
+	if err := tx.WriteFile(b.outputPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to write output file: %w", err)
 	}
 
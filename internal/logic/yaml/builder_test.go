@@ -2,18 +2,22 @@
 package yaml
 
 import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/input"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
-	"os"
+	"io"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
 )
 
 func TestBuilder_Build(t *testing.T) {
 	// Create a temporary directory for the test
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	// Create test files structure with real-world content and comments
 	testFiles := map[string]string{
@@ -85,13 +89,13 @@ networks:
 	}
 
 	// Create necessary directories and files
-	err := os.Mkdir(filepath.Join(tempDir, logic.ServicesDirectoryConst), 0755)
+	err := fs.Mkdir(filepath.Join(tempDir, logic.ServicesDirectoryConst), 0755)
 	if err != nil {
 		t.Fatalf("Failed to create services directory: %v", err)
 	}
 
 	for filename, content := range testFiles {
-		err := os.WriteFile(filepath.Join(tempDir, filename), []byte(content), 0644)
+		err := afero.WriteFile(fs, filepath.Join(tempDir, filename), []byte(content), 0644)
 		if err != nil {
 			t.Fatalf("Failed to create test file %s: %v", filename, err)
 		}
@@ -105,12 +109,13 @@ networks:
 		filepath.Join(tempDir, logic.ComposeFileNameConst),
 		true,
 	)
+	builder.SetFs(fs)
 
 	err = builder.Build()
 	assert.NoError(t, err)
 
 	// Read the generated docker-compose.yml
-	content, err := os.ReadFile(filepath.Join(tempDir, logic.ComposeFileNameConst))
+	content, err := afero.ReadFile(fs, filepath.Join(tempDir, logic.ComposeFileNameConst))
 	if err != nil {
 		t.Fatalf("Failed to read output file: %v", err)
 	}
@@ -180,7 +185,8 @@ networks:
 }
 
 func TestBuilder_Build_NoTemplate(t *testing.T) {
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	builder := NewBuilder(
 		tempDir,
@@ -189,6 +195,7 @@ func TestBuilder_Build_NoTemplate(t *testing.T) {
 		filepath.Join(tempDir, logic.ComposeFileNameConst),
 		true,
 	)
+	builder.SetFs(fs)
 
 	err := builder.Build()
 	assert.Error(t, err)
@@ -196,10 +203,11 @@ func TestBuilder_Build_NoTemplate(t *testing.T) {
 }
 
 func TestBuilder_Build_NoServicesDir(t *testing.T) {
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	// Create template file
-	err := os.WriteFile(
+	err := afero.WriteFile(fs,
 		filepath.Join(tempDir, logic.TemplateFileNameDefaultConst),
 		[]byte("services:\n  <dcm: include services>"),
 		0644,
@@ -215,6 +223,7 @@ func TestBuilder_Build_NoServicesDir(t *testing.T) {
 		filepath.Join(tempDir, logic.ComposeFileNameConst),
 		true,
 	)
+	builder.SetFs(fs)
 
 	err = builder.Build()
 	assert.Error(t, err)
@@ -222,13 +231,15 @@ func TestBuilder_Build_NoServicesDir(t *testing.T) {
 }
 
 func TestBuilder_Build_ExistingOutput(t *testing.T) {
-	tempDir := t.TempDir()
+	fs := afero.NewMemMapFs()
+	tempDir := "/project"
 
 	// Setup test cases with different scenarios
 	tests := []struct {
 		name           string
 		forceOverwrite bool
 		mockUserInput  string
+		prompter       input.Prompter
 		expectedError  string
 	}{
 		{
@@ -249,6 +260,30 @@ func TestBuilder_Build_ExistingOutput(t *testing.T) {
 			mockUserInput:  "", // No input needed when force is true
 			expectedError:  "", // No error expected with force flag
 		},
+		{
+			name:           "yes_flag",
+			forceOverwrite: false,
+			prompter:       input.NewFixed(true, io.Discard), // Simulate --yes
+			expectedError:  "",
+		},
+		{
+			name:           "no_flag",
+			forceOverwrite: false,
+			prompter:       input.NewFixed(false, io.Discard), // Simulate --no
+			expectedError:  "operation canceled",
+		},
+		{
+			name:           "non_interactive",
+			forceOverwrite: false,
+			prompter:       input.NewNonInteractive(), // Simulate a CI job without a terminal
+			expectedError:  "running non-interactively",
+		},
+		{
+			name:           "non_interactive_force_overwrite",
+			forceOverwrite: true,
+			prompter:       input.NewNonInteractive(), // Never asked when force is true
+			expectedError:  "",
+		},
 	}
 
 	// Create template and service files needed for the build process
@@ -265,38 +300,15 @@ volumes:
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create a pipe to simulate stdin for user input
-			oldStdin := os.Stdin
-			r, w, err := os.Pipe()
-			if err != nil {
-				t.Fatalf("Failed to create pipe: %v", err)
-			}
-			os.Stdin = r
-			defer func() {
-				os.Stdin = oldStdin
-			}()
-
-			// Write mock user input if provided
-			if tt.mockUserInput != "" {
-				_, err = w.WriteString(tt.mockUserInput)
-				if err != nil {
-					t.Fatalf("Failed to write mock input: %v", err)
-				}
-				err := w.Close()
-				if err != nil {
-					return
-				}
-			}
-
 			// Create directory structure and required files
 			servicesDir := filepath.Join(tempDir, logic.ServicesDirectoryConst)
-			err = os.MkdirAll(servicesDir, 0755)
+			err := fs.MkdirAll(servicesDir, 0755)
 			if err != nil {
 				t.Fatalf("Failed to create services directory: %v", err)
 			}
 
 			// Create template file
-			err = os.WriteFile(
+			err = afero.WriteFile(fs,
 				filepath.Join(tempDir, logic.TemplateFileNameDefaultConst),
 				[]byte(templateContent),
 				0644,
@@ -306,7 +318,7 @@ volumes:
 			}
 
 			// Create service file
-			err = os.WriteFile(
+			err = afero.WriteFile(fs,
 				filepath.Join(servicesDir, "app.yml"),
 				[]byte(serviceContent),
 				0644,
@@ -316,7 +328,7 @@ volumes:
 			}
 
 			// Create existing output file
-			err = os.WriteFile(
+			err = afero.WriteFile(fs,
 				filepath.Join(tempDir, logic.ComposeFileNameConst),
 				[]byte("existing content"),
 				0644,
@@ -332,6 +344,13 @@ volumes:
 				filepath.Join(tempDir, logic.ComposeFileNameConst),
 				tt.forceOverwrite,
 			)
+			builder.SetFs(fs)
+			// Answer the question with the mock user input unless the case selects a prompter
+			prompter := tt.prompter
+			if prompter == nil {
+				prompter = input.NewConsole(strings.NewReader(tt.mockUserInput), io.Discard)
+			}
+			builder.SetPrompter(prompter)
 
 			// Execute the build
 			err = builder.Build()
@@ -344,8 +363,8 @@ volumes:
 				assert.NoError(t, err)
 
 				// Verify the file was actually overwritten
-				if tt.forceOverwrite || tt.mockUserInput == "y\n" {
-					content, err := os.ReadFile(filepath.Join(tempDir, logic.ComposeFileNameConst))
+				if tt.forceOverwrite || tt.mockUserInput == "y\n" || tt.prompter != nil {
+					content, err := afero.ReadFile(fs, filepath.Join(tempDir, logic.ComposeFileNameConst))
 					assert.NoError(t, err, "Expected output file to exist")
 					assert.NotEqual(t, "existing content", string(content), "Expected file content to be overwritten")
 
@@ -2,16 +2,20 @@ package yaml
 
 import (
 	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/edit"
 	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/helper"
+	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
-	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
 // ServiceDecomposer handles the decomposition of docker-compose services into separate files
 type ServiceDecomposer struct {
+	fs           afero.Fs
 	fileSrc      string
 	fileTemplate string
 	servicesDir  string
@@ -20,192 +24,179 @@ type ServiceDecomposer struct {
 // NewServiceDecomposer creates a new instance of ServiceDecomposer
 func NewServiceDecomposer(fileSrc, fileTemplate, servicesDir string) *ServiceDecomposer {
 	return &ServiceDecomposer{
+		fs:           afero.NewOsFs(),
 		fileSrc:      fileSrc,
 		fileTemplate: fileTemplate,
 		servicesDir:  servicesDir,
 	}
 }
 
-// Decompose performs the main decomposition logic
+// SetFs selects the file system the files are read from and written to
+func (d *ServiceDecomposer) SetFs(fs afero.Fs) {
+	d.fs = fs
+}
+
+// Decompose performs the main decomposition logic, writing all files atomically
 func (d *ServiceDecomposer) Decompose() error {
-	// Create services directory if it doesn't exist
-	if err := os.MkdirAll(d.servicesDir, 0755); err != nil {
-		return fmt.Errorf("failed to create services directory: %w", err)
+	tx, err := path.NewTransaction(d.fs, filepath.Dir(d.fileTemplate))
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
+
+	if err := d.Stage(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
+// Stage performs the decomposition, staging the template and service files in the given
+// transaction. Nothing is written to the project until the transaction is committed.
+func (d *ServiceDecomposer) Stage(tx *path.Transaction) error {
 	// Read the source file
-	node, err := d.parseSourceFile()
+	content, err := afero.ReadFile(d.fs, d.fileSrc)
 	if err != nil {
-		return fmt.Errorf("failed to parse source file: %w", err)
+		return fmt.Errorf("failed to parse source file: failed to read source file: %w", err)
 	}
 
-	// Extract and write services
-	if err := d.extractServices(node); err != nil {
-		return fmt.Errorf("failed to extract services: %w", err)
+	decomposition, err := Split(content)
+	if err != nil {
+		return err
 	}
 
-	// Create template file
-	if err := d.createTemplateFile(node); err != nil {
-		return fmt.Errorf("failed to create template file: %w", err)
+	// Write the service files
+	for _, service := range decomposition.Services {
+		filename := filepath.Join(d.servicesDir, service.Name)
+		if err := tx.WriteFile(filename, service.Content, 0644); err != nil {
+			return fmt.Errorf("failed to extract services: failed to write service file %s: %w", filename, err)
+		}
+	}
+
+	// Write the template file
+	if err := tx.WriteFile(d.fileTemplate, decomposition.Template, 0644); err != nil {
+		return fmt.Errorf("failed to create template file: failed to write template file: %w", err)
 	}
 
 	return nil
 }
 
-// parseSourceFile reads and parses the source docker-compose file preserving comments
-func (d *ServiceDecomposer) parseSourceFile() (*yaml.Node, error) {
-	file, err := os.ReadFile(d.fileSrc)
+// Split splits the content of a compose file into the template and one service file per service,
+// keeping the original formatting of both
+func Split(content []byte) (*logic.Decomposition, error) {
+	// The provenance header of a generated file is not part of the template
+	doc, err := edit.Parse(provenance.Strip(content))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read source file: %w", err)
-	}
-
-	var node yaml.Node
-	if err := yaml.Unmarshal(file, &node); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+		return nil, fmt.Errorf("failed to parse source file: failed to unmarshal YAML: %w", err)
 	}
 
-	return &node, nil
-}
-
-// extractServices extracts individual services and writes them to separate files
-func (d *ServiceDecomposer) extractServices(node *yaml.Node) error {
-	servicesNode := helper.FindServicesNode(node)
+	servicesNode := helper.FindServicesNode(doc.Node())
 	if servicesNode == nil {
-		return fmt.Errorf("services section not found in source file")
+		return nil, fmt.Errorf("failed to extract services: services section not found in source file")
 	}
 
-	// Process each service
-	for i := 0; i < len(servicesNode.Content); i += 2 {
+	decomposition := &logic.Decomposition{}
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
 		serviceName := servicesNode.Content[i].Value
-		serviceNode := servicesNode.Content[i+1]
-
-		// Create service YAML document
-		serviceDoc := &yaml.Node{
-			Kind: yaml.DocumentNode,
-			Content: []*yaml.Node{
-				{
-					Kind:  yaml.MappingNode,
-					Style: yaml.LiteralStyle,
-					Content: []*yaml.Node{
-						{
-							Kind:        yaml.ScalarNode,
-							Value:       serviceName,
-							Style:       servicesNode.Content[i].Style,
-							HeadComment: servicesNode.Content[i].HeadComment,
-							LineComment: servicesNode.Content[i].LineComment,
-							FootComment: servicesNode.Content[i].FootComment,
-						},
-						serviceNode,
-					},
-				},
-			},
-		}
-
-		// Marshal service to YAML
-		var buf strings.Builder
-		encoder := yaml.NewEncoder(&buf)
-		encoder.SetIndent(2)
-		if err := encoder.Encode(serviceDoc); err != nil {
-			return fmt.Errorf("failed to marshal service %s: %w", serviceName, err)
+		service, err := renderService(doc, servicesNode.Content[i], servicesNode.Content[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract services: failed to marshal service %s: %w", serviceName, err)
 		}
+		decomposition.Services = append(decomposition.Services,
+			logic.ServiceFile{Name: fmt.Sprintf("%s.yml", serviceName), Content: service})
+	}
 
-		// Write service file
-		filename := filepath.Join(d.servicesDir, fmt.Sprintf("%s.yml", serviceName))
-		if err := os.WriteFile(filename, []byte(buf.String()), 0644); err != nil {
-			return fmt.Errorf("failed to write service file %s: %w", filename, err)
-		}
+	template, err := renderTemplate(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template file: %w", err)
 	}
+	decomposition.Template = template
 
-	return nil
+	return decomposition, nil
 }
 
-// createTemplateFile creates the template file with service inclusion directive
-func (d *ServiceDecomposer) createTemplateFile(node *yaml.Node) error {
-	rootMap := node.Content[0]
-
-	// Find services section
-	for i := 0; i < len(rootMap.Content); i += 2 {
-		// Handle services section
-		if rootMap.Content[i].Value == "services" {
-			headComment := rootMap.Content[i].HeadComment
-			lineComment := rootMap.Content[i].LineComment
-
-			servicesKeyNode := &yaml.Node{
-				Kind:        yaml.ScalarNode,
-				Value:       "services",
-				HeadComment: headComment,
-				LineComment: lineComment,
-			}
-
-			includeNode := &yaml.Node{
-				Kind:        yaml.ScalarNode,
-				Value:       "<dcm: include services\\>",
-				Style:       yaml.LiteralStyle,
-				HeadComment: "\n",
-				FootComment: "\n",
-			}
-
-			rootMap.Content[i] = servicesKeyNode
-			rootMap.Content[i+1] = includeNode
-		}
+// renderService returns a single service as a standalone service file. The original bytes of the
+// service are kept, moved to the start of the line; services without them are encoded preserving comments.
+func renderService(doc *edit.Document, keyNode, serviceNode *yaml.Node) ([]byte, error) {
+	if source, ok := doc.Source(keyNode); ok {
+		content := strings.Trim(string(edit.Reindent(source, keyNode.Column-1, 0)), "\n")
+		return []byte(content + "\n"), nil
+	}
 
-		// Add extra newline before each main section (volumes, networks)
-		if i > 0 && (rootMap.Content[i].Value == "volumes" || rootMap.Content[i].Value == "networks") {
-			currentComment := rootMap.Content[i].HeadComment
-			if !strings.HasPrefix(currentComment, "\n") {
-				rootMap.Content[i].HeadComment = "\n" + currentComment
-			}
-		}
+	// Create service YAML document
+	serviceDoc := &yaml.Node{
+		Kind: yaml.DocumentNode,
+		Content: []*yaml.Node{
+			{
+				Kind:  yaml.MappingNode,
+				Style: yaml.LiteralStyle,
+				Content: []*yaml.Node{
+					{
+						Kind:        yaml.ScalarNode,
+						Value:       keyNode.Value,
+						Style:       keyNode.Style,
+						HeadComment: keyNode.HeadComment,
+						LineComment: keyNode.LineComment,
+						FootComment: keyNode.FootComment,
+					},
+					serviceNode,
+				},
+			},
+		},
 	}
 
-	// Marshal to YAML
+	// Marshal service to YAML
 	var buf strings.Builder
 	encoder := yaml.NewEncoder(&buf)
 	encoder.SetIndent(2)
-	if err := encoder.Encode(node); err != nil {
-		return fmt.Errorf("failed to marshal template: %w", err)
+	if err := encoder.Encode(serviceDoc); err != nil {
+		return nil, err
 	}
 
-	// Post-process the content
-	content := buf.String()
-
-	// Fix the services section formatting
-	re := regexp.MustCompile(`(?m)^services:\s*(?:\|-?|[|>](?:[-+]|\d+[-+]?)?)?\s*(?:\n\s+)?<dcm:`)
-	content = re.ReplaceAllString(content, "services:\n<dcm:")
-
-	// Clean up duplicate newlines while preserving intended spacing
-	lines := strings.Split(content, "\n")
-	var processedLines []string
-	var previousEmpty, previousWasComment bool
+	return []byte(buf.String()), nil
+}
 
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		isEmpty := trimmedLine == ""
-		isComment := strings.HasPrefix(trimmedLine, "#")
+// renderTemplate replaces the services section of the document with the service inclusion directive
+// and returns the result as the template file content. Everything else keeps its original bytes.
+func renderTemplate(doc *edit.Document) ([]byte, error) {
+	rootMap := doc.Root()
+	if rootMap == nil || rootMap.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid source file structure")
+	}
 
-		// Skip if we have three consecutive empty lines
-		if isEmpty && previousEmpty && len(processedLines) > 0 && strings.TrimSpace(processedLines[len(processedLines)-1]) == "" {
+	for i := 0; i+1 < len(rootMap.Content); i += 2 {
+		keyNode, valueNode := rootMap.Content[i], rootMap.Content[i+1]
+		if keyNode.Value != "services" {
 			continue
 		}
 
-		// Ensure empty line before comments that start main sections (except the first one)
-		if isComment && !previousWasComment && len(processedLines) > 0 && !strings.HasPrefix(trimmedLine, "# Main") {
-			if !previousEmpty {
-				processedLines = append(processedLines, "")
+		// Keep the comments and the line of the services key unless the services are written on it
+		header, ok := doc.Header(keyNode)
+		if !ok || valueNode.Line == keyNode.Line {
+			header = []byte("services:")
+			if keyNode.LineComment != "" {
+				header = append(header, " "+keyNode.LineComment...)
 			}
+			header = append(header, '\n')
 		}
+		content := string(header) + "<dcm: include services\\>\n"
 
-		processedLines = append(processedLines, line)
-		previousEmpty = isEmpty
-		previousWasComment = isComment
+		// Separate the directive from the next section by a blank line
+		if i+2 < len(rootMap.Content) {
+			next, ok := doc.Source(rootMap.Content[i+2])
+			if !ok || !strings.HasPrefix(string(next), "\n") {
+				content += "\n"
+			}
+		}
+		doc.SetSource(keyNode, []byte(content))
 	}
 
-	content = strings.Join(processedLines, "\n") + "\n"
-
-	// Write the file
-	if err := os.WriteFile(d.fileTemplate, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write template file: %w", err)
+	content, err := doc.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template: %w", err)
+	}
+	if !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
 	}
 
-	return nil
+	return content, nil
 }
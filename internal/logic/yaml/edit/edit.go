@@ -0,0 +1,447 @@
+// Package edit edits YAML documents keeping the original source bytes of everything that was not changed
+package edit
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+// span is the location of a block mapping entry in the source lines.
+// Lines are counted from 0, the entry covers the lines from start up to but excluding end.
+type span struct {
+	start  int        // first line of the leading comments and blank lines
+	key    int        // line of the key
+	end    int        // first line after the entry
+	column int        // indentation of the key
+	value  *yaml.Node // value of the entry when the document was parsed
+}
+
+// shape is the state of a node when the document was parsed
+type shape struct {
+	kind        yaml.Kind
+	style       yaml.Style
+	tag         string
+	value       string
+	anchor      string
+	alias       *yaml.Node
+	headComment string
+	lineComment string
+	footComment string
+	content     []*yaml.Node
+}
+
+// Document is a parsed YAML document that can be modified through its nodes.
+// Encoding the document copies the source bytes of unmodified mapping entries
+// and re-encodes only the entries that changed.
+type Document struct {
+	content  []byte
+	node     *yaml.Node
+	lines    [][]byte
+	prefix   int
+	spans    map[*yaml.Node]span
+	original map[*yaml.Node]shape
+	sources  map[*yaml.Node][]byte
+}
+
+// Parse parses the content into a Document
+func Parse(content []byte) (*Document, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, err
+	}
+
+	d := &Document{
+		content:  content,
+		node:     &node,
+		lines:    splitLines(content),
+		spans:    make(map[*yaml.Node]span),
+		original: make(map[*yaml.Node]shape),
+		sources:  make(map[*yaml.Node][]byte),
+	}
+	d.record(&node)
+
+	if root := d.Root(); root != nil && isBlockMapping(root) && len(root.Content) > 0 {
+		d.prefix = d.directives(root.Content[0].Line - 1)
+		d.measure(root, d.prefix, len(d.lines))
+	}
+
+	return d, nil
+}
+
+// Node returns the document node. The tree can be modified freely before calling Encode.
+func (d *Document) Node() *yaml.Node {
+	return d.node
+}
+
+// Root returns the top level node of the document, nil for an empty document
+func (d *Document) Root() *yaml.Node {
+	if d.node.Kind != yaml.DocumentNode || len(d.node.Content) == 0 {
+		return nil
+	}
+	return d.node.Content[0]
+}
+
+// Source returns the original bytes of the block mapping entry with the given key,
+// including the comments and blank lines in front of it
+func (d *Document) Source(key *yaml.Node) ([]byte, bool) {
+	s, ok := d.spans[key]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Join(d.lines[s.start:s.end], nil), true
+}
+
+// Header returns the original bytes of the comments in front of the mapping entry with the given key
+// together with the line of the key itself
+func (d *Document) Header(key *yaml.Node) ([]byte, bool) {
+	s, ok := d.spans[key]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Join(d.lines[s.start:s.key+1], nil), true
+}
+
+// SetSource replaces the whole mapping entry with the given key by the content, which is written as is
+func (d *Document) SetSource(key *yaml.Node, content []byte) {
+	d.sources[key] = content
+}
+
+// Encode returns the content of the document
+func (d *Document) Encode() ([]byte, error) {
+	if len(d.sources) == 0 && d.unchanged(d.node) {
+		return d.content, nil
+	}
+
+	root := d.Root()
+	if root == nil || !isBlockMapping(root) {
+		return encode(d.node, 0)
+	}
+
+	var buf bytes.Buffer
+	if _, measured := d.original[root]; measured {
+		writeLines(&buf, d.lines[:d.prefix])
+	}
+	if err := d.writeEntries(&buf, root, d.childIndent(root, 0, 0)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// record remembers the state of the node and all nodes below it
+func (d *Document) record(node *yaml.Node) {
+	if _, ok := d.original[node]; ok {
+		return
+	}
+	d.original[node] = shapeOf(node)
+	for _, child := range node.Content {
+		d.record(child)
+	}
+}
+
+// directives returns the number of lines before the given line that belong to the document start,
+// the directives and the '---' marker
+func (d *Document) directives(firstKey int) int {
+	prefix := 0
+	for i := 0; i < firstKey && i < len(d.lines); i++ {
+		line := strings.TrimSpace(string(d.lines[i]))
+		if strings.HasPrefix(line, "%") || strings.HasPrefix(line, "---") {
+			prefix = i + 1
+		}
+	}
+	return prefix
+}
+
+// measure finds the spans of the entries of a block mapping occupying the lines from 'from' up to 'to'.
+// Comments and blank lines in front of a key belong to its entry when they are indented like the key.
+func (d *Document) measure(mapping *yaml.Node, from, to int) {
+	previous := from - 1
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key := mapping.Content[i]
+		line := key.Line - 1
+		if key.Kind != yaml.ScalarNode || line <= previous || line >= to {
+			// Complex keys or several entries on one line, the mapping is only handled as a whole
+			return
+		}
+		previous = line
+	}
+
+	count := len(mapping.Content) / 2
+	starts := make([]int, count)
+	for j := 0; j < count; j++ {
+		if j == 0 {
+			starts[j] = from
+			continue
+		}
+		key := mapping.Content[2*j]
+		lower := mapping.Content[2*(j-1)].Line
+		start := key.Line - 1
+		for start > lower && attaches(d.lines[start-1], key.Column-1) {
+			start--
+		}
+		starts[j] = start
+	}
+
+	for j := 0; j < count; j++ {
+		end := to
+		if j+1 < count {
+			end = starts[j+1]
+		}
+		key, value := mapping.Content[2*j], mapping.Content[2*j+1]
+		d.spans[key] = span{start: starts[j], key: key.Line - 1, end: end, column: key.Column - 1, value: value}
+
+		if isBlockMapping(value) && value.Line > key.Line {
+			d.measure(value, key.Line, end)
+		}
+	}
+}
+
+// writeEntries writes all entries of the mapping with the keys at the given indentation
+func (d *Document) writeEntries(buf *bytes.Buffer, mapping *yaml.Node, indent int) error {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if err := d.writeEntry(buf, mapping.Content[i], mapping.Content[i+1], indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEntry writes a single mapping entry, copying the source bytes of everything that was not changed
+func (d *Document) writeEntry(buf *bytes.Buffer, key, value *yaml.Node, indent int) error {
+	if source, ok := d.sources[key]; ok {
+		buf.Write(source)
+		if len(source) > 0 && source[len(source)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		return nil
+	}
+
+	s, measured := d.spans[key]
+	keyUnchanged := measured && d.unchanged(key)
+	if keyUnchanged && s.value == value && d.unchanged(value) {
+		writeLines(buf, reindent(d.lines[s.start:s.end], indent-s.column))
+		return nil
+	}
+
+	// Keep the leading comments of an entry whose key was not changed
+	if keyUnchanged {
+		writeLines(buf, reindent(d.lines[s.start:s.key], indent-s.column))
+	}
+
+	// Only the changed entries of a block mapping are encoded again
+	if isBlockMapping(value) && len(value.Content) > 0 {
+		if keyUnchanged && d.keyLineReusable(s) {
+			writeLines(buf, reindent(d.lines[s.key:s.key+1], indent-s.column))
+		} else {
+			header, err := encodeHeader(key, value, indent, !keyUnchanged)
+			if err != nil {
+				return err
+			}
+			buf.Write(header)
+		}
+		childIndent := indent + 2
+		if measured && s.value == value {
+			childIndent = d.childIndent(value, indent-s.column, childIndent)
+		}
+		return d.writeEntries(buf, value, childIndent)
+	}
+
+	k := *key
+	if measured {
+		// The comments after the entry are part of the leading comments of the next entry
+		k.FootComment = ""
+	}
+	if keyUnchanged {
+		k.HeadComment = ""
+	}
+	content, err := encode(&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{&k, value}}, indent)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key.Value, err)
+	}
+	buf.Write(content)
+	return nil
+}
+
+// keyLineReusable reports whether the source line of the key holds nothing but the key and comments,
+// so it can be kept when the value below it changes
+func (d *Document) keyLineReusable(s span) bool {
+	if s.value.Line > s.key+1 {
+		return true
+	}
+	original := d.original[s.value]
+	return original.kind == yaml.ScalarNode && original.tag == "!!null" && original.value == ""
+}
+
+// childIndent returns the original indentation of the keys of the mapping shifted by delta spaces,
+// or the fallback when none of the keys comes from the source
+func (d *Document) childIndent(mapping *yaml.Node, delta, fallback int) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if s, ok := d.spans[mapping.Content[i]]; ok {
+			return s.column + delta
+		}
+	}
+	return fallback
+}
+
+// unchanged reports whether the node and all nodes below it are in the state they had when parsed
+func (d *Document) unchanged(node *yaml.Node) bool {
+	original, ok := d.original[node]
+	if !ok || !original.equal(shapeOf(node)) {
+		return false
+	}
+	for _, child := range node.Content {
+		if !d.unchanged(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reindent shifts every line of the content indented by 'from' spaces to be indented by 'to' spaces.
+// Lines indented less than 'from', like comments at the start of the line, lose only the spaces they have.
+func Reindent(content []byte, from, to int) []byte {
+	var buf bytes.Buffer
+	writeLines(&buf, reindent(splitLines(content), to-from))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+	return buf.Bytes()
+}
+
+// reindent returns the lines shifted by delta spaces
+func reindent(lines [][]byte, delta int) [][]byte {
+	if delta == 0 {
+		return lines
+	}
+	result := make([][]byte, len(lines))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			result[i] = bytes.TrimLeft(line, " \t")
+			continue
+		}
+		if delta > 0 {
+			result[i] = append([]byte(strings.Repeat(" ", delta)), line...)
+			continue
+		}
+		remove := 0
+		for remove < -delta && remove < len(line) && line[remove] == ' ' {
+			remove++
+		}
+		result[i] = line[remove:]
+	}
+	return result
+}
+
+// writeLines writes the lines, making sure the last one ends with a line break
+func writeLines(buf *bytes.Buffer, lines [][]byte) {
+	for _, line := range lines {
+		buf.Write(line)
+	}
+	if len(lines) > 0 && !bytes.HasSuffix(lines[len(lines)-1], []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+}
+
+// encodeHeader encodes the line of a key whose value is a block mapping
+func encodeHeader(key, value *yaml.Node, indent int, withHeadComment bool) ([]byte, error) {
+	k := *key
+	k.HeadComment, k.LineComment, k.FootComment = "", "", ""
+	out, err := yaml.Marshal(&k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", key.Value, err)
+	}
+
+	prefix := strings.Repeat(" ", indent)
+	var sb strings.Builder
+	if withHeadComment && key.HeadComment != "" {
+		for _, line := range strings.Split(key.HeadComment, "\n") {
+			sb.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+		}
+	}
+	sb.WriteString(prefix + strings.TrimSuffix(string(out), "\n") + ":")
+	if value.Anchor != "" {
+		sb.WriteString(" &" + value.Anchor)
+	}
+	if comment := key.LineComment; comment != "" {
+		sb.WriteString(" " + comment)
+	} else if comment := value.LineComment; comment != "" {
+		sb.WriteString(" " + comment)
+	}
+	sb.WriteString("\n")
+	return []byte(sb.String()), nil
+}
+
+// encode encodes the node with the repository's indentation of two spaces, shifted by the given indentation
+func encode(node *yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return Reindent(buf.Bytes(), 0, indent), nil
+}
+
+// attaches reports whether the line in front of a key at the given column belongs to its entry
+func attaches(line []byte, column int) bool {
+	trimmed := bytes.TrimLeft(line, " ")
+	if len(bytes.TrimSpace(trimmed)) == 0 {
+		return true
+	}
+	return trimmed[0] == '#' && len(line)-len(trimmed) == column
+}
+
+// isBlockMapping reports whether the node is a mapping written in block style
+func isBlockMapping(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode && node.Style&yaml.FlowStyle == 0
+}
+
+// splitLines splits the content into lines keeping the line breaks
+func splitLines(content []byte) [][]byte {
+	var lines [][]byte
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, content)
+			break
+		}
+		lines = append(lines, content[:i+1])
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// shapeOf returns the current state of the node, the children are compared by identity
+func shapeOf(node *yaml.Node) shape {
+	return shape{
+		kind:        node.Kind,
+		style:       node.Style,
+		tag:         node.Tag,
+		value:       node.Value,
+		anchor:      node.Anchor,
+		alias:       node.Alias,
+		headComment: node.HeadComment,
+		lineComment: node.LineComment,
+		footComment: node.FootComment,
+		content:     append([]*yaml.Node(nil), node.Content...),
+	}
+}
+
+// equal reports whether the node is unchanged, with the same fields and the same children in the same order
+func (s shape) equal(other shape) bool {
+	if s.kind != other.kind || s.style != other.style || s.tag != other.tag || s.value != other.value ||
+		s.anchor != other.anchor || s.alias != other.alias || s.headComment != other.headComment ||
+		s.lineComment != other.lineComment || s.footComment != other.footComment || len(s.content) != len(other.content) {
+		return false
+	}
+	for i := range s.content {
+		if s.content[i] != other.content[i] {
+			return false
+		}
+	}
+	return true
+}
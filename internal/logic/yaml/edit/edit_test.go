@@ -0,0 +1,141 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+const source = `# Compose file
+version: '3'
+services:
+  app:
+    image: "app:1.0"   # Quoted on purpose
+    ports: [ "80:80", "443:443" ]
+    command: >
+      run --verbose
+
+  # Cache
+  redis:
+    image: redis:alpine
+
+volumes:
+    data: {}
+`
+
+// mappingValue returns the value of the key in the mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func encoded(t *testing.T, doc *Document) string {
+	content, err := doc.Encode()
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestDocument_Unchanged(t *testing.T) {
+	doc, err := Parse([]byte(source))
+	assert.NoError(t, err)
+	assert.Equal(t, source, encoded(t, doc))
+}
+
+func TestDocument_ChangedScalar(t *testing.T) {
+	doc, err := Parse([]byte(source))
+	assert.NoError(t, err)
+
+	services := mappingValue(doc.Root(), "services")
+	mappingValue(mappingValue(services, "redis"), "image").Value = "redis:7-alpine"
+
+	expected := `# Compose file
+version: '3'
+services:
+  app:
+    image: "app:1.0"   # Quoted on purpose
+    ports: [ "80:80", "443:443" ]
+    command: >
+      run --verbose
+
+  # Cache
+  redis:
+    image: redis:7-alpine
+
+volumes:
+    data: {}
+`
+	assert.Equal(t, expected, encoded(t, doc))
+}
+
+func TestDocument_AddedAndRemovedEntries(t *testing.T) {
+	doc, err := Parse([]byte(source))
+	assert.NoError(t, err)
+
+	services := mappingValue(doc.Root(), "services")
+	app := mappingValue(services, "app")
+	app.Content = append(app.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: "restart"},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: "always"},
+	)
+	// Remove redis
+	services.Content = services.Content[:2]
+
+	expected := `# Compose file
+version: '3'
+services:
+  app:
+    image: "app:1.0"   # Quoted on purpose
+    ports: [ "80:80", "443:443" ]
+    command: >
+      run --verbose
+    restart: always
+
+volumes:
+    data: {}
+`
+	assert.Equal(t, expected, encoded(t, doc))
+}
+
+func TestDocument_SetSource(t *testing.T) {
+	doc, err := Parse([]byte(source))
+	assert.NoError(t, err)
+
+	root := doc.Root()
+	header, ok := doc.Header(root.Content[2])
+	assert.True(t, ok)
+	assert.Equal(t, "services:\n", string(header))
+
+	doc.SetSource(root.Content[2], []byte("services:\n  web:\n    image: nginx"))
+	assert.Equal(t, "# Compose file\nversion: '3'\nservices:\n  web:\n    image: nginx\n\nvolumes:\n    data: {}\n",
+		encoded(t, doc))
+}
+
+func TestDocument_Source(t *testing.T) {
+	doc, err := Parse([]byte(source))
+	assert.NoError(t, err)
+
+	services := mappingValue(doc.Root(), "services")
+	redis, ok := doc.Source(services.Content[2])
+	assert.True(t, ok)
+	assert.Equal(t, "\n  # Cache\n  redis:\n    image: redis:alpine\n", string(redis))
+	assert.Equal(t, "\n# Cache\nredis:\n  image: redis:alpine\n", string(Reindent(redis, 2, 0)))
+}
+
+func TestDocument_FlowRoot(t *testing.T) {
+	doc, err := Parse([]byte("{a: 1, b: 2}\n"))
+	assert.NoError(t, err)
+
+	doc.Root().Content[1].Value = "3"
+	assert.Equal(t, "{a: 3, b: 2}\n", encoded(t, doc))
+}
+
+func TestReindent(t *testing.T) {
+	assert.Equal(t, "  a:\n    b: 1\n\n  # note\n", string(Reindent([]byte("a:\n  b: 1\n\n# note\n"), 0, 2)))
+	assert.Equal(t, "a:\n  b: |\n    text\n", string(Reindent([]byte("  a:\n    b: |\n      text\n"), 2, 0)))
+	assert.Equal(t, "a: 1", string(Reindent([]byte("    a: 1"), 4, 0)))
+}
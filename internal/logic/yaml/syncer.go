@@ -0,0 +1,505 @@
+package yaml
+
+import (
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/edit"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/yaml/helper"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SyncAction describes what the syncer does with a single source file
+type SyncAction string
+
+const (
+	// SyncUpdated means the source file is rewritten from the compose file
+	SyncUpdated SyncAction = "updated"
+	// SyncCreated means a new service file is created for a service added to the compose file
+	SyncCreated SyncAction = "created"
+	// SyncRemoved means the service file is removed because its services were removed from the compose file
+	SyncRemoved SyncAction = "removed"
+	// SyncConflict means both the compose file and the source changed, so nothing can be applied safely
+	SyncConflict SyncAction = "conflict"
+)
+
+// SyncChange is a change of a single source file detected by the syncer
+type SyncChange struct {
+	// Source is the path of the source file relative to the build directory
+	Source string
+	// Services lists the services defined in the source, empty for the template
+	Services []string
+	Action   SyncAction
+	// Reason explains a conflict
+	Reason string
+}
+
+// SyncReport is the result of comparing the compose file with its sources
+type SyncReport struct {
+	// HasHeader is true if the compose file has a provenance header
+	HasHeader bool
+	// HandEdited is true if the compose file was edited after it was generated
+	HandEdited bool
+	Changes    []SyncChange
+}
+
+// Conflicts returns the changes that could not be applied
+func (r *SyncReport) Conflicts() []SyncChange {
+	var conflicts []SyncChange
+	for _, change := range r.Changes {
+		if change.Action == SyncConflict {
+			conflicts = append(conflicts, change)
+		}
+	}
+	return conflicts
+}
+
+// Syncer propagates edits made directly in a generated docker-compose.yml back into
+// the service files and the template
+type Syncer struct {
+	fs           afero.Fs
+	buildDir     string
+	templatePath string
+	servicesDir  string
+	composePath  string
+	force        bool
+}
+
+// NewSyncer creates a new instance of Syncer. With force, the compose file wins every conflict.
+func NewSyncer(buildDir, templatePath, servicesDir, composePath string, force bool) *Syncer {
+	return &Syncer{
+		fs:           afero.NewOsFs(),
+		buildDir:     buildDir,
+		templatePath: templatePath,
+		servicesDir:  servicesDir,
+		composePath:  composePath,
+		force:        force,
+	}
+}
+
+// SetFs selects the file system the files are read from and written to
+func (s *Syncer) SetFs(fs afero.Fs) {
+	s.fs = fs
+}
+
+// serviceFile is a parsed service file of the project
+type serviceFile struct {
+	path    string
+	source  string
+	content []byte
+	indent  int
+	names   []string
+	values  map[string]*yaml.Node
+	// lines are the lines of the service keys in the order of names
+	lines []int
+}
+
+// Stage compares the compose file with its sources and stages the changes per source file in the
+// given transaction. If any conflict is found and force is not set, nothing is staged.
+func (s *Syncer) Stage(tx *path.Transaction) (*SyncReport, error) {
+	content, err := afero.ReadFile(s.fs, s.composePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	header, body, err := provenance.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SyncReport{HasHeader: header != nil, HandEdited: true}
+	recorded := make(map[string]string)
+	if header != nil {
+		report.HandEdited = provenance.Hash(body) != header.OutputHash
+		for _, source := range header.Sources {
+			recorded[source.Path] = source.Hash
+		}
+	}
+	if !report.HandEdited {
+		return report, nil
+	}
+
+	// sourceChanged reports whether the source changed since the compose file was generated.
+	// Without a provenance header this cannot be known, so every source counts as changed.
+	sourceChanged := func(source string, content []byte) bool {
+		hash, ok := recorded[source]
+		return !ok || hash != provenance.Hash(content)
+	}
+
+	// The hashes of the generated services and sections tell which of them were edited in the compose
+	// file. Without them, i.e. without a header or with one written by an older dcm, every service and
+	// section differing from its source counts as edited.
+	var built map[string]string
+	var builtSections string
+	if header != nil {
+		built, builtSections = header.Services, header.Sections
+	}
+	current, _, err := provenance.Parts(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	composeEdited := func(name string) bool {
+		hash, ok := built[name]
+		if !ok {
+			_, present := current[name]
+			return built == nil || present
+		}
+		return current[name] != hash
+	}
+
+	composeDoc, err := edit.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	composeServices := helper.FindServicesNode(composeDoc.Node())
+	if composeServices == nil || composeServices.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("services section not found in compose file")
+	}
+
+	files, err := s.readServiceFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	type stagedWrite struct {
+		target  string
+		content []byte
+	}
+	var writes []stagedWrite
+	var removals []string
+	covered := make(map[string]bool)
+
+	// Compare every service file with the services of the compose file
+	for _, file := range files {
+		var present []string
+		equal := true
+		for _, name := range file.names {
+			covered[name] = true
+			composeValue := mappingValue(composeServices, name)
+			if composeValue == nil {
+				equal = false
+				continue
+			}
+			present = append(present, name)
+			same, err := sameValue(composeValue, file.values[name])
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				equal = false
+			}
+		}
+		if equal {
+			continue
+		}
+
+		_, wasRecorded := recorded[file.source]
+		if header != nil && !wasRecorded && len(present) == 0 {
+			// Added after the compose file was generated, nothing to propagate
+			continue
+		}
+
+		// Only the services edited in the compose file are taken from it, the others differ because
+		// their source was edited after the build and keep the source. An edited service conflicts
+		// when its source was edited as well.
+		var edited, conflicting []string
+		for _, name := range file.names {
+			if !composeEdited(name) {
+				continue
+			}
+			edited = append(edited, name)
+			changed := sourceChanged(file.source, file.content)
+			if hash, ok := built[name]; ok {
+				sourceHash, err := provenance.HashNode(file.values[name])
+				if err != nil {
+					return nil, err
+				}
+				changed = sourceHash != hash
+			}
+			if changed {
+				conflicting = append(conflicting, name)
+			}
+		}
+		if len(edited) == 0 {
+			continue
+		}
+
+		change := SyncChange{Source: file.source, Services: file.names}
+		if len(conflicting) > 0 && !s.force {
+			change.Action = SyncConflict
+			change.Reason = "changed both in the compose file and in the service file"
+			report.Changes = append(report.Changes, change)
+			continue
+		}
+
+		rendered, err := s.renderFile(file, composeDoc, composeServices, edited)
+		if err != nil {
+			return nil, err
+		}
+		if len(rendered) == 0 {
+			change.Action = SyncRemoved
+			removals = append(removals, file.path)
+		} else {
+			change.Action = SyncUpdated
+			writes = append(writes, stagedWrite{target: file.path, content: rendered})
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	// Create service files for services added to the compose file
+	indent := 2
+	if len(files) > 0 {
+		indent = files[0].indent
+	}
+	for i := 0; i < len(composeServices.Content); i += 2 {
+		name := composeServices.Content[i].Value
+		if covered[name] {
+			continue
+		}
+
+		filePath := filepath.Join(s.servicesDir, name+".yml")
+		source := provenance.NewSource(s.buildDir, filePath, nil).Path
+		change := SyncChange{Source: source, Services: []string{name}}
+		_, wasRecorded := recorded[source]
+		if (header == nil || wasRecorded) && !s.force {
+			change.Action = SyncConflict
+			change.Reason = "added in the compose file but the service file was removed"
+			if header == nil {
+				change.Reason = "added in the compose file, no provenance header to tell which side changed"
+			}
+		} else {
+			rendered, err := renderServices(composeDoc, composeServices, []string{name}, indent)
+			if err != nil {
+				return nil, err
+			}
+			change.Action = SyncCreated
+			writes = append(writes, stagedWrite{target: filePath, content: rendered})
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	// Compare the remaining sections with the template
+	templateChange, templateContent, err := s.compareTemplate(body, builtSections, sourceChanged)
+	if err != nil {
+		return nil, err
+	}
+	if templateChange != nil {
+		report.Changes = append(report.Changes, *templateChange)
+		if templateChange.Action == SyncUpdated {
+			writes = append(writes, stagedWrite{target: s.templatePath, content: templateContent})
+		}
+	}
+
+	if len(report.Conflicts()) > 0 {
+		return report, nil
+	}
+	for _, write := range writes {
+		if err := tx.WriteFile(write.target, write.content, 0644); err != nil {
+			return nil, err
+		}
+	}
+	for _, removal := range removals {
+		tx.Remove(removal)
+	}
+
+	return report, nil
+}
+
+// compareTemplate compares the sections of the compose file other than services with the template.
+// With the hash of the generated sections, the template is only changed if they were edited in the
+// compose file, and the change only conflicts if the sections of the template were edited as well.
+func (s *Syncer) compareTemplate(body []byte, builtSections string, sourceChanged func(string, []byte) bool) (*SyncChange, []byte, error) {
+	templateContent, err := afero.ReadFile(s.fs, s.templatePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	_, composeSections, err := provenance.Parts(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	_, templateSections, err := provenance.Parts(templateContent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse template file: %w", err)
+	}
+	if composeSections == templateSections || composeSections == builtSections {
+		return nil, nil, nil
+	}
+
+	source := provenance.NewSource(s.buildDir, s.templatePath, templateContent).Path
+	change := &SyncChange{Source: source, Action: SyncUpdated}
+	changed := sourceChanged(source, templateContent)
+	if builtSections != "" {
+		changed = templateSections != builtSections
+	}
+	if changed && !s.force {
+		change.Action = SyncConflict
+		change.Reason = "changed both in the compose file and in the template"
+		return change, nil, nil
+	}
+
+	doc, err := edit.Parse(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	rendered, err := renderTemplate(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return change, rendered, nil
+}
+
+// readServiceFiles reads and parses all service files of the project
+func (s *Syncer) readServiceFiles() ([]*serviceFile, error) {
+	names, err := logic.ReadServiceFiles(s.fs, s.servicesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+
+	var files []*serviceFile
+	for _, name := range names {
+		filePath := filepath.Join(s.servicesDir, name)
+		content, err := afero.ReadFile(s.fs, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service file %s: %w", name, err)
+		}
+
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil {
+			return nil, fmt.Errorf("failed to parse service YAML %s: %w", name, err)
+		}
+
+		file := &serviceFile{
+			path:    filePath,
+			source:  provenance.NewSource(s.buildDir, filePath, content).Path,
+			content: content,
+			values:  make(map[string]*yaml.Node),
+		}
+		if len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode {
+			mapping := node.Content[0]
+			for i := 0; i < len(mapping.Content); i += 2 {
+				file.names = append(file.names, mapping.Content[i].Value)
+				file.values[mapping.Content[i].Value] = mapping.Content[i+1]
+				file.lines = append(file.lines, mapping.Content[i].Line)
+			}
+			if len(mapping.Content) > 0 {
+				file.indent = mapping.Content[0].Column - 1
+			}
+		}
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// renderFile renders the service file with the edited services taken from the compose file, dropping
+// the ones removed from it, and every other service kept as it is in the source
+func (s *Syncer) renderFile(file *serviceFile, doc *edit.Document, services *yaml.Node, edited []string) ([]byte, error) {
+	isEdited := make(map[string]bool, len(edited))
+	for _, name := range edited {
+		isEdited[name] = true
+	}
+
+	var sb strings.Builder
+	for _, name := range file.names {
+		if !isEdited[name] {
+			segment := file.segment(name)
+			if segment != "" && !strings.HasSuffix(segment, "\n") {
+				segment += "\n"
+			}
+			sb.WriteString(segment)
+			continue
+		}
+		if mappingValue(services, name) == nil {
+			continue
+		}
+		rendered, err := renderServices(doc, services, []string{name}, file.indent)
+		if err != nil {
+			return nil, err
+		}
+		sb.Write(rendered)
+	}
+	return []byte(sb.String()), nil
+}
+
+// segment returns the lines of the source defining the named service, from its key up to the key of
+// the next service. The first service also gets the lines above its key, e.g. a file comment.
+func (f *serviceFile) segment(name string) string {
+	lines := strings.SplitAfter(string(f.content), "\n")
+	for i, candidate := range f.names {
+		if candidate != name {
+			continue
+		}
+		start, end := f.lines[i]-1, len(lines)
+		if i == 0 {
+			start = 0
+		}
+		if i+1 < len(f.lines) {
+			end = f.lines[i+1] - 1
+		}
+		return strings.Join(lines[start:end], "")
+	}
+	return ""
+}
+
+// renderServices renders the named services of the compose file as a service file with the given indentation
+func renderServices(doc *edit.Document, services *yaml.Node, names []string, indent int) ([]byte, error) {
+	var sb strings.Builder
+	for _, name := range names {
+		for i := 0; i < len(services.Content); i += 2 {
+			if services.Content[i].Value != name {
+				continue
+			}
+			content, err := renderService(doc, services.Content[i], services.Content[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal service %s: %w", name, err)
+			}
+			sb.WriteString(indentLines(string(content), indent))
+		}
+	}
+	return []byte(sb.String()), nil
+}
+
+// indentLines prefixes every non-empty line of the content with the given number of spaces
+func indentLines(content string, indent int) string {
+	if indent <= 0 {
+		return content
+	}
+	prefix := strings.Repeat(" ", indent)
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sameValue reports whether two nodes hold the same data, ignoring comments and formatting
+func sameValue(a, b *yaml.Node) (bool, error) {
+	var valueA, valueB interface{}
+	if err := a.Decode(&valueA); err != nil {
+		return false, err
+	}
+	if err := b.Decode(&valueB); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(valueA, valueB), nil
+}
+
+// mappingValue returns the value stored under the given key of a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
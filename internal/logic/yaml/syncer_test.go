@@ -0,0 +1,203 @@
+package yaml
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncProjectDir is the directory of the in-memory project used by the syncer tests
+const syncProjectDir = "/project"
+
+// setupSyncProject creates an in-memory project with two services and builds its compose file
+func setupSyncProject(t *testing.T) (afero.Fs, *Syncer) {
+	fs := afero.NewMemMapFs()
+	dir := syncProjectDir
+	servicesDir := filepath.Join(dir, logic.ServicesDirectoryConst)
+	assert.NoError(t, fs.MkdirAll(servicesDir, 0755))
+
+	files := map[string]string{
+		filepath.Join(dir, logic.TemplateFileNameDefaultConst): `services:
+<dcm: include services\>
+
+networks:
+  innernet:
+    driver: bridge # Network driver
+`,
+		filepath.Join(servicesDir, "app.yml"): `  app:
+    image: app:1.0 # Application image
+    depends_on:
+      - redis
+`,
+		filepath.Join(servicesDir, "redis.yml"): `  redis:
+    image: redis:alpine # Cache image
+`,
+	}
+	for filePath, content := range files {
+		assert.NoError(t, afero.WriteFile(fs, filePath, []byte(content), 0644))
+	}
+
+	builder := NewBuilder(
+		dir,
+		filepath.Join(dir, logic.TemplateFileNameDefaultConst),
+		servicesDir,
+		filepath.Join(dir, logic.ComposeFileNameConst),
+		true,
+	)
+	builder.SetFs(fs)
+	assert.NoError(t, builder.Build())
+
+	syncer := NewSyncer(
+		dir,
+		filepath.Join(dir, logic.TemplateFileNameDefaultConst),
+		servicesDir,
+		filepath.Join(dir, logic.ComposeFileNameConst),
+		false,
+	)
+	syncer.SetFs(fs)
+	return fs, syncer
+}
+
+// editCompose replaces text in the generated compose file
+func editCompose(t *testing.T, fs afero.Fs, old, new string) {
+	composePath := filepath.Join(syncProjectDir, logic.ComposeFileNameConst)
+	content, err := afero.ReadFile(fs, composePath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), old)
+	assert.NoError(t, afero.WriteFile(fs, composePath, []byte(strings.Replace(string(content), old, new, 1)), 0644))
+}
+
+// runSync stages and commits the sync, returning the report
+func runSync(t *testing.T, fs afero.Fs, syncer *Syncer) *SyncReport {
+	tx, err := path.NewTransaction(fs, syncProjectDir)
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	report, err := syncer.Stage(tx)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+	return report
+}
+
+func readProjectFile(t *testing.T, fs afero.Fs, name string) string {
+	content, err := afero.ReadFile(fs, filepath.Join(syncProjectDir, name))
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestSyncer_NotEdited(t *testing.T) {
+	fs, syncer := setupSyncProject(t)
+
+	report := runSync(t, fs, syncer)
+	assert.True(t, report.HasHeader)
+	assert.False(t, report.HandEdited)
+	assert.Empty(t, report.Changes)
+}
+
+func TestSyncer_UpdatesServiceAndTemplate(t *testing.T) {
+	fs, syncer := setupSyncProject(t)
+	editCompose(t, fs, "image: redis:alpine", "image: redis:7-alpine")
+	editCompose(t, fs, "driver: bridge", "driver: overlay")
+
+	report := runSync(t, fs, syncer)
+	assert.Empty(t, report.Conflicts())
+	assert.Len(t, report.Changes, 2)
+
+	redis := readProjectFile(t, fs, filepath.Join(logic.ServicesDirectoryConst, "redis.yml"))
+	assert.Equal(t, "  redis:\n    image: redis:7-alpine # Cache image\n", redis)
+
+	// Untouched services are left alone
+	app := readProjectFile(t, fs, filepath.Join(logic.ServicesDirectoryConst, "app.yml"))
+	assert.Contains(t, app, "image: app:1.0 # Application image")
+
+	template := readProjectFile(t, fs, logic.TemplateFileNameDefaultConst)
+	assert.Contains(t, template, "driver: overlay # Network driver")
+	assert.Contains(t, template, "<dcm: include services\\>")
+}
+
+func TestSyncer_AddsAndRemovesServices(t *testing.T) {
+	fs, syncer := setupSyncProject(t)
+	editCompose(t, fs, "  redis:\n    image: redis:alpine # Cache image\n", "  db:\n    image: postgres:16\n")
+
+	report := runSync(t, fs, syncer)
+	assert.Empty(t, report.Conflicts())
+
+	_, err := fs.Stat(filepath.Join(syncProjectDir, logic.ServicesDirectoryConst, "redis.yml"))
+	assert.True(t, os.IsNotExist(err), "Removed service file must be deleted")
+
+	db := readProjectFile(t, fs, filepath.Join(logic.ServicesDirectoryConst, "db.yml"))
+	assert.Equal(t, "  db:\n    image: postgres:16\n", db)
+}
+
+func TestSyncer_Conflict(t *testing.T) {
+	fs, syncer := setupSyncProject(t)
+	editCompose(t, fs, "image: redis:alpine", "image: redis:7-alpine")
+
+	redisPath := filepath.Join(syncProjectDir, logic.ServicesDirectoryConst, "redis.yml")
+	sourceEdit := "  redis:\n    image: redis:6-alpine\n"
+	assert.NoError(t, afero.WriteFile(fs, redisPath, []byte(sourceEdit), 0644))
+
+	report := runSync(t, fs, syncer)
+	conflicts := report.Conflicts()
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, "services/redis.yml", conflicts[0].Source)
+	assert.Equal(t, sourceEdit, readProjectFile(t, fs, filepath.Join(logic.ServicesDirectoryConst, "redis.yml")))
+
+	// With force the compose file wins
+	syncer.force = true
+	report = runSync(t, fs, syncer)
+	assert.Empty(t, report.Conflicts())
+	assert.Contains(t, readProjectFile(t, fs, filepath.Join(logic.ServicesDirectoryConst, "redis.yml")), "redis:7-alpine")
+}
+
+func TestSyncer_SourceEditedAfterBuild(t *testing.T) {
+	fs, syncer := setupSyncProject(t)
+	editCompose(t, fs, "image: redis:alpine", "image: redis:7-alpine")
+
+	// The app service and the template were edited in their sources after the build, not in the compose file
+	appPath := filepath.Join(syncProjectDir, logic.ServicesDirectoryConst, "app.yml")
+	appEdit := "  app:\n    image: app:2.0 # Newer than the compose file\n"
+	assert.NoError(t, afero.WriteFile(fs, appPath, []byte(appEdit), 0644))
+	templatePath := filepath.Join(syncProjectDir, logic.TemplateFileNameDefaultConst)
+	template := readProjectFile(t, fs, logic.TemplateFileNameDefaultConst) + "volumes:\n  data: {}\n"
+	assert.NoError(t, afero.WriteFile(fs, templatePath, []byte(template), 0644))
+
+	for _, force := range []bool{false, true} {
+		syncer.force = force
+		report := runSync(t, fs, syncer)
+		assert.Empty(t, report.Conflicts())
+		assert.Equal(t, appEdit, readProjectFile(t, fs, filepath.Join(logic.ServicesDirectoryConst, "app.yml")))
+		assert.Equal(t, template, readProjectFile(t, fs, logic.TemplateFileNameDefaultConst))
+		assert.Contains(t, readProjectFile(t, fs, filepath.Join(logic.ServicesDirectoryConst, "redis.yml")), "redis:7-alpine")
+	}
+}
+
+func TestSyncer_SharedServiceFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	servicesDir := filepath.Join(syncProjectDir, logic.ServicesDirectoryConst)
+	stackPath := filepath.Join(servicesDir, "stack.yml")
+	assert.NoError(t, afero.WriteFile(fs, filepath.Join(syncProjectDir, logic.TemplateFileNameDefaultConst), []byte("services:\n<dcm: include services\\>\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, stackPath, []byte("  app:\n    image: app:1.0\n  worker:\n    image: worker:1.0\n"), 0644))
+	builder := NewBuilder(syncProjectDir, filepath.Join(syncProjectDir, logic.TemplateFileNameDefaultConst), servicesDir,
+		filepath.Join(syncProjectDir, logic.ComposeFileNameConst), true)
+	builder.SetFs(fs)
+	assert.NoError(t, builder.Build())
+
+	// app is edited in the compose file, worker in the shared service file
+	editCompose(t, fs, "image: app:1.0", "image: app:1.1")
+	assert.NoError(t, afero.WriteFile(fs, stackPath, []byte("  app:\n    image: app:1.0\n  worker:\n    image: worker:2.0 # Local edit\n"), 0644))
+
+	syncer := NewSyncer(syncProjectDir, filepath.Join(syncProjectDir, logic.TemplateFileNameDefaultConst), servicesDir,
+		filepath.Join(syncProjectDir, logic.ComposeFileNameConst), false)
+	syncer.SetFs(fs)
+	report := runSync(t, fs, syncer)
+	assert.Empty(t, report.Conflicts())
+	assert.Equal(t, "  app:\n    image: app:1.1\n  worker:\n    image: worker:2.0 # Local edit\n",
+		readProjectFile(t, fs, filepath.Join(logic.ServicesDirectoryConst, "stack.yml")))
+}
@@ -0,0 +1,86 @@
+package dcm
+
+import (
+	"context"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/format"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/provenance"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Build combines the template and the service files of the project into the compose file.
+// Every file with the .yml extension in the services directory is a service file.
+func Build(ctx context.Context, opts Options) (Result, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return Result{}, err
+	}
+	outputFormat, err := format.Parse(opts.Format)
+	if err != nil {
+		return Result{}, err
+	}
+
+	template, err := fs.ReadFile(opts.FS, opts.Template)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read template: %w", err)
+	}
+	result := Result{Sources: []string{opts.Template}}
+	sources := []provenance.Source{{Path: opts.Template, Hash: provenance.Hash(template)}}
+
+	entries, err := fs.ReadDir(opts.FS, opts.ServicesDir)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read services: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	var services []logic.ServiceFile
+	for _, file := range logic.ServiceFiles(names) {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		name := path.Join(opts.ServicesDir, file)
+		content, err := fs.ReadFile(opts.FS, name)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to read services: %w", err)
+		}
+		services = append(services, logic.ServiceFile{Name: file, Content: content})
+		sources = append(sources, provenance.Source{Path: name, Hash: provenance.Hash(content)})
+		result.Sources = append(result.Sources, name)
+		result.Services = append(result.Services, strings.TrimSuffix(file, ".yml"))
+	}
+
+	selected, reasons, err := engine.Resolve(opts.Engine, func() (engine.Detection, error) {
+		return engine.DetectBuildContent(path.Base(opts.Template), template, path.Base(opts.ServicesDir), services)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	result.Engine, result.EngineReasons = selected.Name, reasons
+
+	merged, err := selected.Assemble(template, services)
+	if err != nil {
+		return Result{}, err
+	}
+	content, err := format.Convert(merged, outputFormat)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to convert output to %v: %w", outputFormat, err)
+	}
+	if !opts.NoProvenance && outputFormat != format.JSON {
+		content = provenance.Stamp(logic.VersionConst, sources, content)
+	}
+
+	result.Files = []File{{Name: opts.Compose, Content: content}}
+	if err := write(ctx, opts.Output, result.Files); err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,136 @@
+// Package dcm builds docker-compose.yml files from a template and separate service files,
+// and decomposes existing compose files into them.
+//
+// It is the public API for embedding dcm in other tools. The functions read the project from an fs.FS,
+// hand the produced files to a Writer and return structured results; they never print, prompt or exit.
+package dcm
+
+import (
+	"context"
+	"errors"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"io/fs"
+)
+
+const (
+	// DefaultTemplate is the default name of the template file
+	DefaultTemplate = logic.TemplateFileNameDefaultConst
+	// DefaultCompose is the default name of the compose file
+	DefaultCompose = logic.ComposeFileNameConst
+	// DefaultServicesDir is the default name of the directory containing the service files
+	DefaultServicesDir = logic.ServicesDirectoryConst
+)
+
+const (
+	// EngineText processes the files line by line
+	EngineText = engine.Text
+	// EngineYAML processes the files with a YAML parser
+	EngineYAML = engine.YAML
+	// EngineAuto picks the engine based on the content of the files
+	EngineAuto = engine.Auto
+)
+
+const (
+	// FormatYAML writes the compose file as assembled
+	FormatYAML = "yaml"
+	// FormatYAMLNormalized writes the compose file with sorted keys and canonical values
+	FormatYAMLNormalized = "yaml-normalized"
+	// FormatJSON writes the compose file as JSON
+	FormatJSON = "json"
+)
+
+// Writer receives the files produced by Build and Decompose.
+// Names are slash-separated paths relative to the project directory.
+type Writer interface {
+	WriteFile(name string, data []byte) error
+}
+
+// WriterFunc adapts an ordinary function to the Writer interface
+type WriterFunc func(name string, data []byte) error
+
+// WriteFile calls f(name, data)
+func (f WriterFunc) WriteFile(name string, data []byte) error {
+	return f(name, data)
+}
+
+// Options configures Build and Decompose
+type Options struct {
+	// FS contains the project, the other paths are relative to its root
+	FS fs.FS
+	// Output receives the produced files. When nil, the files are only returned in the Result.
+	Output Writer
+	// Template is the path of the template file, DefaultTemplate when empty
+	Template string
+	// Compose is the path of the compose file, DefaultCompose when empty
+	Compose string
+	// ServicesDir is the path of the directory containing the service files, DefaultServicesDir when empty
+	ServicesDir string
+	// Engine is one of EngineText, EngineYAML and EngineAuto, EngineText when empty
+	Engine string
+	// Format is the format of the compose file written by Build, FormatYAML when empty
+	Format string
+	// NoProvenance disables the provenance header written by Build at the top of the compose file
+	NoProvenance bool
+}
+
+// File is a file produced by Build or Decompose
+type File struct {
+	// Name is the slash-separated path of the file relative to the project directory
+	Name    string
+	Content []byte
+}
+
+// Result describes the outcome of Build or Decompose
+type Result struct {
+	// Engine is the name of the engine that processed the files
+	Engine string
+	// EngineReasons explains the choice of the engine when EngineAuto was requested
+	EngineReasons []string
+	// Sources are the files that were read
+	Sources []string
+	// Services are the names of the services: the names of the service files without extension
+	// for Build, the services of the compose file for Decompose
+	Services []string
+	// Files are the produced files, in the order they were handed to the Writer
+	Files []File
+}
+
+// withDefaults returns the options with the empty fields set to their defaults
+func (o Options) withDefaults() (Options, error) {
+	if o.FS == nil {
+		return o, errors.New("dcm: no file system given in the options")
+	}
+	if o.Template == "" {
+		o.Template = DefaultTemplate
+	}
+	if o.Compose == "" {
+		o.Compose = DefaultCompose
+	}
+	if o.ServicesDir == "" {
+		o.ServicesDir = DefaultServicesDir
+	}
+	if o.Engine == "" {
+		o.Engine = EngineText
+	}
+	if o.Format == "" {
+		o.Format = FormatYAML
+	}
+	return o, nil
+}
+
+// write hands the files to the writer, stopping when the context is canceled
+func write(ctx context.Context, w Writer, files []File) error {
+	if w == nil {
+		return nil
+	}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := w.WriteFile(file.Name, file.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
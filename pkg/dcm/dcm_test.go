@@ -0,0 +1,113 @@
+package dcm
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// project returns a project with two services
+func project() fstest.MapFS {
+	return fstest.MapFS{
+		"docker-compose-dcm.yml": {Data: []byte("services:\n<dcm: include services\\>\nvolumes:\n  data:\n")},
+		"services/app.yml":       {Data: []byte("  app:\n    image: app # Application\n")},
+		"services/redis.yml":     {Data: []byte("  redis:\n    image: redis\n")},
+		"services/notes.txt":     {Data: []byte("not a service")},
+	}
+}
+
+// memoryWriter collects the written files
+type memoryWriter map[string]string
+
+func (w memoryWriter) WriteFile(name string, data []byte) error {
+	w[name] = string(data)
+	return nil
+}
+
+func TestBuild(t *testing.T) {
+	output := memoryWriter{}
+	result, err := Build(context.Background(), Options{FS: project(), Output: output, NoProvenance: true})
+	assert.NoError(t, err)
+
+	expected := "services:\n  app:\n    image: app # Application\n\n  redis:\n    image: redis\n\n\nvolumes:\n  data:\n"
+	assert.Equal(t, map[string]string{"docker-compose.yml": expected}, map[string]string(output))
+	assert.Equal(t, EngineText, result.Engine)
+	assert.Empty(t, result.EngineReasons)
+	assert.Equal(t, []string{"docker-compose-dcm.yml", "services/app.yml", "services/redis.yml"}, result.Sources)
+	assert.Equal(t, []string{"app", "redis"}, result.Services)
+	assert.Equal(t, []File{{Name: "docker-compose.yml", Content: []byte(expected)}}, result.Files)
+}
+
+func TestBuild_ProvenanceAndFormat(t *testing.T) {
+	result, err := Build(context.Background(), Options{FS: project()})
+	assert.NoError(t, err)
+	content := string(result.Files[0].Content)
+	assert.True(t, strings.HasPrefix(content, "# Generated by dcm"))
+	assert.Contains(t, content, "# dcm:source services/app.yml sha256:")
+
+	result, err = Build(context.Background(), Options{FS: project(), Format: FormatJSON, Engine: EngineAuto})
+	assert.NoError(t, err)
+	assert.Equal(t, EngineText, result.Engine)
+	assert.NotEmpty(t, result.EngineReasons)
+	assert.True(t, strings.HasPrefix(string(result.Files[0].Content), "{"))
+}
+
+func TestBuild_Errors(t *testing.T) {
+	_, err := Build(context.Background(), Options{})
+	assert.Error(t, err)
+
+	files := project()
+	delete(files, "docker-compose-dcm.yml")
+	_, err = Build(context.Background(), Options{FS: files})
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = Build(context.Background(), Options{FS: project(), Engine: "xml"})
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Build(ctx, Options{FS: project()})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDecompose(t *testing.T) {
+	files := fstest.MapFS{
+		"docker-compose.yml": {Data: []byte("services:\n    app:\n        image: app\n\nvolumes:\n    data:\n")},
+	}
+	output := memoryWriter{}
+	result, err := Decompose(context.Background(), Options{FS: files, Output: output, Engine: EngineAuto})
+	assert.NoError(t, err)
+
+	assert.Equal(t, EngineYAML, result.Engine)
+	assert.Equal(t, []string{"docker-compose.yml:2: service 'app' indented by 4 spaces instead of 2"}, result.EngineReasons)
+	assert.Equal(t, []string{"app"}, result.Services)
+	assert.Equal(t, memoryWriter{
+		"services/app.yml":       "app:\n    image: app\n",
+		"docker-compose-dcm.yml": "services:\n<dcm: include services\\>\n\nvolumes:\n    data:\n",
+	}, output)
+}
+
+func TestDirWriter(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Decompose(context.Background(), Options{
+		FS:     fstest.MapFS{"docker-compose.yml": {Data: []byte("services:\n  app:\n    image: app\n")}},
+		Output: DirWriter(dir),
+	})
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(dir, "services", "app.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "  app:\n    image: app\n", string(content))
+
+	// The decomposed project builds again from the directory
+	result, err := Build(context.Background(), Options{FS: os.DirFS(dir), NoProvenance: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "services:\n  app:\n    image: app\n\n\n", string(result.Files[0].Content))
+}
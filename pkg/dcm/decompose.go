@@ -0,0 +1,52 @@
+package dcm
+
+import (
+	"context"
+	"fmt"
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/logic/engine"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Decompose splits the compose file of the project into the template and one service file per service.
+// Existing files are not backed up; the Writer decides what happens to them.
+func Decompose(ctx context.Context, opts Options) (Result, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return Result{}, err
+	}
+
+	content, err := fs.ReadFile(opts.FS, opts.Compose)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	result := Result{Sources: []string{opts.Compose}}
+
+	selected, reasons, err := engine.Resolve(opts.Engine, func() (engine.Detection, error) {
+		return engine.DetectDecomposeContent(path.Base(opts.Compose), content)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	result.Engine, result.EngineReasons = selected.Name, reasons
+
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	decomposition, err := selected.Split(content)
+	if err != nil {
+		return Result{}, err
+	}
+
+	for _, service := range decomposition.Services {
+		result.Services = append(result.Services, strings.TrimSuffix(service.Name, ".yml"))
+		result.Files = append(result.Files, File{Name: path.Join(opts.ServicesDir, service.Name), Content: service.Content})
+	}
+	result.Files = append(result.Files, File{Name: opts.Template, Content: decomposition.Template})
+
+	if err := write(ctx, opts.Output, result.Files); err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,24 @@
+package dcm
+
+import (
+	"github.com/Benek2048/ZigzagDockerComposeMake/internal/helper/path"
+	"github.com/spf13/afero"
+	"path/filepath"
+)
+
+// DirWriter returns a Writer creating the files below the directory. Each file is replaced atomically,
+// a reader never sees a partially written file. Missing directories are created.
+func DirWriter(dir string) Writer {
+	return WriterFunc(func(name string, data []byte) error {
+		tx, err := path.NewTransaction(afero.NewOsFs(), dir)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := tx.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), data, 0644); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}